@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/velumlabs/hana/internal/twitter"
+	"github.com/velumlabs/thor/llm"
+)
+
+// runExport writes the memory of the agent to an archive
+func runExport(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	output := flags.String("o", "wrz-memory.tar.gz", "archive file to write")
+	model := flags.String("embedding-model", twitter.DefaultEmbeddingModel, "embedding model of the stored fragments")
+	dimensions := flags.Int("embedding-dimensions", twitter.DefaultEmbeddingDimensions, "dimensions of the embedding model")
+	flags.Parse(args)
+
+	log := newLogger()
+
+	file, err := os.Create(*output)
+	if err != nil {
+		log.Fatalf("Failed to create %s: %v", *output, err)
+	}
+
+	manifest, err := twitter.ExportMemory(context.Background(), openDatabase(log), file, *model, *dimensions)
+	if err == nil {
+		err = file.Close()
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(*output)
+		log.Fatalf("Failed to export memory: %v", err)
+	}
+
+	for name, rows := range manifest.Files {
+		log.Infof("Exported %d rows to %s", rows, name)
+	}
+	log.Infof("Wrote %s at schema version %d", *output, manifest.SchemaVersion)
+}
+
+// runImport reads an archive written by export into the database
+func runImport(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	conflict := flags.String("conflict", string(twitter.ImportConflictSkip), "rows whose ID exists: skip, overwrite or fail")
+	model := flags.String("embedding-model", twitter.DefaultEmbeddingModel, "embedding model of this agent, fragments embedded by another model are re-embedded")
+	dimensions := flags.Int("embedding-dimensions", twitter.DefaultEmbeddingDimensions, "dimensions of the embedding model")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	log := newLogger()
+	ctx := context.Background()
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", flags.Arg(0), err)
+	}
+	defer file.Close()
+
+	// The LLM client is only needed, and OPENAI_API_KEY only required, to re-embed
+	var llmClient *llm.LLMClient
+	report, err := twitter.ImportMemory(ctx, openDatabase(log), file, twitter.ImportOptions{
+		Conflict:            twitter.ImportConflict(*conflict),
+		EmbeddingModel:      *model,
+		EmbeddingDimensions: *dimensions,
+		Embed: func(text string) ([]float32, error) {
+			if llmClient == nil {
+				llmClient = newLLMClient(ctx, log)
+			}
+			return llmClient.EmbedText(text)
+		},
+	})
+	if err != nil {
+		log.Fatalf("Failed to import memory: %v", err)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatalf("Failed to write report: %v", err)
+		}
+		return
+	}
+
+	if report.Reembed {
+		log.Infof("Re-embedded fragments of %s (%d dimensions) with %s", report.Manifest.EmbeddingModel, report.Manifest.EmbeddingDimensions, *model)
+	}
+	for _, imported := range report.Files {
+		log.Infof("%s: %d rows, %d written, %d skipped, %d dropped, %d re-embedded", imported.File, imported.Rows, imported.Written, imported.Skipped, imported.Dropped, imported.Reembedded)
+	}
+}
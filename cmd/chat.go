@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/velumlabs/hana/internal/twitter"
+	"github.com/velumlabs/thor/logger"
+	"github.com/velumlabs/thor/options"
+)
+
+const chatHelp = `Commands:
+  /prompt          print the prompt composed for the last reply
+  /reset           forget the conversation and start a new one
+  /persona <file>  switch to the personality in a JSON file
+  /quit            leave the chat
+`
+
+// runChat talks to the persona from the terminal. Each line is processed as a tweet
+// of a local user through the reply pipeline, without Twitter credentials or posting.
+func runChat(args []string) {
+	flags := flag.NewFlagSet("chat", flag.ExitOnError)
+	persona := flags.String("persona", "", "JSON file of the personality to chat with, defaults to the built-in one")
+	user := flags.String("user", "local", "user name the messages are sent as")
+	asJSON := flags.Bool("json", false, "print replies as JSON lines")
+	flags.Parse(args)
+
+	log := newLoggerAt("warn")
+
+	// Interrupts end the chat through the deferred session cleanup instead of killing the process
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	database := openDatabase(log)
+	usage := twitter.NewUsageTracker(ctx, database, log.NewSubLogger("usage", &logger.SubLoggerOpts{}), twitter.DefaultModelPrices)
+	http.DefaultTransport = usage.Transport(http.DefaultTransport)
+
+	opts := []options.Option[twitter.Twitter]{
+		twitter.WithContext(ctx),
+		twitter.WithLogger(log.NewSubLogger("thor", &logger.SubLoggerOpts{})),
+		twitter.WithDatabase(database),
+		twitter.WithLLM(newLLMClient(ctx, log)),
+		twitter.WithUsageTracking(usage, twitter.UsageConfig{}),
+		twitter.WithTwitterCredentials("", "", os.Getenv("TWITTER_USER")),
+		twitter.WithLocalMode(),
+	}
+	if *persona != "" {
+		p, err := twitter.LoadPersonality(*persona)
+		if err != nil {
+			log.Fatalf("Failed to load persona: %v", err)
+		}
+		opts = append(opts, twitter.WithPersonality(p))
+	}
+
+	k, err := twitter.New(opts...)
+	if err != nil {
+		log.Fatalf("Failed to create thor: %v", err)
+	}
+
+	session, err := k.NewChatSession(*user)
+	if err != nil {
+		log.Fatalf("Failed to start chat: %v", err)
+	}
+	defer func() {
+		if err := session.Close(); err != nil {
+			log.Errorf("Failed to delete chat session: %v", err)
+		}
+	}()
+
+	fmt.Printf("Chatting with %s, /help lists the commands\n", k.Personality().Name)
+
+	// Lines are read in the background so that an interrupt doesn't wait for the next line
+	lines := make(chan string)
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	go func() {
+		defer close(lines)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			log.Errorf("Failed to read input: %v", err)
+		}
+	}()
+
+	for {
+		fmt.Print("> ")
+		var line string
+		select {
+		case <-ctx.Done():
+			fmt.Println()
+			return
+		case text, ok := <-lines:
+			if !ok {
+				fmt.Println()
+				return
+			}
+			line = strings.TrimSpace(text)
+		}
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "/") {
+			command, argument, _ := strings.Cut(line, " ")
+			argument = strings.TrimSpace(argument)
+			switch command {
+			case "/quit", "/exit":
+				return
+			case "/help":
+				fmt.Print(chatHelp)
+			case "/prompt":
+				prompt := session.Prompt()
+				if prompt == nil {
+					fmt.Println("No reply yet")
+				}
+				for _, message := range prompt {
+					fmt.Printf("--- %s ---\n%s\n", message.Role, message.Content)
+				}
+			case "/reset":
+				if err := session.Reset(); err != nil {
+					log.Errorf("Failed to reset chat: %v", err)
+					continue
+				}
+				fmt.Println("Started a new conversation")
+			case "/persona":
+				if argument == "" {
+					fmt.Println("Usage: /persona <file>")
+					continue
+				}
+				p, err := twitter.LoadPersonality(argument)
+				if err != nil {
+					log.Errorf("Failed to load persona: %v", err)
+					continue
+				}
+				if err := k.SetPersonality(p); err != nil {
+					log.Errorf("Failed to switch persona: %v", err)
+					continue
+				}
+				if err := session.Reset(); err != nil {
+					log.Errorf("Failed to reset chat: %v", err)
+				}
+				fmt.Printf("Now chatting with %s in a new conversation\n", p.Name)
+			default:
+				fmt.Printf("Unknown command %s\n%s", command, chatHelp)
+			}
+			continue
+		}
+
+		reply, err := session.Send(line)
+		if err != nil {
+			log.Errorf("Failed to reply: %v", err)
+			continue
+		}
+
+		if *asJSON {
+			if err := json.NewEncoder(os.Stdout).Encode(reply); err != nil {
+				log.Errorf("Failed to write reply: %v", err)
+				return
+			}
+			continue
+		}
+		if reply.Reasoning != "" {
+			fmt.Printf("\n[thinking]\n%s\n\n", reply.Reasoning)
+		}
+		fmt.Printf("%s: %s\n", k.Personality().Name, reply.Answer)
+	}
+}
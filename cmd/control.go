@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/velumlabs/hana/internal/twitter"
+)
+
+// runControl changes the runtime controls of the agent.
+// Controls are written to the database, so they apply to a running agent
+// within one check and persist across restarts.
+func runControl(command string, args []string) {
+	log := newLogger()
+	database := openDatabase(log)
+	requireSchema(log, database)
+	controlStore := twitter.NewControlStore(context.Background(), database)
+
+	var err error
+	switch command {
+	case "pause", "resume":
+		if len(args) != 0 {
+			controlUsage()
+		}
+		err = controlStore.SetPaused(command == "pause")
+	case "mute", "unmute":
+		if len(args) != 2 {
+			controlUsage()
+		}
+		kind := twitter.MuteKind(args[0])
+		if command == "mute" {
+			err = controlStore.Mute(kind, args[1])
+		} else {
+			err = controlStore.Unmute(kind, args[1])
+		}
+	case "status":
+	}
+	if err != nil {
+		log.Fatalf("Failed to %s: %v", command, err)
+	}
+
+	paused, err := controlStore.IsPaused()
+	if err != nil {
+		log.Fatalf("Failed to get pause state: %v", err)
+	}
+	mutes, err := controlStore.ListMutes()
+	if err != nil {
+		log.Fatalf("Failed to list mutes: %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(map[string]interface{}{
+		"paused": paused,
+		"mutes":  mutes,
+	})
+}
+
+func controlUsage() {
+	fmt.Fprint(os.Stderr, usage)
+	os.Exit(2)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/velumlabs/hana/internal/twitter"
+	"github.com/velumlabs/thor/llm"
+)
+
+// evalVariantFile is the JSON file describing a reply configuration to evaluate
+type evalVariantFile struct {
+	Name        string  `json:"name"`
+	PromptFile  string  `json:"prompt_file"` // relative to the variant file, empty uses the default prompt
+	ModelType   string  `json:"model_type"`
+	Temperature float32 `json:"temperature"`
+}
+
+// runEval generates replies to a fixture dataset with one or two reply configurations
+// and writes a report comparing them
+func runEval(args []string) {
+	flags := flag.NewFlagSet("eval", flag.ExitOnError)
+	fixtures := flags.String("fixtures", "", "JSONL file of fixtures, one {id, thread, tweet} object per line")
+	variantA := flags.String("a", "", "JSON file of the first configuration, defaults to the current configuration")
+	variantB := flags.String("b", "", "JSON file of the configuration to compare against")
+	judge := flags.Bool("judge", false, "also grade every reply with the LLM judge")
+	jsonOut := flags.String("json", "eval-report.json", "path of the JSON report")
+	htmlOut := flags.String("html", "eval-report.html", "path of the HTML report, empty to skip")
+	flags.Parse(args)
+
+	log := newLogger()
+
+	if *fixtures == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	source, err := os.Open(*fixtures)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", *fixtures, err)
+	}
+	evalOpts := twitter.EvalOptions{Judge: *judge}
+	evalOpts.Fixtures, err = twitter.ReadEvalFixtures(source)
+	source.Close()
+	if err != nil {
+		log.Fatalf("Failed to read fixtures: %v", err)
+	}
+
+	current := twitter.EvalVariant{
+		Name: "current",
+		Response: twitter.ResponseConfig{
+			ModelType:   llm.ModelTypeDefault,
+			Temperature: 0.7,
+		},
+	}
+	for _, path := range []string{*variantA, *variantB} {
+		if path == "" {
+			if len(evalOpts.Variants) == 0 {
+				evalOpts.Variants = append(evalOpts.Variants, current)
+			}
+			continue
+		}
+		variant, err := loadEvalVariant(path, current.Response)
+		if err != nil {
+			log.Fatalf("Failed to load configuration %s: %v", path, err)
+		}
+		evalOpts.Variants = append(evalOpts.Variants, variant)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k, err := twitter.New(agentOptions(ctx, log)...)
+	if err != nil {
+		log.Fatalf("Failed to create thor: %v", err)
+	}
+
+	report, err := k.Eval(evalOpts)
+	if err != nil {
+		log.Fatalf("Failed to evaluate replies: %v", err)
+	}
+
+	output, err := os.Create(*jsonOut)
+	if err != nil {
+		log.Fatalf("Failed to create %s: %v", *jsonOut, err)
+	}
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(report)
+	output.Close()
+	if err != nil {
+		log.Fatalf("Failed to write JSON report: %v", err)
+	}
+
+	if *htmlOut != "" {
+		output, err := os.Create(*htmlOut)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %v", *htmlOut, err)
+		}
+		err = report.WriteHTML(output)
+		output.Close()
+		if err != nil {
+			log.Fatalf("Failed to write HTML report: %v", err)
+		}
+	}
+
+	for _, variant := range report.Variants {
+		fmt.Printf("%s: %d replies, %d errors, check score %.0f%%", variant.Name, variant.Replies, variant.Errors, variant.CheckScore*100)
+		if report.Judge {
+			fmt.Printf(", judge score %.2f", variant.JudgeScore)
+		}
+		fmt.Println()
+	}
+}
+
+// loadEvalVariant reads a configuration file, falling back to the defaults for missing fields
+func loadEvalVariant(path string, defaults twitter.ResponseConfig) (twitter.EvalVariant, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return twitter.EvalVariant{}, err
+	}
+
+	file := evalVariantFile{
+		ModelType:   string(defaults.ModelType),
+		Temperature: defaults.Temperature,
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return twitter.EvalVariant{}, fmt.Errorf("failed to parse configuration: %w", err)
+	}
+
+	response, err := file.responseConfig(filepath.Dir(path))
+	if err != nil {
+		return twitter.EvalVariant{}, err
+	}
+
+	variant := twitter.EvalVariant{
+		Name:     file.Name,
+		Response: response,
+	}
+	if variant.Name == "" {
+		variant.Name = filepath.Base(path)
+	}
+
+	return variant, nil
+}
+
+// responseConfig builds the reply configuration described by the file.
+// Relative prompt paths are resolved against dir.
+func (f evalVariantFile) responseConfig(dir string) (twitter.ResponseConfig, error) {
+	config := twitter.ResponseConfig{
+		ModelType:   llm.ModelType(f.ModelType),
+		Temperature: f.Temperature,
+	}
+
+	if f.PromptFile != "" {
+		promptPath := f.PromptFile
+		if !filepath.IsAbs(promptPath) {
+			promptPath = filepath.Join(dir, promptPath)
+		}
+		prompt, err := os.ReadFile(promptPath)
+		if err != nil {
+			return config, fmt.Errorf("failed to read prompt: %w", err)
+		}
+		config.Prompt = string(prompt)
+	}
+
+	return config, nil
+}
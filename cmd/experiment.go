@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/velumlabs/hana/internal/twitter"
+	"github.com/velumlabs/thor/llm"
+)
+
+// experimentFile is the JSON file configured in EXPERIMENT_FILE
+type experimentFile struct {
+	Name string `json:"name"`
+	Arms []struct {
+		evalVariantFile
+		PromptVersion string `json:"prompt_version"`
+		Weight        int    `json:"weight"`
+	} `json:"arms"`
+}
+
+// loadExperiment reads the experiment described by a JSON file.
+// Arms default to the default model, a temperature of 0.7 and a weight of 1.
+func loadExperiment(path string) (twitter.ExperimentConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return twitter.ExperimentConfig{}, err
+	}
+
+	var file experimentFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return twitter.ExperimentConfig{}, fmt.Errorf("failed to parse experiment: %w", err)
+	}
+
+	config := twitter.ExperimentConfig{Name: file.Name}
+	for _, arm := range file.Arms {
+		if arm.ModelType == "" {
+			arm.ModelType = string(llm.ModelTypeDefault)
+		}
+		if arm.Temperature == 0 {
+			arm.Temperature = 0.7
+		}
+		if arm.Weight == 0 {
+			arm.Weight = 1
+		}
+
+		response, err := arm.responseConfig(filepath.Dir(path))
+		if err != nil {
+			return twitter.ExperimentConfig{}, fmt.Errorf("failed to load arm %s: %w", arm.Name, err)
+		}
+
+		config.Arms = append(config.Arms, twitter.ExperimentArm{
+			Name:          arm.Name,
+			PromptVersion: arm.PromptVersion,
+			Weight:        arm.Weight,
+			Response:      response,
+		})
+	}
+
+	return config, nil
+}
+
+// runExperiment compares the arms of an experiment by the engagement their replies received
+func runExperiment(args []string) {
+	if len(args) == 0 || args[0] != "report" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("experiment report", flag.ExitOnError)
+	name := flags.String("name", "", "experiment to report on, defaults to the experiment in EXPERIMENT_FILE")
+	refresh := flags.Bool("refresh", false, "collect the current engagement of recent replies first")
+	since := flags.Duration("since", 7*24*time.Hour, "age of the oldest replies to refresh")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	flags.Parse(args[1:])
+
+	log := newLogger()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	experiment := *name
+	opts := agentOptions(ctx, log)
+	if path := os.Getenv("EXPERIMENT_FILE"); path != "" && experiment == "" {
+		config, err := loadExperiment(path)
+		if err != nil {
+			log.Fatalf("Failed to load experiment: %v", err)
+		}
+		experiment = config.Name
+	}
+	if experiment == "" {
+		log.Fatalf("No experiment given, pass -name or set EXPERIMENT_FILE")
+	}
+
+	k, err := twitter.New(opts...)
+	if err != nil {
+		log.Fatalf("Failed to create thor: %v", err)
+	}
+
+	if *refresh {
+		refreshed, err := k.CollectReplyEngagement(time.Now().Add(-*since))
+		if err != nil {
+			log.Fatalf("Failed to collect engagement: %v", err)
+		}
+		log.Infof("Refreshed engagement of %d replies", refreshed)
+	}
+
+	report, err := k.ExperimentReport(experiment)
+	if err != nil {
+		log.Fatalf("Failed to build report: %v", err)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/velumlabs/hana/internal/twitter"
+)
+
+// runForgetUser permanently removes every piece of data kept about a user
+func runForgetUser(args []string) {
+	if len(args) != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	log := newLogger()
+
+	report, err := twitter.ForgetUser(context.Background(), openDatabase(log), args[0])
+	if err != nil {
+		log.Fatalf("Failed to forget user: %v", err)
+	}
+
+	for table, count := range report.DeletedFragments {
+		log.Infof("Deleted %d %s fragments", count, table)
+	}
+	log.Infof("Anonymized %d fragments, %d LLM usage records and %d admin audit entries",
+		report.AnonymizedFragment,
+		report.AnonymizedUsage,
+		report.AnonymizedAdminAudit,
+	)
+	log.Infof("Deleted %d sessions, %d actors, %d profiles, %d actions, %d opt-ins, %d mutes and %d audit events",
+		report.DeletedSessions,
+		report.DeletedActors,
+		report.DeletedProfiles,
+		report.DeletedActions,
+		report.DeletedOptIns,
+		report.DeletedMutes,
+		report.DeletedAuditEvents,
+	)
+	log.Infof("Deleted %d replies, %d engagement samples and %d cached embeddings",
+		report.DeletedReplies,
+		report.DeletedSamples,
+		report.DeletedEmbeddings,
+	)
+	log.Infof("Forgot user %s and added them to the do-not-engage list", args[0])
+}
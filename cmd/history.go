@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/velumlabs/hana/internal/twitter"
+)
+
+// runHistory prints the audit events of a tweet, user or conversation
+func runHistory(args []string) {
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	tweet := flags.String("tweet", "", "ID of the tweet the events are about")
+	user := flags.String("user", "", "ID or @username of the tweet author")
+	conversation := flags.String("conversation", "", "ID of the conversation")
+	types := flags.String("type", "", "comma separated event types, such as tweet_skipped,reply_failed")
+	since := flags.Duration("since", 0, "age of the oldest events, zero for all")
+	limit := flags.Int("limit", 100, "most recent events printed, zero for all")
+	asJSON := flags.Bool("json", false, "print the events as JSON")
+	flags.Parse(args)
+
+	if *tweet == "" && *user == "" && *conversation == "" && *types == "" {
+		fmt.Fprintln(os.Stderr, "history requires -tweet, -user, -conversation or -type")
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	log := newLogger()
+
+	database := openDatabase(log)
+	requireSchema(log, database)
+
+	filter := twitter.AuditFilter{
+		TweetID:        *tweet,
+		ConversationID: *conversation,
+		Limit:          *limit,
+	}
+	if strings.HasPrefix(*user, "@") {
+		filter.UserName = *user
+	} else {
+		filter.UserID = *user
+	}
+	if *types != "" {
+		for _, eventType := range strings.Split(*types, ",") {
+			filter.Types = append(filter.Types, twitter.AuditEventType(strings.TrimSpace(eventType)))
+		}
+	}
+	if *since > 0 {
+		filter.Since = time.Now().Add(-*since)
+	}
+
+	events, err := twitter.NewAuditStore(context.Background(), database).Query(filter)
+	if err != nil {
+		log.Fatalf("Failed to query audit events: %v", err)
+	}
+	history := &twitter.AuditHistory{Events: events}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(history)
+	} else {
+		err = history.WriteText(os.Stdout)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write history: %v", err)
+	}
+}
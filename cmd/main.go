@@ -2,18 +2,15 @@ package main
 
 import (
 	"context"
+	"fmt"
 
 	"log"
 	"os"
-	"time"
+	"os/signal"
+	"syscall"
 
 	"github.com/joho/godotenv"
-	"github.com/sashabaranov/go-openai"
 	"github.com/velumlabs/hana/internal/twitter"
-	"github.com/velumlabs/thor/llm"
-	"github.com/velumlabs/thor/logger"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
 )
 
 func main() {
@@ -22,56 +19,93 @@ func main() {
 		log.Fatal("Error loading .env file")
 	}
 
-	// Initialize logger
-	log, err := logger.New(&logger.Config{
-		Level:      "info",
-		TreeFormat: true,
-		TimeFormat: "2006-01-02 15:04:05",
-		UseColors:  true,
-	})
-	if err != nil {
-		log.Fatalf("Failed to create logger: %v", err)
+	args := os.Args[1:]
+	if len(args) == 0 {
+		args = []string{"run"}
 	}
 
-	// Create context
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Initialize database
-	db, err := gorm.Open(postgres.Open(os.Getenv("DB_URL")), &gorm.Config{})
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+	switch args[0] {
+	case "run":
+		run()
+	case "profile":
+		runProfile(args[1:])
+	case "forget-user":
+		runForgetUser(args[1:])
+	case "pause", "resume", "status", "mute", "unmute":
+		runControl(args[0], args[1:])
+	case "replay":
+		runReplay(args[1:])
+	case "eval":
+		runEval(args[1:])
+	case "chat":
+		runChat(args[1:])
+	case "experiment":
+		runExperiment(args[1:])
+	case "usage":
+		runUsage(args[1:])
+	case "migrate":
+		runMigrate(args[1:])
+	case "retention":
+		runRetention(args[1:])
+	case "history":
+		runHistory(args[1:])
+	case "export":
+		runExport(args[1:])
+	case "import":
+		runImport(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+		os.Exit(2)
 	}
+}
+
+const usage = `Usage:
+  wrz [run]                       run the agent, requires an up to date schema
+  wrz migrate up                  apply pending database migrations
+  wrz migrate down [steps]        revert the last applied migrations, one by default
+  wrz migrate status              list migrations and when they were applied
+  wrz profile show <username>     print the profile kept about a user
+  wrz profile erase <username>    delete the profile kept about a user
+  wrz forget-user <id|@username>  delete all data about a user and never engage with them again
+  wrz pause                       stop fetching and posting, persists across restarts
+  wrz resume                      resume fetching and posting
+  wrz status                      print the pause state and active mutes
+  wrz mute conversation <id>      stop engaging in a conversation
+  wrz mute user <id|@username>    stop engaging with a user
+  wrz unmute conversation|user <target>
+  wrz replay [flags]              re-run the pipeline over past tweets without posting, see replay -h
+  wrz eval [flags]                score replies to a fixture dataset and compare configurations, see eval -h
+  wrz chat [flags]                talk to the persona from the terminal without Twitter, see chat -h
+  wrz experiment report [flags]   compare experiment arms by the engagement of their replies
+  wrz usage [flags]               report LLM token usage and cost, see usage -h
+  wrz history [flags]             list the agent's decisions about a tweet, user or conversation, see history -h
+  wrz retention [-dry-run]        remove expired fragments now, or report what would be removed
+  wrz export [-o file]            write actors, sessions and fragments to a portable archive
+  wrz import [flags] <file>       load an exported archive, see import -h for ID conflicts and re-embedding
+`
 
-	// Initialize LLM client
-	llmClient, err := llm.NewLLMClient(llm.Config{
-		ProviderType: llm.ProviderOpenAI,
-		APIKey:       os.Getenv("OPENAI_API_KEY"),
-		ModelConfig: map[llm.ModelType]string{
-			llm.ModelTypeFast:     openai.GPT4oMini,
-			llm.ModelTypeDefault:  openai.GPT4oMini,
-			llm.ModelTypeAdvanced: openai.GPT4o,
-		},
-		Logger:  log.NewSubLogger("llm", &logger.SubLoggerOpts{}),
-		Context: ctx,
-	})
+// run starts the agent and blocks until it stops
+func run() {
+	// Initialize logger
+	log := newLogger()
+
+	// Create context, canceled on interrupt
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	// Trace the reply pipeline
+	shutdownTracing := setupTracing(ctx, log)
+	defer shutdownTracing()
 
 	// Create Twitter instance with options
-	k, err := twitter.New(
-		twitter.WithContext(ctx),
-		twitter.WithLogger(log.NewSubLogger("thor", &logger.SubLoggerOpts{})),
-		twitter.WithDatabase(db),
-		twitter.WithLLM(llmClient),
-		twitter.WithTwitterMonitorInterval(
-			60*time.Second,  // min interval
-			120*time.Second, // max interval
-		),
-		twitter.WithTwitterCredentials(
-			os.Getenv("TWITTER_CT0"),
-			os.Getenv("TWITTER_AUTH_TOKEN"),
-			os.Getenv("TWITTER_USER"),
-		),
-	)
+	opts := agentOptions(ctx, log)
+
+	// Serve the local admin API
+	if address := os.Getenv("ADMIN_ADDRESS"); address != "" {
+		opts = append(opts, twitter.WithAdminAPI(address))
+	}
+
+	k, err := twitter.New(opts...)
 	if err != nil {
 		log.Fatalf("Failed to create thor: %v", err)
 	}
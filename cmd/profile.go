@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/velumlabs/hana/internal/twitter"
+	"gorm.io/gorm"
+)
+
+// runProfile inspects or erases the profile the agent keeps about a user
+func runProfile(args []string) {
+	if len(args) != 2 || (args[0] != "show" && args[0] != "erase") {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	log := newLogger()
+	profileStore := twitter.NewProfileStore(context.Background(), openDatabase(log))
+
+	profile, err := profileStore.GetByUserName(args[1])
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Fatalf("No profile found for %s", args[1])
+	}
+	if err != nil {
+		log.Fatalf("Failed to get profile: %v", err)
+	}
+
+	switch args[0] {
+	case "show":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(profile); err != nil {
+			log.Fatalf("Failed to print profile: %v", err)
+		}
+	case "erase":
+		if err := profileStore.DeleteByActorID(profile.ActorID); err != nil {
+			log.Fatalf("Failed to erase profile: %v", err)
+		}
+		log.Infof("Erased profile of %s", profile.UserName)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"time"
+
+	"github.com/velumlabs/hana/internal/twitter"
+)
+
+// runReplay re-runs the pipeline over historical tweets without posting and
+// prints the original replies next to the new ones
+func runReplay(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	from := flags.String("from", "", "replay stored tweets created at or after this RFC 3339 time")
+	to := flags.String("to", "", "replay stored tweets created before this RFC 3339 time, defaults to now")
+	ids := flags.String("ids", "", "comma separated IDs of stored tweets to replay")
+	file := flags.String("file", "", "JSONL export of tweets to replay")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	flags.Parse(args)
+
+	log := newLogger()
+
+	var replayOpts twitter.ReplayOptions
+	switch {
+	case *file != "":
+		source, err := os.Open(*file)
+		if err != nil {
+			log.Fatalf("Failed to open %s: %v", *file, err)
+		}
+		defer source.Close()
+		replayOpts.Source = source
+	case *ids != "":
+		replayOpts.TweetIDs = splitList(*ids)
+	case *from != "":
+		start, err := time.Parse(time.RFC3339, *from)
+		if err != nil {
+			log.Fatalf("Invalid -from time: %v", err)
+		}
+		end := time.Now()
+		if *to != "" {
+			if end, err = time.Parse(time.RFC3339, *to); err != nil {
+				log.Fatalf("Invalid -to time: %v", err)
+			}
+		}
+		replayOpts.Start, replayOpts.End = start, end
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Replayed tweets are read from the database or the export, never fetched
+	// from Twitter, so the agent runs in local mode without credentials
+	k, err := twitter.New(append(agentOptions(ctx, log), twitter.WithLocalMode())...)
+	if err != nil {
+		log.Fatalf("Failed to create thor: %v", err)
+	}
+
+	report, err := k.Replay(replayOpts)
+	if err != nil {
+		log.Fatalf("Failed to replay tweets: %v", err)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+
+	"github.com/velumlabs/hana/internal/twitter"
+)
+
+// runRetention applies the retention policies once, or reports what they would remove
+func runRetention(args []string) {
+	flags := flag.NewFlagSet("retention", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "report what would be removed without removing or summarizing anything")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	flags.Parse(args)
+
+	log := newLogger()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k, err := twitter.New(agentOptions(ctx, log)...)
+	if err != nil {
+		log.Fatalf("Failed to create thor: %v", err)
+	}
+
+	report, err := k.RunRetention(*dryRun)
+	if err != nil {
+		log.Fatalf("Failed to apply retention policies: %v", err)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+}
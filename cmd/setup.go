@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/velumlabs/hana/internal/twitter"
+	"github.com/velumlabs/thor/db"
+	"github.com/velumlabs/thor/llm"
+	"github.com/velumlabs/thor/logger"
+	"github.com/velumlabs/thor/options"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// newLogger creates the logger shared by every command
+func newLogger() *logger.Logger {
+	return newLoggerAt("info")
+}
+
+// newLoggerAt creates a logger writing entries at or above level
+func newLoggerAt(level string) *logger.Logger {
+	log, err := logger.New(&logger.Config{
+		Level:      level,
+		TreeFormat: true,
+		TimeFormat: "2006-01-02 15:04:05",
+		UseColors:  true,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create logger: %v\n", err)
+		os.Exit(1)
+	}
+	return log
+}
+
+// splitList splits a comma separated list, trimming the entries and dropping empty ones
+func splitList(value string) []string {
+	var entries []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// openDatabase connects to the database selected by DB_DRIVER:
+// Postgres at DB_URL by default, or an embedded SQLite database at DB_PATH
+func openDatabase(log *logger.Logger) *gorm.DB {
+	var db *gorm.DB
+	var err error
+	switch driver := os.Getenv("DB_DRIVER"); driver {
+	case "", "postgres":
+		db, err = gorm.Open(postgres.Open(os.Getenv("DB_URL")), &gorm.Config{})
+	case twitter.DialectSQLite:
+		path := os.Getenv("DB_PATH")
+		if path == "" {
+			path = "wrz.db"
+		}
+		db, err = twitter.OpenSQLite(path)
+	default:
+		log.Fatalf("Unsupported DB_DRIVER: %s", driver)
+	}
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	return db
+}
+
+// requireSchema exits if the database schema is behind the migrations of this build
+func requireSchema(log *logger.Logger, db *gorm.DB) {
+	if err := twitter.NewMigrator(context.Background(), db).CheckCurrent(); err != nil {
+		log.Fatalf("%v", err)
+	}
+}
+
+// newLLMClient creates the LLM client configured in OPENAI_API_KEY
+func newLLMClient(ctx context.Context, log *logger.Logger) *llm.LLMClient {
+	llmClient, err := llm.NewLLMClient(llm.Config{
+		ProviderType: llm.ProviderOpenAI,
+		APIKey:       os.Getenv("OPENAI_API_KEY"),
+		ModelConfig: map[llm.ModelType]string{
+			llm.ModelTypeFast:     openai.GPT4oMini,
+			llm.ModelTypeDefault:  openai.GPT4oMini,
+			llm.ModelTypeAdvanced: openai.GPT4o,
+		},
+		Logger:  log.NewSubLogger("llm", &logger.SubLoggerOpts{}),
+		Context: ctx,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create LLM client: %v", err)
+	}
+	return llmClient
+}
+
+// agentOptions returns the options of the agent configured in the environment
+func agentOptions(ctx context.Context, log *logger.Logger) []options.Option[twitter.Twitter] {
+	// Initialize database
+	database := openDatabase(log)
+
+	// Initialize LLM client
+	llmClient := newLLMClient(ctx, log)
+
+	// Record the usage of every LLM call. The LLM client takes no HTTP client,
+	// so its requests are captured by wrapping the default transport.
+	usage := twitter.NewUsageTracker(ctx, database, log.NewSubLogger("usage", &logger.SubLoggerOpts{}), twitter.DefaultModelPrices)
+	http.DefaultTransport = usage.Transport(http.DefaultTransport)
+
+	// Alert on operational failures, the alerter sees LLM calls through the default transport as well
+	alerter := alerterFromEnv(log)
+	if alerter != nil {
+		http.DefaultTransport = alerter.Transport(http.DefaultTransport)
+	}
+
+	var budget float64
+	if value := os.Getenv("LLM_DAILY_BUDGET"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			log.Fatalf("Invalid LLM_DAILY_BUDGET: %v", err)
+		}
+		budget = parsed
+	}
+
+	opts := []options.Option[twitter.Twitter]{
+		twitter.WithContext(ctx),
+		twitter.WithLogger(log.NewSubLogger("thor", &logger.SubLoggerOpts{})),
+		twitter.WithDatabase(database),
+		twitter.WithLLM(llmClient),
+		twitter.WithUsageTracking(usage, twitter.UsageConfig{DailyBudget: budget}),
+		twitter.WithTwitterMonitorInterval(
+			60*time.Second,  // min interval
+			120*time.Second, // max interval
+		),
+		twitter.WithTwitterCredentials(
+			os.Getenv("TWITTER_CT0"),
+			os.Getenv("TWITTER_AUTH_TOKEN"),
+			os.Getenv("TWITTER_USER"),
+		),
+	}
+
+	// Decide between replying, liking or ignoring before generating replies, and take engagement actions
+	opts = append(opts, twitter.WithReplyDecision(twitter.DecisionConfig{
+		Enabled:        os.Getenv("REPLY_DECISION_ENABLED") != "false",
+		ReplyThreshold: twitter.DefaultReplyThreshold,
+		LikeThreshold:  twitter.DefaultLikeThreshold,
+	}))
+
+	// Enable direct message replies
+	if os.Getenv("TWITTER_DM_ENABLED") == "true" {
+		allowlist := splitList(os.Getenv("TWITTER_DM_ALLOWLIST"))
+
+		policy := twitter.DMPolicy(os.Getenv("TWITTER_DM_POLICY"))
+		if policy == "" {
+			policy = twitter.DMPolicyAllowlist
+		}
+
+		opts = append(opts, twitter.WithDirectMessages(twitter.DMConfig{
+			Enabled: true,
+			PollInterval: twitter.IntervalConfig{
+				Min: 60 * time.Second,
+				Max: 120 * time.Second,
+			},
+			Policy:        policy,
+			Allowlist:     allowlist,
+			OptInKeyword:  "start",
+			OptOutKeyword: "stop",
+			ReplyLimit:    twitter.ActionLimit{Max: 100, Window: 24 * time.Hour},
+			PerUserLimit:  twitter.ActionLimit{Max: 20, Window: 24 * time.Hour},
+			MaxAge:        24 * time.Hour,
+		}))
+	}
+
+	// Collect the engagement of posted replies
+	engagement := twitter.EngagementConfig{
+		Enabled: os.Getenv("TWITTER_ENGAGEMENT_ENABLED") != "false",
+		Interval: twitter.IntervalConfig{
+			Min: 30 * time.Minute,
+			Max: 45 * time.Minute,
+		},
+		Window:         7 * 24 * time.Hour,
+		MaxPerCycle:    50,
+		PromptExamples: 3,
+	}
+	if value := os.Getenv("TWITTER_ENGAGEMENT_WINDOW"); value != "" {
+		window, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("Invalid TWITTER_ENGAGEMENT_WINDOW: %v", err)
+		}
+		engagement.Window = window
+	}
+	opts = append(opts, twitter.WithEngagementCollector(engagement))
+
+	// Remove old fragments, summarizing conversations first
+	conversationAge, tweetAge := 90*24*time.Hour, 30*24*time.Hour
+	for name, age := range map[string]*time.Duration{
+		"RETENTION_CONVERSATION_MAX_AGE": &conversationAge,
+		"RETENTION_TWEET_MAX_AGE":        &tweetAge,
+	} {
+		if value := os.Getenv(name); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				log.Fatalf("Invalid %s: %v", name, err)
+			}
+			*age = parsed
+		}
+	}
+	opts = append(opts, twitter.WithRetention(twitter.RetentionConfig{
+		Enabled: os.Getenv("RETENTION_ENABLED") == "true",
+		Interval: twitter.IntervalConfig{
+			Min: 24 * time.Hour,
+			Max: 26 * time.Hour,
+		},
+		Policies: []twitter.RetentionPolicy{
+			{Table: db.FragmentTableInteraction, MaxAge: conversationAge, SummaryTable: db.FragmentTableInsight},
+			{Table: db.FragmentTableTwitter, MaxAge: tweetAge},
+			{Table: twitter.FragmentTableDirectMessage, MaxAge: conversationAge, SummaryTable: twitter.FragmentTableDirectMessageInsight},
+		},
+		MaxSummaries: 50,
+		Vacuum:       true,
+	}))
+
+	// Record every decision about tweets
+	opts = append(opts, twitter.WithAuditLog(twitter.AuditConfig{
+		Enabled: os.Getenv("AUDIT_ENABLED") != "false",
+		File:    os.Getenv("AUDIT_LOG_FILE"),
+	}))
+
+	if alerter != nil {
+		config := twitter.AlertsConfig{
+			CheckInterval:    time.Minute,
+			TimelineFailures: 3,
+			NoPostWindow:     6 * time.Hour,
+			LLMErrorRate:     0.5,
+			LLMWindow:        15 * time.Minute,
+			LLMMinCalls:      5,
+			RepeatInterval:   6 * time.Hour,
+			MaxPerHour:       10,
+		}
+		if value := os.Getenv("ALERT_TIMELINE_FAILURES"); value != "" {
+			failures, err := strconv.Atoi(value)
+			if err != nil {
+				log.Fatalf("Invalid ALERT_TIMELINE_FAILURES: %v", err)
+			}
+			config.TimelineFailures = failures
+		}
+		if value := os.Getenv("ALERT_LLM_ERROR_RATE"); value != "" {
+			rate, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				log.Fatalf("Invalid ALERT_LLM_ERROR_RATE: %v", err)
+			}
+			config.LLMErrorRate = rate
+		}
+		for name, duration := range map[string]*time.Duration{
+			"ALERT_NO_POST_WINDOW":  &config.NoPostWindow,
+			"ALERT_REPEAT_INTERVAL": &config.RepeatInterval,
+		} {
+			if value := os.Getenv(name); value != "" {
+				parsed, err := time.ParseDuration(value)
+				if err != nil {
+					log.Fatalf("Invalid %s: %v", name, err)
+				}
+				*duration = parsed
+			}
+		}
+		opts = append(opts, twitter.WithAlerting(alerter, config))
+	}
+
+	// Skip tweets mentioning sensitive topics
+	if topics := splitList(os.Getenv("MODERATION_SENSITIVE_TOPICS")); len(topics) > 0 {
+		opts = append(opts, twitter.WithModeration(twitter.ModerationConfig{
+			SensitiveTopics: topics,
+		}))
+	}
+
+	// Publish events to downstream systems
+	if sinks := eventSinks(log); len(sinks) > 0 {
+		opts = append(opts, twitter.WithEvents(twitter.EventsConfig{
+			Sinks:      sinks,
+			BufferSize: 256,
+			Timeout:    time.Minute,
+		}))
+	}
+
+	// Serve another platform through the HTTP connector
+	if address := os.Getenv("CONNECTOR_HTTP_ADDRESS"); address != "" {
+		var maxLength int
+		if value := os.Getenv("CONNECTOR_HTTP_MAX_LENGTH"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				log.Fatalf("Invalid CONNECTOR_HTTP_MAX_LENGTH: %v", err)
+			}
+			maxLength = parsed
+		}
+		connector, err := twitter.NewHTTPConnector(twitter.HTTPConnectorConfig{
+			Platform:  os.Getenv("CONNECTOR_HTTP_PLATFORM"),
+			Address:   address,
+			ReplyURL:  os.Getenv("CONNECTOR_HTTP_REPLY_URL"),
+			Secret:    os.Getenv("CONNECTOR_HTTP_SECRET"),
+			UserName:  os.Getenv("TWITTER_USER"),
+			MaxLength: maxLength,
+		})
+		if err != nil {
+			log.Fatalf("Invalid HTTP connector configuration: %v", err)
+		}
+		opts = append(opts, twitter.WithConnectors(twitter.ConnectorsConfig{
+			Connectors: []twitter.Connector{connector},
+			PollInterval: twitter.IntervalConfig{
+				Min: 5 * time.Second,
+				Max: 10 * time.Second,
+			},
+		}))
+	}
+
+	// Split conversations between the arms of an experiment
+	if path := os.Getenv("EXPERIMENT_FILE"); path != "" {
+		experiment, err := loadExperiment(path)
+		if err != nil {
+			log.Fatalf("Failed to load experiment: %v", err)
+		}
+		opts = append(opts, twitter.WithExperiment(experiment))
+	}
+
+	return opts
+}
+
+// eventSinks returns the event sinks configured in the environment
+func eventSinks(log *logger.Logger) []twitter.EventSink {
+	var sinks []twitter.EventSink
+	if url := os.Getenv("EVENT_WEBHOOK_URL"); url != "" {
+		sink, err := twitter.NewWebhookSink(twitter.WebhookConfig{
+			URL:        url,
+			Secret:     os.Getenv("EVENT_WEBHOOK_SECRET"),
+			MaxRetries: 5,
+			Backoff:    time.Second,
+			Timeout:    10 * time.Second,
+		})
+		if err != nil {
+			log.Fatalf("Invalid EVENT_WEBHOOK_URL: %v", err)
+		}
+		sinks = append(sinks, sink)
+	}
+	if path := os.Getenv("EVENT_FILE"); path != "" {
+		sink, err := twitter.NewFileSink(path)
+		if err != nil {
+			log.Fatalf("Failed to open EVENT_FILE: %v", err)
+		}
+		sinks = append(sinks, sink)
+	}
+	if url := os.Getenv("EVENT_REDIS_URL"); url != "" {
+		stream := os.Getenv("EVENT_REDIS_STREAM")
+		if stream == "" {
+			stream = "wrz:events"
+		}
+		sink, err := twitter.NewRedisStreamSink(url, stream, 100000)
+		if err != nil {
+			log.Fatalf("Invalid EVENT_REDIS_URL: %v", err)
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks
+}
+
+// alerterFromEnv returns an alerter delivering to the notifiers configured in the environment,
+// nil if none is configured
+func alerterFromEnv(log *logger.Logger) *twitter.Alerter {
+	var notifiers []twitter.Notifier
+	if url := os.Getenv("ALERT_WEBHOOK_URL"); url != "" {
+		notifier, err := twitter.NewWebhookNotifier(url)
+		if err != nil {
+			log.Fatalf("Invalid ALERT_WEBHOOK_URL: %v", err)
+		}
+		notifiers = append(notifiers, notifier)
+	}
+	if address := os.Getenv("ALERT_SMTP_ADDRESS"); address != "" {
+		notifier, err := twitter.NewEmailNotifier(twitter.SMTPConfig{
+			Address:  address,
+			Username: os.Getenv("ALERT_SMTP_USERNAME"),
+			Password: os.Getenv("ALERT_SMTP_PASSWORD"),
+			From:     os.Getenv("ALERT_EMAIL_FROM"),
+			To:       splitList(os.Getenv("ALERT_EMAIL_TO")),
+		})
+		if err != nil {
+			log.Fatalf("Invalid alert email configuration: %v", err)
+		}
+		notifiers = append(notifiers, notifier)
+	}
+	if os.Getenv("ALERT_STDERR") == "true" {
+		notifiers = append(notifiers, twitter.NewStderrNotifier())
+	}
+
+	if len(notifiers) == 0 {
+		return nil
+	}
+	return twitter.NewAlerter(notifiers...)
+}
+
+// setupTracing exports traces to the exporter configured in TRACING_EXPORTER,
+// returning the function that flushes them on exit
+func setupTracing(ctx context.Context, log *logger.Logger) func() {
+	config := twitter.TracingConfig{
+		Exporter:    os.Getenv("TRACING_EXPORTER"),
+		Endpoint:    os.Getenv("TRACING_ENDPOINT"),
+		SampleRatio: 1,
+	}
+	if os.Getenv("OTEL_SERVICE_NAME") == "" {
+		config.ServiceName = "wrz"
+	}
+	if value := os.Getenv("TRACING_SAMPLE_RATIO"); value != "" {
+		ratio, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			log.Fatalf("Invalid TRACING_SAMPLE_RATIO: %v", err)
+		}
+		config.SampleRatio = ratio
+	}
+
+	shutdown, err := twitter.SetupTracing(ctx, config)
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	if config.Exporter != "" {
+		log.Infof("Exporting traces to %s", config.Exporter)
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			log.Errorf("Failed to flush traces: %v", err)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"time"
+
+	"github.com/velumlabs/hana/internal/twitter"
+)
+
+// runUsage reports the token usage and cost of the LLM calls made by the agent
+func runUsage(args []string) {
+	flags := flag.NewFlagSet("usage", flag.ExitOnError)
+	since := flags.Duration("since", 7*24*time.Hour, "age of the oldest calls to report")
+	by := flags.String("by", "day", "group calls by day, user, conversation, tweet, purpose or model")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	flags.Parse(args)
+
+	log := newLogger()
+
+	database := openDatabase(log)
+	requireSchema(log, database)
+	usageStore := twitter.NewUsageStore(context.Background(), database)
+
+	report, err := usageStore.Report(time.Now().Add(-*since), *by)
+	if err != nil {
+		log.Fatalf("Failed to build report: %v", err)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+}
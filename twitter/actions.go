@@ -0,0 +1,279 @@
+package twitter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/velumlabs/thor/id"
+	"github.com/velumlabs/thor/llm"
+	"github.com/velumlabs/thor/managers/personality"
+	"github.com/velumlabs/thor/pkg/twitter"
+	"github.com/velumlabs/thor/state"
+	"gorm.io/gorm"
+)
+
+// EngagementAction is a non-reply action the agent can take on a tweet
+type EngagementAction string
+
+const (
+	EngagementActionLike    EngagementAction = "like"
+	EngagementActionRetweet EngagementAction = "retweet"
+	EngagementActionQuote   EngagementAction = "quote"
+	EngagementActionFollow  EngagementAction = "follow"
+
+	// EngagementActionDirectMessage records direct message replies for rate limiting.
+	// Message contents are never stored in the action log.
+	EngagementActionDirectMessage EngagementAction = "direct_message"
+)
+
+// ActionRecord is a persisted engagement action taken by the agent
+type ActionRecord struct {
+	ID             id.ID            `gorm:"type:uuid;primaryKey"`
+	Action         EngagementAction `gorm:"type:varchar(32);not null;index"`
+	TweetID        string           `gorm:"type:varchar(64);index"`
+	TwitterUserID  string           `gorm:"type:varchar(64);index"`
+	ConversationID string           `gorm:"type:varchar(64)"`
+	Content        string           `gorm:"type:text"`
+
+	CreatedAt time.Time `gorm:"index"`
+}
+
+// TableName returns the table engagement actions are stored in
+func (ActionRecord) TableName() string {
+	return "twitter_actions"
+}
+
+// ActionStore persists engagement actions taken by the agent
+type ActionStore struct {
+	db  *gorm.DB
+	ctx context.Context
+}
+
+// NewActionStore returns a new ActionStore initialized with the provided context and DB connection
+func NewActionStore(ctx context.Context, db *gorm.DB) *ActionStore {
+	return &ActionStore{
+		db:  db,
+		ctx: ctx,
+	}
+}
+
+// Create inserts a new ActionRecord into the database
+func (s *ActionStore) Create(record *ActionRecord) error {
+	return s.db.WithContext(s.ctx).Create(record).Error
+}
+
+// CountSince returns how many times the action was taken since the given time
+func (s *ActionStore) CountSince(action EngagementAction, since time.Time) (int64, error) {
+	var count int64
+	err := s.db.WithContext(s.ctx).
+		Model(&ActionRecord{}).
+		Where("action = ? AND created_at >= ?", action, since).
+		Count(&count).Error
+	return count, err
+}
+
+// CountSinceForUser returns how many times the action was taken towards a user since the given time
+func (s *ActionStore) CountSinceForUser(action EngagementAction, twitterUserID string, since time.Time) (int64, error) {
+	var count int64
+	err := s.db.WithContext(s.ctx).
+		Model(&ActionRecord{}).
+		Where("action = ? AND twitter_user_id = ? AND created_at >= ?", action, twitterUserID, since).
+		Count(&count).Error
+	return count, err
+}
+
+// Exists reports whether the action was already taken on the target.
+// For follows the target is the user ID, for every other action it is the tweet ID.
+func (s *ActionStore) Exists(action EngagementAction, target string) (bool, error) {
+	column := "tweet_id"
+	if action == EngagementActionFollow {
+		column = "twitter_user_id"
+	}
+
+	var count int64
+	err := s.db.WithContext(s.ctx).
+		Model(&ActionRecord{}).
+		Where("action = ? AND "+column+" = ?", action, target).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// performEngagementActions executes the secondary actions chosen by the reply decision.
+// Failures are logged so that one failed action does not block the others or the reply.
+func (k *Twitter) performEngagementActions(currentState *state.State, tweet *twitter.ParsedTweet, decision *ReplyDecision) {
+	if decision.Action == ReplyActionLike {
+		k.logEngagementError(EngagementActionLike, tweet, k.performEngagementAction(EngagementActionLike, tweet, ""))
+	}
+
+	if decision.Retweet {
+		k.logEngagementError(EngagementActionRetweet, tweet, k.performEngagementAction(EngagementActionRetweet, tweet, ""))
+	}
+
+	if decision.Quote {
+		commentary, err := k.generateQuoteCommentary(currentState, tweet)
+		if err == nil {
+			err = k.performEngagementAction(EngagementActionQuote, tweet, commentary)
+		}
+		k.logEngagementError(EngagementActionQuote, tweet, err)
+	}
+
+	if decision.FollowBack {
+		k.logEngagementError(EngagementActionFollow, tweet, k.performEngagementAction(EngagementActionFollow, tweet, ""))
+	}
+}
+
+// performEngagementAction executes a single engagement action:
+// 1. Skips actions that were already taken on the same target
+// 2. Skips actions that exceeded their rate limit
+// 3. Executes the action against Twitter
+// 4. Persists what was done
+// Returns an error if checking, executing or persisting fails.
+func (k *Twitter) performEngagementAction(action EngagementAction, tweet *twitter.ParsedTweet, content string) error {
+	target := tweet.TweetID
+	if action == EngagementActionFollow {
+		target = tweet.UserID
+	}
+
+	exists, err := k.actionStore.Exists(action, target)
+	if err != nil {
+		return fmt.Errorf("failed to check previous actions: %w", err)
+	}
+	if exists {
+		k.logger.Infof("Skipping %s on %s: already done", action, target)
+		return nil
+	}
+
+	if limit, ok := k.twitterConfig.Actions.Limits[action]; ok {
+		limited, err := k.rateLimitReached(action, "", limit)
+		if err != nil {
+			return err
+		}
+		if limited {
+			k.logger.Infof("Skipping %s on %s: rate limit of %d per %v reached", action, target, limit.Max, limit.Window)
+			return nil
+		}
+	}
+
+	if err := k.checkNotPaused(); err != nil {
+		return err
+	}
+
+	switch action {
+	case EngagementActionLike:
+		err = k.twitterClient.FavoriteTweet(tweet.TweetID)
+	case EngagementActionRetweet:
+		err = k.twitterAPI.Retweet(tweet.TweetID)
+	case EngagementActionQuote:
+		// Twitter renders a trailing status link as a quoted tweet
+		statusURL := fmt.Sprintf("https://x.com/%s/status/%s", tweet.UserName, tweet.TweetID)
+		_, err = k.twitterClient.CreateTweet(content+" "+statusURL, nil)
+	case EngagementActionFollow:
+		err = k.twitterAPI.FollowUser(tweet.UserID)
+	default:
+		return fmt.Errorf("unknown engagement action: %s", action)
+	}
+	if err != nil {
+		return err
+	}
+
+	k.logger.Infof("Performed %s on %s", action, target)
+
+	return k.actionStore.Create(&ActionRecord{
+		ID:             id.New(),
+		Action:         action,
+		TweetID:        tweet.TweetID,
+		TwitterUserID:  tweet.UserID,
+		ConversationID: tweet.TweetConversationID,
+		Content:        content,
+		CreatedAt:      time.Now(),
+	})
+}
+
+// rateLimitReached reports whether the action reached its limit within the sliding window.
+// The limit applies to a single user when twitterUserID is set and to all users otherwise.
+func (k *Twitter) rateLimitReached(action EngagementAction, twitterUserID string, limit ActionLimit) (bool, error) {
+	since := time.Now().Add(-limit.Window)
+
+	var count int64
+	var err error
+	if twitterUserID == "" {
+		count, err = k.actionStore.CountSince(action, since)
+	} else {
+		count, err = k.actionStore.CountSinceForUser(action, twitterUserID, since)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to count previous actions: %w", err)
+	}
+
+	return count >= int64(limit.Max), nil
+}
+
+// generateQuoteCommentary writes the commentary posted along with a quote tweet.
+// The commentary goes through the style rules of replies and must fit in a tweet along with the status link.
+func (k *Twitter) generateQuoteCommentary(currentState *state.State, tweet *twitter.ParsedTweet) (string, error) {
+	// The status link appended to the commentary takes a space and the weight of a link
+	maxLength := k.tweetLengthLimit() - 1 - urlWeightedLength
+
+	currentState.AddCustomData("quoted_tweet", fmt.Sprintf("@%s: %s", tweet.UserName, tweet.TweetText))
+	currentState.AddCustomData("quote_max_length", strconv.Itoa(maxLength))
+
+	messages, err := state.NewPromptBuilder(currentState).
+		AddSystemSection(`You embody this core identity:
+{{.base_personality}}
+
+Quoted tweet:
+{{.quoted_tweet}}
+
+Write a short comment to quote-tweet the tweet above to your own followers.
+1. Stay fully in character
+2. NO @ mentions
+3. NO hashtags
+4. Keep it under {{.quote_max_length}} characters
+5. Reply with the comment inside <final_answer> tags`).
+		WithManagerData(personality.BasePersonality).
+		Compose()
+	if err != nil {
+		return "", fmt.Errorf("failed to build quote prompt: %w", err)
+	}
+
+	generate := func(messages []llm.Message) (string, error) {
+		response, err := k.llmClient.GenerateCompletion(llm.CompletionRequest{
+			Messages:    messages,
+			ModelType:   llm.ModelTypeDefault,
+			Temperature: 0.7,
+		})
+		if err != nil {
+			return "", err
+		}
+		return response.Content, nil
+	}
+
+	response, err := generate(messages)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate quote commentary: %w", err)
+	}
+
+	commentary := extractTag(response, "final_answer")
+	if commentary == "" {
+		return "", fmt.Errorf("empty quote commentary")
+	}
+
+	commentary, err = k.enforceStyle(messages, response, commentary, false, generate)
+	if err != nil {
+		return "", err
+	}
+	if length := weightedLength(commentary); length > maxLength {
+		return "", fmt.Errorf("quote commentary of %d characters exceeds the limit of %d", length, maxLength)
+	}
+
+	return commentary, nil
+}
+
+// logEngagementError logs a failed engagement action
+func (k *Twitter) logEngagementError(action EngagementAction, tweet *twitter.ParsedTweet, err error) {
+	if err != nil {
+		k.logger.Errorf("Failed to perform %s for tweet %s: %v", action, tweet.TweetID, err)
+	}
+}
@@ -0,0 +1,273 @@
+package twitter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// unixAddressPrefix marks admin API addresses that are Unix socket paths
+const unixAddressPrefix = "unix:"
+
+// adminMuteRequest is the body of mute and unmute requests
+type adminMuteRequest struct {
+	Kind   MuteKind `json:"kind"`
+	Target string   `json:"target"`
+}
+
+// adminForgetRequest is the body of forget requests
+type adminForgetRequest struct {
+	User string `json:"user"` // user ID or @handle
+}
+
+// adminStatusResponse is the body of status responses
+type adminStatusResponse struct {
+	Paused bool   `json:"paused"`
+	Mutes  []Mute `json:"mutes"`
+}
+
+// listenAdmin listens on a loopback TCP address or on a Unix socket prefixed with "unix:"
+func listenAdmin(address string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(address, unixAddressPrefix); ok {
+		// Remove a socket left behind by a previous run
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+		listener, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(path, 0o600); err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("failed to restrict socket permissions: %w", err)
+		}
+		return listener, nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid admin address: %w", err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("admin API must listen on a loopback address, got %s", host)
+	}
+
+	return net.Listen("tcp", address)
+}
+
+// adminGuard rejects the requests a web page could send to the admin API:
+// - Requests carrying an Origin header, which browsers add to cross-origin requests
+// - Requests to a non-loopback Host on TCP listeners, which DNS rebinding pages send
+// - State changing requests without a JSON content type, which forms can't send
+func adminGuard(next http.Handler, checkHost bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Origin") != "" {
+			writeAdminError(w, http.StatusForbidden, fmt.Errorf("cross-origin requests are not allowed"))
+			return
+		}
+
+		if checkHost {
+			host, _, err := net.SplitHostPort(r.Host)
+			if err != nil {
+				host = r.Host
+			}
+			if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+				writeAdminError(w, http.StatusForbidden, fmt.Errorf("host %s is not allowed", r.Host))
+				return
+			}
+		}
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || mediaType != "application/json" {
+				writeAdminError(w, http.StatusUnsupportedMediaType, fmt.Errorf("content type must be application/json"))
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// startAdminAPI serves the local admin API in the background.
+// Requests other than GET must be sent with "Content-Type: application/json",
+// even when they have no body, and requests from browsers are rejected.
+//
+// Endpoints:
+//
+//	GET    /status  pause state and active mutes
+//	POST   /pause   pause the agent
+//	POST   /resume  resume the agent
+//	POST   /mutes   mute a conversation or user, body {"kind": "conversation"|"user", "target": "..."}
+//	DELETE /mutes   unmute a conversation or user, same body
+//	POST   /forget  delete all data about a user and never engage with them again, body {"user": "<id|@handle>"}
+//	GET    /metrics metrics in the Prometheus text format
+func (k *Twitter) startAdminAPI() error {
+	listener, err := listenAdmin(k.twitterConfig.Admin.Address)
+	if err != nil {
+		return fmt.Errorf("failed to start admin API: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", k.handleAdminStatus)
+	mux.HandleFunc("/pause", k.handleAdminPause)
+	mux.HandleFunc("/resume", k.handleAdminResume)
+	mux.HandleFunc("/mutes", k.handleAdminMutes)
+	mux.HandleFunc("/forget", k.handleAdminForget)
+	mux.HandleFunc("/metrics", k.handleAdminMetrics)
+
+	// Unix sockets are only reachable by local processes, whatever the Host header says
+	isUnixSocket := strings.HasPrefix(k.twitterConfig.Admin.Address, unixAddressPrefix)
+
+	k.adminServer = &http.Server{
+		Handler:           adminGuard(mux, !isUnixSocket),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		k.logger.Infof("Admin API listening on %s", k.twitterConfig.Admin.Address)
+		if err := k.adminServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			k.logger.Errorf("Admin API stopped: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// stopAdminAPI shuts the admin API down
+func (k *Twitter) stopAdminAPI() error {
+	if k.adminServer == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return k.adminServer.Shutdown(ctx)
+}
+
+func (k *Twitter) handleAdminStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAdminError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	paused, err := k.IsPaused()
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err)
+		return
+	}
+	mutes, err := k.controlStore.ListMutes()
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeAdminJSON(w, http.StatusOK, adminStatusResponse{Paused: paused, Mutes: mutes})
+}
+
+func (k *Twitter) handleAdminPause(w http.ResponseWriter, r *http.Request) {
+	k.handleAdminAction(w, r, k.Pause)
+}
+
+func (k *Twitter) handleAdminResume(w http.ResponseWriter, r *http.Request) {
+	k.handleAdminAction(w, r, k.Resume)
+}
+
+func (k *Twitter) handleAdminMutes(w http.ResponseWriter, r *http.Request) {
+	var req adminMuteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAdminError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	var err error
+	switch r.Method {
+	case http.MethodPost:
+		err = k.controlStore.Mute(req.Kind, req.Target)
+	case http.MethodDelete:
+		err = k.controlStore.Unmute(req.Kind, req.Target)
+	default:
+		writeAdminError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	k.handleAdminStatus(w, &http.Request{Method: http.MethodGet})
+}
+
+func (k *Twitter) handleAdminForget(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAdminError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	var req adminForgetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAdminError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if strings.TrimSpace(req.User) == "" {
+		writeAdminError(w, http.StatusBadRequest, fmt.Errorf("user is required"))
+		return
+	}
+
+	report, err := k.ForgetUser(req.User)
+	if errors.Is(err, ErrUnknownUser) {
+		writeAdminError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeAdminJSON(w, http.StatusOK, report)
+}
+
+func (k *Twitter) handleAdminMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAdminError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := k.metrics.WriteText(w); err != nil {
+		k.logger.Errorf("Failed to write metrics: %v", err)
+	}
+}
+
+// handleAdminAction runs a state changing action and responds with the new status
+func (k *Twitter) handleAdminAction(w http.ResponseWriter, r *http.Request, action func() error) {
+	if r.Method != http.MethodPost {
+		writeAdminError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	if err := action(); err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	k.handleAdminStatus(w, &http.Request{Method: http.MethodGet})
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeAdminError(w http.ResponseWriter, status int, err error) {
+	writeAdminJSON(w, status, map[string]string{"error": err.Error()})
+}
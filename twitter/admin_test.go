@@ -0,0 +1,52 @@
+package twitter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminGuard(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		host        string
+		origin      string
+		contentType string
+		checkHost   bool
+		want        int
+	}{
+		{name: "status", method: http.MethodGet, host: "127.0.0.1:8080", checkHost: true, want: http.StatusOK},
+		{name: "json post", method: http.MethodPost, host: "localhost:8080", contentType: "application/json; charset=utf-8", checkHost: true, want: http.StatusOK},
+		{name: "ipv6 loopback", method: http.MethodGet, host: "[::1]:8080", checkHost: true, want: http.StatusOK},
+		{name: "post without content type", method: http.MethodPost, host: "127.0.0.1:8080", checkHost: true, want: http.StatusUnsupportedMediaType},
+		{name: "form post", method: http.MethodPost, host: "127.0.0.1:8080", contentType: "application/x-www-form-urlencoded", checkHost: true, want: http.StatusUnsupportedMediaType},
+		{name: "delete as text", method: http.MethodDelete, host: "127.0.0.1:8080", contentType: "text/plain", checkHost: true, want: http.StatusUnsupportedMediaType},
+		{name: "browser origin", method: http.MethodGet, host: "127.0.0.1:8080", origin: "https://example.com", checkHost: true, want: http.StatusForbidden},
+		{name: "rebound host", method: http.MethodGet, host: "attacker.example:8080", checkHost: true, want: http.StatusForbidden},
+		{name: "any host on unix sockets", method: http.MethodGet, host: "attacker.example", want: http.StatusOK},
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/status", nil)
+			req.Host = tt.host
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			rec := httptest.NewRecorder()
+			adminGuard(ok, tt.checkHost).ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,367 @@
+package twitter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AlertCondition is an operational failure the agent alerts on
+type AlertCondition string
+
+const (
+	AlertTimelineFailures AlertCondition = "timeline_failures" // consecutive failed timeline checks
+	AlertNoRecentPost     AlertCondition = "no_recent_post"    // no reply posted while the agent was running
+	AlertLLMErrorRate     AlertCondition = "llm_error_rate"    // share of failed LLM calls
+)
+
+// alertConditions is the evaluation order of the conditions
+var alertConditions = []AlertCondition{AlertTimelineFailures, AlertNoRecentPost, AlertLLMErrorRate}
+
+// AlertStatus is whether a notification reports a failure or its recovery
+type AlertStatus string
+
+const (
+	AlertFiring   AlertStatus = "firing"
+	AlertResolved AlertStatus = "resolved"
+)
+
+// Alert is a notification about a condition
+type Alert struct {
+	Condition AlertCondition `json:"condition"`
+	Status    AlertStatus    `json:"status"`
+	Agent     string         `json:"agent"`
+	Message   string         `json:"message"`
+	Since     time.Time      `json:"since"` // when the condition started firing
+	At        time.Time      `json:"at"`
+}
+
+// Subject returns a one line summary of the alert
+func (a *Alert) Subject() string {
+	return fmt.Sprintf("[%s] @%s %s", strings.ToUpper(string(a.Status)), a.Agent, a.Condition)
+}
+
+// Text returns the alert as a short plain text message
+func (a *Alert) Text() string {
+	if a.Status == AlertResolved {
+		return fmt.Sprintf("%s: %s (failing for %v)", a.Subject(), a.Message, a.At.Sub(a.Since).Round(time.Second))
+	}
+	return fmt.Sprintf("%s: %s", a.Subject(), a.Message)
+}
+
+// Notifier delivers alerts to people
+type Notifier interface {
+	// Name identifies the notifier in logs
+	Name() string
+	// Notify delivers an alert, returning once it is accepted or the context is done
+	Notify(ctx context.Context, alert *Alert) error
+}
+
+// alertNotifyTimeout is the delivery timeout of an alert to a notifier
+const alertNotifyTimeout = 30 * time.Second
+
+// alertState is the notification state of a condition
+type alertState struct {
+	firing       bool
+	notified     bool // the firing notification was sent, so the recovery is sent too
+	since        time.Time
+	lastNotified time.Time
+	message      string
+}
+
+// llmCall is the outcome of a call to the LLM API
+type llmCall struct {
+	at  time.Time
+	err string // empty for successful calls
+}
+
+// Alerter watches for operational failures and notifies them once when they start,
+// as reminders while they last and once when they recover. It is safe for concurrent use.
+// LLM calls are observed through an HTTP transport, timeline checks and posts are recorded by the agent.
+type Alerter struct {
+	notifiers []Notifier
+
+	mu                sync.Mutex
+	timelineFailures  int
+	lastTimelineError string
+	lastPost          time.Time
+	llmCalls          []llmCall
+	llmCallsIgnored   bool
+	states            map[AlertCondition]*alertState
+	notificationsSent []time.Time
+}
+
+// NewAlerter returns an alerter delivering to the given notifiers
+func NewAlerter(notifiers ...Notifier) *Alerter {
+	return &Alerter{
+		notifiers: notifiers,
+		lastPost:  time.Now(),
+		states:    make(map[AlertCondition]*alertState),
+	}
+}
+
+// Transport wraps base so that the outcome of every OpenAI API call passing through it is recorded
+func (a *Alerter) Transport(base http.RoundTripper) http.RoundTripper {
+	return &alertTransport{base: base, alerter: a}
+}
+
+// RecordTimelineCheck records the outcome of a timeline check
+func (a *Alerter) RecordTimelineCheck(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err == nil {
+		a.timelineFailures = 0
+		return
+	}
+	a.timelineFailures++
+	a.lastTimelineError = err.Error()
+}
+
+// RecordPost records a posted reply
+func (a *Alerter) RecordPost() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lastPost = time.Now()
+}
+
+// recordLLMCall records the outcome of an LLM call, with an empty error for successful calls
+func (a *Alerter) recordLLMCall(err string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.llmCallsIgnored {
+		return
+	}
+	a.llmCalls = append(a.llmCalls, llmCall{at: time.Now(), err: err})
+}
+
+// ignoreLLMCalls stops or resumes recording LLM calls, so that offline runs don't count towards the error rate
+func (a *Alerter) ignoreLLMCalls(ignore bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.llmCallsIgnored = ignore
+}
+
+// evaluate updates the state of every condition and returns the notifications that are due.
+// Time spent paused doesn't count towards the no recent post condition.
+func (a *Alerter) evaluate(config AlertsConfig, agent string, paused bool, now time.Time) []*Alert {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var due []*Alert
+	for _, condition := range alertConditions {
+		firing, message := a.condition(condition, config, paused, now)
+
+		state, ok := a.states[condition]
+		if !ok {
+			state = &alertState{}
+			a.states[condition] = state
+		}
+
+		// Conditions without enough data keep their state
+		if firing && message == "" {
+			message = state.message
+		}
+
+		switch {
+		case firing && !state.firing:
+			state.firing, state.notified, state.since = true, false, now
+		case !firing && state.firing:
+			state.firing = false
+			if state.notified {
+				due = append(due, &Alert{Condition: condition, Status: AlertResolved, Agent: agent, Message: "recovered", Since: state.since, At: now})
+			}
+			continue
+		case !firing:
+			continue
+		}
+		state.message = message
+
+		repeat := state.notified && config.RepeatInterval > 0 && now.Sub(state.lastNotified) >= config.RepeatInterval
+		if state.notified && !repeat {
+			continue
+		}
+		if !a.allowNotification(config, now) {
+			continue
+		}
+		state.notified, state.lastNotified = true, now
+		due = append(due, &Alert{Condition: condition, Status: AlertFiring, Agent: agent, Message: message, Since: state.since, At: now})
+	}
+
+	// Recoveries are never rate limited, but they use up the budget of firing notifications
+	for _, alert := range due {
+		if alert.Status == AlertResolved {
+			a.notificationsSent = append(a.notificationsSent, now)
+		}
+	}
+	return due
+}
+
+// condition reports whether a condition is firing, and why
+func (a *Alerter) condition(condition AlertCondition, config AlertsConfig, paused bool, now time.Time) (bool, string) {
+	switch condition {
+	case AlertTimelineFailures:
+		if config.TimelineFailures <= 0 || a.timelineFailures < config.TimelineFailures {
+			return false, ""
+		}
+		return true, fmt.Sprintf("%d consecutive timeline checks failed, last error: %s", a.timelineFailures, truncateText(a.lastTimelineError, 300))
+
+	case AlertNoRecentPost:
+		if paused {
+			a.lastPost = now
+		}
+		if config.NoPostWindow <= 0 || now.Sub(a.lastPost) < config.NoPostWindow {
+			return false, ""
+		}
+		return true, fmt.Sprintf("no reply posted for %v", now.Sub(a.lastPost).Round(time.Minute))
+
+	case AlertLLMErrorRate:
+		cutoff := now.Add(-config.LLMWindow)
+		kept := a.llmCalls[:0]
+		failed, lastError := 0, ""
+		for _, call := range a.llmCalls {
+			if call.at.Before(cutoff) {
+				continue
+			}
+			kept = append(kept, call)
+			if call.err != "" {
+				failed++
+				lastError = call.err
+			}
+		}
+		a.llmCalls = kept
+
+		if config.LLMErrorRate <= 0 {
+			return false, ""
+		}
+		if len(kept) == 0 || len(kept) < config.LLMMinCalls {
+			return a.states[condition] != nil && a.states[condition].firing, ""
+		}
+		rate := float64(failed) / float64(len(kept))
+		if rate < config.LLMErrorRate {
+			return false, ""
+		}
+		return true, fmt.Sprintf("%.0f%% of LLM calls failed in the last %v (%d of %d), last error: %s",
+			rate*100, config.LLMWindow, failed, len(kept), truncateText(lastError, 300))
+	}
+	return false, ""
+}
+
+// allowNotification reports whether a firing notification fits in the hourly limit, and counts it if it does
+func (a *Alerter) allowNotification(config AlertsConfig, now time.Time) bool {
+	kept := a.notificationsSent[:0]
+	for _, sent := range a.notificationsSent {
+		if now.Sub(sent) < time.Hour {
+			kept = append(kept, sent)
+		}
+	}
+	a.notificationsSent = kept
+
+	if config.MaxPerHour > 0 && len(kept) >= config.MaxPerHour {
+		return false
+	}
+	a.notificationsSent = append(a.notificationsSent, now)
+	return true
+}
+
+// notify delivers an alert to every notifier, returning the first failure
+func (a *Alerter) notify(alert *Alert) error {
+	var firstErr error
+	for _, notifier := range a.notifiers {
+		ctx, cancel := context.WithTimeout(context.Background(), alertNotifyTimeout)
+		err := notifier.Notify(ctx, alert)
+		cancel()
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to notify %s: %w", notifier.Name(), err)
+		}
+	}
+	return firstErr
+}
+
+// alertTransport records the outcome of OpenAI API calls
+type alertTransport struct {
+	base    http.RoundTripper
+	alerter *Alerter
+}
+
+// openAIErrorResponse is the error body of failed OpenAI API calls
+type openAIErrorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+		Code    string `json:"code"`
+	} `json:"error"`
+}
+
+// RoundTrip forwards the request and records whether it succeeded.
+// The body of failed responses is read and replaced so that the caller can still decode it.
+func (t *alertTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if req.URL.Host != openAIHost {
+		return resp, err
+	}
+	if err != nil {
+		if req.Context().Err() == nil {
+			t.alerter.recordLLMCall(err.Error())
+		}
+		return resp, err
+	}
+	if resp.StatusCode < 400 {
+		t.alerter.recordLLMCall("")
+		return resp, nil
+	}
+
+	body, readErr := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	if readErr != nil {
+		return nil, readErr
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	message := resp.Status
+	var apiError openAIErrorResponse
+	if json.Unmarshal(body, &apiError) == nil && apiError.Error.Message != "" {
+		message = fmt.Sprintf("%s (%s): %s", resp.Status, apiError.Error.Code, apiError.Error.Message)
+	}
+	t.alerter.recordLLMCall(message)
+	return resp, nil
+}
+
+// monitorAlerts periodically checks the alert conditions.
+// It runs in a separate goroutine next to monitorTwitter and stops on
+// context cancellation or through the stopChan.
+func (k *Twitter) monitorAlerts() {
+	config := k.twitterConfig.Alerts
+	for {
+		select {
+		case <-time.After(config.CheckInterval):
+		case <-k.ctx.Done():
+			return
+		case <-k.stopChan:
+			return
+		}
+
+		paused, err := k.controlStore.IsPaused()
+		if err != nil {
+			k.logger.Errorf("Failed to check pause state for alerts: %v", err)
+		}
+		for _, alert := range k.alerts.evaluate(config, k.twitterConfig.Credentials.User, paused, time.Now()) {
+			k.logger.Warnf("Alert %s", alert.Text())
+			if err := k.alerts.notify(alert); err != nil {
+				k.logger.Errorf("Failed to send alert: %v", err)
+			}
+		}
+	}
+}
+
+// truncateText cuts text to at most max bytes, marking the cut with an ellipsis
+func truncateText(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+	return strings.ToValidUTF8(text[:max], "") + "..."
+}
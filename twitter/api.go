@@ -0,0 +1,254 @@
+package twitter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/google/uuid"
+)
+
+// bearerToken is the public web client token used by pkg/twitter for every request
+const bearerToken = "Bearer AAAAAAAAAAAAAAAAAAAAANRILgAAAAAAnNwIzUejRCOuH5E6I8xnZz4puTs%3D1Zv7ttfk8LF81IUq16cHjhLTvJu4FA33AGWWjCpTnA"
+
+// twitterAPITimeout bounds every request to the endpoints pkg/twitter does not cover
+const twitterAPITimeout = 30 * time.Second
+
+// twitterAPI performs authenticated requests against endpoints that the
+// pkg/twitter client does not cover. It reuses the same cookie based session
+// and mirrors the request style of pkg/twitter, through a single client
+// configured once with the session headers and cookies.
+type twitterAPI struct {
+	ctx    context.Context
+	client *resty.Client
+}
+
+// newTwitterAPI returns a twitterAPI authenticated with the given credentials
+func newTwitterAPI(ctx context.Context, credentials TwitterCredentials) *twitterAPI {
+	client := resty.New().
+		SetBaseURL("https://x.com/i/api").
+		SetTimeout(twitterAPITimeout).
+		SetHeaders(map[string]string{
+			"authorization":             bearerToken,
+			"x-csrf-token":              credentials.CT0,
+			"x-twitter-auth-type":       "OAuth2Session",
+			"x-twitter-client-language": "en",
+			"x-twitter-active-user":     "yes",
+			"user-agent":                "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36",
+			"referer":                   "https://x.com/home",
+		}).
+		SetCookies([]*http.Cookie{
+			{Name: "ct0", Value: credentials.CT0},
+			{Name: "auth_token", Value: credentials.AuthToken},
+		})
+
+	return &twitterAPI{
+		ctx:    ctx,
+		client: client,
+	}
+}
+
+// request returns a request of the shared client with the given content type
+func (a *twitterAPI) request(contentType string) *resty.Request {
+	return a.client.R().
+		SetHeader("content-type", contentType).
+		SetContext(a.ctx)
+}
+
+// Retweet retweets the tweet with the given ID
+func (a *twitterAPI) Retweet(tweetID string) error {
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"variables": map[string]interface{}{
+			"tweet_id":     tweetID,
+			"dark_request": false,
+		},
+		"queryId": "ojPdsZsimiJrUGLR1sjUtA",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	res, err := a.request("application/json").
+		SetBody(reqBody).
+		Post("/graphql/ojPdsZsimiJrUGLR1sjUtA/CreateRetweet")
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	if res.StatusCode() != 200 {
+		return fmt.Errorf("invalid status code retweeting: %s", res.String())
+	}
+
+	var response struct {
+		Data struct {
+			CreateRetweet struct {
+				RetweetResults struct {
+					Result struct {
+						RestID string `json:"rest_id"`
+					} `json:"result"`
+				} `json:"retweet_results"`
+			} `json:"create_retweet"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(res.Body(), &response); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	if response.Data.CreateRetweet.RetweetResults.Result.RestID == "" {
+		return fmt.Errorf("failed to retweet: %s", res.String())
+	}
+
+	return nil
+}
+
+// FollowUser follows the user with the given ID
+func (a *twitterAPI) FollowUser(userID string) error {
+	res, err := a.request("application/x-www-form-urlencoded").
+		SetFormData(map[string]string{
+			"user_id":                           userID,
+			"include_profile_interstitial_type": "1",
+			"skip_status":                       "true",
+		}).
+		Post("/1.1/friendships/create.json")
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	if res.StatusCode() != 200 {
+		return fmt.Errorf("invalid status code following user: %s", res.String())
+	}
+
+	return nil
+}
+
+// inboxResponse is the subset of the direct message inbox used by the agent
+type inboxResponse struct {
+	InboxInitialState struct {
+		Entries []struct {
+			Message *struct {
+				ID             string `json:"id"`
+				Time           string `json:"time"`
+				ConversationID string `json:"conversation_id"`
+				MessageData    struct {
+					ID          string `json:"id"`
+					Time        string `json:"time"`
+					RecipientID string `json:"recipient_id"`
+					SenderID    string `json:"sender_id"`
+					Text        string `json:"text"`
+				} `json:"message_data"`
+			} `json:"message,omitempty"`
+		} `json:"entries"`
+		Users map[string]struct {
+			IDStr      string `json:"id_str"`
+			Name       string `json:"name"`
+			ScreenName string `json:"screen_name"`
+		} `json:"users"`
+	} `json:"inbox_initial_state"`
+}
+
+// directMessage is a parsed direct message received or sent by the agent
+type directMessage struct {
+	MessageID      string
+	ConversationID string
+	SenderID       string
+	SenderName     string
+	DisplayName    string
+	Text           string
+	CreatedAt      int64 // unix seconds
+}
+
+// FetchInbox retrieves the most recent direct messages of every conversation in the inbox.
+// Returns the messages in chronological order along with the screen names of the participants
+// keyed by user ID.
+func (a *twitterAPI) FetchInbox() ([]directMessage, map[string]string, error) {
+	res, err := a.request("application/json").
+		SetQueryParams(map[string]string{
+			"nsfw_filtering_enabled":            "false",
+			"include_profile_interstitial_type": "1",
+			"include_groups":                    "true",
+			"dm_users":                          "true",
+			"include_conversation_info":         "true",
+			"supports_reactions":                "true",
+			"include_ext_edit_control":          "true",
+			"dm_secret_conversations_enabled":   "false",
+		}).
+		Get("/1.1/dm/inbox_initial_state.json")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if res.StatusCode() != 200 {
+		return nil, nil, fmt.Errorf("invalid status code fetching inbox: %s", res.String())
+	}
+
+	var response inboxResponse
+	if err := json.Unmarshal(res.Body(), &response); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	users := make(map[string]string, len(response.InboxInitialState.Users))
+	displayNames := make(map[string]string, len(response.InboxInitialState.Users))
+	for userID, user := range response.InboxInitialState.Users {
+		users[userID] = user.ScreenName
+		displayNames[userID] = user.Name
+	}
+
+	var messages []directMessage
+	for _, entry := range response.InboxInitialState.Entries {
+		if entry.Message == nil || entry.Message.MessageData.Text == "" {
+			continue
+		}
+
+		// Message times are unix milliseconds
+		createdAt, err := strconv.ParseInt(entry.Message.MessageData.Time, 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse message time: %w", err)
+		}
+
+		messages = append(messages, directMessage{
+			MessageID:      entry.Message.MessageData.ID,
+			ConversationID: entry.Message.ConversationID,
+			SenderID:       entry.Message.MessageData.SenderID,
+			SenderName:     users[entry.Message.MessageData.SenderID],
+			DisplayName:    displayNames[entry.Message.MessageData.SenderID],
+			Text:           entry.Message.MessageData.Text,
+			CreatedAt:      createdAt / 1000,
+		})
+	}
+
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].CreatedAt < messages[j].CreatedAt
+	})
+
+	return messages, users, nil
+}
+
+// SendDirectMessage sends a direct message to an existing conversation
+func (a *twitterAPI) SendDirectMessage(conversationID string, text string) error {
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"conversation_id":     conversationID,
+		"recipient_ids":       false,
+		"request_id":          uuid.NewString(),
+		"text":                text,
+		"cards_platform":      "Web-12",
+		"include_cards":       1,
+		"include_quote_count": true,
+		"dm_users":            false,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	res, err := a.request("application/json").
+		SetBody(reqBody).
+		Post("/1.1/dm/new2.json")
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	if res.StatusCode() != 200 {
+		return fmt.Errorf("invalid status code sending direct message: %s", res.String())
+	}
+
+	return nil
+}
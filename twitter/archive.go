@@ -0,0 +1,534 @@
+package twitter
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pgvector/pgvector-go"
+	"github.com/velumlabs/thor/db"
+	"github.com/velumlabs/thor/id"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ArchiveFormatVersion is the version of the memory archive layout written by ExportMemory
+const ArchiveFormatVersion = 1
+
+// Files of a memory archive, in the order they are written and imported.
+// Fragment tables are stored as fragments/<table>.jsonl.
+const (
+	archiveManifestFile = "manifest.json"
+	archiveActorsFile   = "actors.jsonl"
+	archiveSessionsFile = "sessions.jsonl"
+)
+
+// archiveBatchSize is the number of rows read and written at once
+const archiveBatchSize = 500
+
+// ArchiveManifest describes the content of a memory archive
+type ArchiveManifest struct {
+	FormatVersion       int              `json:"format_version"`
+	SchemaVersion       int              `json:"schema_version"` // last migration applied to the exported database
+	EmbeddingModel      string           `json:"embedding_model"`
+	EmbeddingDimensions int              `json:"embedding_dimensions"`
+	CreatedAt           time.Time        `json:"created_at"`
+	Files               map[string]int64 `json:"files"` // rows of each JSONL file
+}
+
+// archiveActor is an actor as stored in a memory archive
+type archiveActor struct {
+	ID        id.ID      `json:"id"`
+	Name      string     `json:"name"`
+	Assistant bool       `json:"assistant"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// archiveSession is a session as stored in a memory archive
+type archiveSession struct {
+	ID        id.ID      `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// archiveFragment is a fragment as stored in a memory archive
+type archiveFragment struct {
+	ID        id.ID       `json:"id"`
+	ActorID   id.ID       `json:"actor_id"`
+	SessionID id.ID       `json:"session_id"`
+	Content   string      `json:"content"`
+	Metadata  db.Metadata `json:"metadata"`
+	Embedding []float32   `json:"embedding,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	DeletedAt *time.Time  `json:"deleted_at,omitempty"`
+}
+
+// archiveFragmentFile returns the archive file of a fragment table
+func archiveFragmentFile(table db.FragmentTable) string {
+	return "fragments/" + string(table) + ".jsonl"
+}
+
+// deletedAt returns the deletion time of a soft deleted row, nil if it isn't deleted
+func deletedAt(deleted gorm.DeletedAt) *time.Time {
+	if !deleted.Valid {
+		return nil
+	}
+	return &deleted.Time
+}
+
+// softDeleted returns the gorm value of an archived deletion time
+func softDeleted(deleted *time.Time) gorm.DeletedAt {
+	if deleted == nil {
+		return gorm.DeletedAt{}
+	}
+	return gorm.DeletedAt{Time: *deleted, Valid: true}
+}
+
+// ExportMemory writes the actors, sessions and fragments of the four fragment stores to w
+// as a gzipped tar archive of JSONL files, soft deleted rows included.
+// The manifest comes first and records the schema version and the embedding model of the fragments.
+func ExportMemory(ctx context.Context, database *gorm.DB, w io.Writer, embeddingModel string, embeddingDimensions int) (*ArchiveManifest, error) {
+	if err := NewMigrator(ctx, database).CheckCurrent(); err != nil {
+		return nil, err
+	}
+	schemaVersion, err := NewMigrator(ctx, database).Version()
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &ArchiveManifest{
+		FormatVersion:       ArchiveFormatVersion,
+		SchemaVersion:       schemaVersion,
+		EmbeddingModel:      embeddingModel,
+		EmbeddingDimensions: embeddingDimensions,
+		CreatedAt:           time.Now().UTC(),
+		Files:               make(map[string]int64),
+	}
+
+	// The tar header of each file needs its size, so files are spooled to disk first
+	var files []string
+	spooled := make(map[string]*os.File)
+	defer func() {
+		for _, file := range spooled {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+	spool := func(name string, export func(encoder *json.Encoder) (int64, error)) error {
+		file, err := os.CreateTemp("", "wrz-export-*.jsonl")
+		if err != nil {
+			return err
+		}
+		spooled[name] = file
+
+		buffered := bufio.NewWriter(file)
+		rows, err := export(json.NewEncoder(buffered))
+		if err != nil {
+			return fmt.Errorf("failed to export %s: %w", name, err)
+		}
+		if err := buffered.Flush(); err != nil {
+			return err
+		}
+		files = append(files, name)
+		manifest.Files[name] = rows
+		return nil
+	}
+
+	tx := database.WithContext(ctx).Unscoped()
+
+	if err := spool(archiveActorsFile, func(encoder *json.Encoder) (int64, error) {
+		var batch []db.Actor
+		var rows int64
+		result := tx.Order("id").FindInBatches(&batch, archiveBatchSize, func(*gorm.DB, int) error {
+			for _, actor := range batch {
+				if err := encoder.Encode(archiveActor{
+					ID:        actor.ID,
+					Name:      actor.Name,
+					Assistant: actor.Assistant,
+					CreatedAt: actor.CreatedAt,
+					UpdatedAt: actor.UpdatedAt,
+					DeletedAt: deletedAt(actor.DeletedAt),
+				}); err != nil {
+					return err
+				}
+				rows++
+			}
+			return nil
+		})
+		return rows, result.Error
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := spool(archiveSessionsFile, func(encoder *json.Encoder) (int64, error) {
+		var batch []db.Session
+		var rows int64
+		result := tx.Order("id").FindInBatches(&batch, archiveBatchSize, func(*gorm.DB, int) error {
+			for _, session := range batch {
+				if err := encoder.Encode(archiveSession{
+					ID:        session.ID,
+					CreatedAt: session.CreatedAt,
+					UpdatedAt: session.UpdatedAt,
+					DeletedAt: deletedAt(session.DeletedAt),
+				}); err != nil {
+					return err
+				}
+				rows++
+			}
+			return nil
+		})
+		return rows, result.Error
+	}); err != nil {
+		return nil, err
+	}
+
+	for _, table := range fragmentTables {
+		if err := spool(archiveFragmentFile(table), func(encoder *json.Encoder) (int64, error) {
+			var batch []db.Fragment
+			var rows int64
+			result := tx.Table(string(table)).Order("id").FindInBatches(&batch, archiveBatchSize, func(*gorm.DB, int) error {
+				for _, fragment := range batch {
+					if err := encoder.Encode(archiveFragment{
+						ID:        fragment.ID,
+						ActorID:   fragment.ActorID,
+						SessionID: fragment.SessionID,
+						Content:   fragment.Content,
+						Metadata:  fragment.Metadata,
+						Embedding: fragment.Embedding.Slice(),
+						CreatedAt: fragment.CreatedAt,
+						UpdatedAt: fragment.UpdatedAt,
+						DeletedAt: deletedAt(fragment.DeletedAt),
+					}); err != nil {
+						return err
+					}
+					rows++
+				}
+				return nil
+			})
+			return rows, result.Error
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	gz := gzip.NewWriter(w)
+	archive := tar.NewWriter(gz)
+
+	encoded, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeArchiveFile(archive, archiveManifestFile, int64(len(encoded)), manifest.CreatedAt, bytes.NewReader(encoded)); err != nil {
+		return nil, err
+	}
+
+	for _, name := range files {
+		file := spooled[name]
+		info, err := file.Stat()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if err := writeArchiveFile(archive, name, info.Size(), manifest.CreatedAt, file); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// writeArchiveFile adds a regular file to a tar archive
+func writeArchiveFile(archive *tar.Writer, name string, size int64, modTime time.Time, content io.Reader) error {
+	if err := archive.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0o644,
+		Size:     size,
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return fmt.Errorf("failed to write %s header: %w", name, err)
+	}
+	if _, err := io.Copy(archive, content); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// ImportConflict is how rows whose ID already exists in the target database are imported
+type ImportConflict string
+
+const (
+	ImportConflictSkip      ImportConflict = "skip"      // keep the existing row
+	ImportConflictOverwrite ImportConflict = "overwrite" // replace the existing row with the archived one
+	ImportConflictFail      ImportConflict = "fail"      // abort the import, nothing is imported
+)
+
+// ImportOptions controls how a memory archive is imported
+type ImportOptions struct {
+	Conflict ImportConflict
+
+	// Embedding model of the target agent. Fragments of archives made with another model
+	// or dimensions are re-embedded with Embed, and refused if Embed is nil.
+	EmbeddingModel      string
+	EmbeddingDimensions int
+	Embed               func(text string) ([]float32, error)
+}
+
+// ImportFileReport counts the rows imported from an archive file
+type ImportFileReport struct {
+	File       string `json:"file"`
+	Rows       int64  `json:"rows"`
+	Written    int64  `json:"written"` // inserted, or overwritten with ImportConflictOverwrite
+	Skipped    int64  `json:"skipped"` // existing rows kept with ImportConflictSkip
+	Dropped    int64  `json:"dropped"` // rows of users on the do-not-engage list
+	Reembedded int64  `json:"reembedded"`
+}
+
+// ImportReport summarizes an import
+type ImportReport struct {
+	Manifest ArchiveManifest    `json:"manifest"`
+	Reembed  bool               `json:"reembed"`
+	Files    []ImportFileReport `json:"files"`
+}
+
+// ImportMemory reads an archive written by ExportMemory into the database:
+// 1. Checks the manifest against the schema and embedding model of the target
+// 2. Imports actors, then sessions, then fragments, resolving ID conflicts with opts.Conflict
+// 3. Drops the actors and fragments of users on the do-not-engage list of the target
+// 4. Re-embeds fragment contents when the embedding model or dimensions differ
+// Everything happens in one transaction, so a failed import leaves the database unchanged.
+func ImportMemory(ctx context.Context, database *gorm.DB, r io.Reader, opts ImportOptions) (*ImportReport, error) {
+	switch opts.Conflict {
+	case ImportConflictSkip, ImportConflictOverwrite, ImportConflictFail:
+	default:
+		return nil, fmt.Errorf("invalid import conflict mode: %q", opts.Conflict)
+	}
+	if err := NewMigrator(ctx, database).CheckCurrent(); err != nil {
+		return nil, err
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	defer gz.Close()
+	archive := tar.NewReader(gz)
+
+	header, err := archive.Next()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	if header.Name != archiveManifestFile {
+		return nil, fmt.Errorf("archive must start with %s, found %s", archiveManifestFile, header.Name)
+	}
+
+	report := &ImportReport{}
+	if err := json.NewDecoder(archive).Decode(&report.Manifest); err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	manifest := report.Manifest
+	if manifest.FormatVersion != ArchiveFormatVersion {
+		return nil, fmt.Errorf("unsupported archive format version %d", manifest.FormatVersion)
+	}
+	if manifest.SchemaVersion > LatestSchemaVersion() {
+		return nil, fmt.Errorf("archive schema version %d is newer than this build's %d", manifest.SchemaVersion, LatestSchemaVersion())
+	}
+
+	report.Reembed = manifest.EmbeddingModel != opts.EmbeddingModel || manifest.EmbeddingDimensions != opts.EmbeddingDimensions
+	if report.Reembed && opts.Embed == nil {
+		return nil, fmt.Errorf("archive embeddings use %s with %d dimensions, re-embedding is required",
+			manifest.EmbeddingModel, manifest.EmbeddingDimensions)
+	}
+
+	fragmentFiles := make(map[string]db.FragmentTable)
+	for _, table := range fragmentTables {
+		fragmentFiles[archiveFragmentFile(table)] = table
+	}
+
+	err = database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		blocked, err := doNotEngageActors(tx)
+		if err != nil {
+			return err
+		}
+
+		for {
+			header, err := archive.Next()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read archive: %w", err)
+			}
+
+			file := ImportFileReport{File: header.Name}
+			switch {
+			case header.Name == archiveActorsFile:
+				err = importRows(archive, &file, func(batch []archiveActor) error {
+					actors := make([]db.Actor, 0, len(batch))
+					for _, actor := range batch {
+						if blocked[actor.ID] {
+							file.Dropped++
+							continue
+						}
+						actors = append(actors, db.Actor{
+							ID:        actor.ID,
+							Name:      actor.Name,
+							Assistant: actor.Assistant,
+							CreatedAt: actor.CreatedAt,
+							UpdatedAt: actor.UpdatedAt,
+							DeletedAt: softDeleted(actor.DeletedAt),
+						})
+					}
+					return writeImportBatch(tx, opts.Conflict, &file, &actors, len(actors))
+				})
+			case header.Name == archiveSessionsFile:
+				err = importRows(archive, &file, func(batch []archiveSession) error {
+					sessions := make([]db.Session, len(batch))
+					for i, session := range batch {
+						sessions[i] = db.Session{
+							ID:        session.ID,
+							CreatedAt: session.CreatedAt,
+							UpdatedAt: session.UpdatedAt,
+							DeletedAt: softDeleted(session.DeletedAt),
+						}
+					}
+					return writeImportBatch(tx, opts.Conflict, &file, &sessions, len(sessions))
+				})
+			case fragmentFiles[header.Name] != "":
+				table := fragmentFiles[header.Name]
+				err = importRows(archive, &file, func(batch []archiveFragment) error {
+					fragments := make([]db.Fragment, 0, len(batch))
+					for _, fragment := range batch {
+						if blocked[fragment.ActorID] {
+							file.Dropped++
+							continue
+						}
+						embedding := fragment.Embedding
+						if report.Reembed && fragment.Content != "" {
+							reembedded, err := opts.Embed(fragment.Content)
+							if err != nil {
+								return fmt.Errorf("failed to re-embed fragment %s: %w", fragment.ID, err)
+							}
+							embedding = reembedded
+							file.Reembedded++
+						}
+						fragments = append(fragments, db.Fragment{
+							ID:        fragment.ID,
+							ActorID:   fragment.ActorID,
+							SessionID: fragment.SessionID,
+							Content:   fragment.Content,
+							Metadata:  fragment.Metadata,
+							Embedding: pgvector.NewVector(embedding),
+							CreatedAt: fragment.CreatedAt,
+							UpdatedAt: fragment.UpdatedAt,
+							DeletedAt: softDeleted(fragment.DeletedAt),
+						})
+					}
+					return writeImportBatch(tx.Table(string(table)), opts.Conflict, &file, &fragments, len(fragments))
+				})
+			default:
+				return fmt.Errorf("unknown archive file %s", header.Name)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to import %s: %w", header.Name, err)
+			}
+			if expected, ok := manifest.Files[header.Name]; ok && expected != file.Rows {
+				return fmt.Errorf("%s has %d rows, the manifest lists %d", header.Name, file.Rows, expected)
+			}
+			report.Files = append(report.Files, file)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// importRows decodes a JSONL file in batches and passes each batch to write
+func importRows[T any](r io.Reader, file *ImportFileReport, write func(batch []T) error) error {
+	decoder := json.NewDecoder(r)
+	batch := make([]T, 0, archiveBatchSize)
+	for {
+		var row T
+		err := decoder.Decode(&row)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid row %d: %w", file.Rows+1, err)
+		}
+		batch = append(batch, row)
+		file.Rows++
+
+		if len(batch) == archiveBatchSize {
+			if err := write(batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	return write(batch)
+}
+
+// doNotEngageActors returns the actor IDs of the users on the do-not-engage list
+func doNotEngageActors(tx *gorm.DB) (map[id.ID]bool, error) {
+	var twitterUserIDs []string
+	if err := tx.Model(&DoNotEngage{}).Pluck("twitter_user_id", &twitterUserIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to read do-not-engage list: %w", err)
+	}
+
+	actors := make(map[id.ID]bool, len(twitterUserIDs))
+	for _, twitterUserID := range twitterUserIDs {
+		actors[id.FromString(twitterUserID)] = true
+	}
+	return actors, nil
+}
+
+// writeImportBatch inserts a batch of rows, resolving ID conflicts with the conflict mode
+func writeImportBatch(tx *gorm.DB, conflict ImportConflict, file *ImportFileReport, rows interface{}, count int) error {
+	if count == 0 {
+		return nil
+	}
+
+	query := tx
+	switch conflict {
+	case ImportConflictSkip:
+		query = tx.Clauses(clause.OnConflict{DoNothing: true})
+	case ImportConflictOverwrite:
+		query = tx.Clauses(clause.OnConflict{UpdateAll: true})
+	}
+
+	result := query.Create(rows)
+	if result.Error != nil {
+		return result.Error
+	}
+	file.Written += result.RowsAffected
+	if conflict == ImportConflictSkip {
+		file.Skipped += int64(count) - result.RowsAffected
+	}
+	return nil
+}
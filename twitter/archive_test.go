@@ -0,0 +1,90 @@
+package twitter
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pgvector/pgvector-go"
+	"github.com/velumlabs/thor/db"
+	"github.com/velumlabs/thor/id"
+	"github.com/velumlabs/thor/stores"
+)
+
+func TestImportMemoryDropsDoNotEngageUsers(t *testing.T) {
+	ctx := context.Background()
+	source := newTestDatabase(t)
+
+	session := &db.Session{ID: id.New()}
+	if err := stores.NewSessionStore(ctx, source).Create(session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	fragmentStore := stores.NewFragmentStore(ctx, source, db.FragmentTableInteraction)
+	for _, twitterUserID := range []string{"42", "43"} {
+		actor := &db.Actor{ID: id.FromString(twitterUserID), Name: twitterUserID}
+		if err := stores.NewActorStore(ctx, source).Create(actor); err != nil {
+			t.Fatalf("failed to create actor: %v", err)
+		}
+		if err := fragmentStore.Create(&db.Fragment{
+			ID:        id.New(),
+			ActorID:   actor.ID,
+			SessionID: session.ID,
+			Content:   "hello from " + twitterUserID,
+			Metadata:  db.Metadata{"user_id": twitterUserID},
+			Embedding: pgvector.NewVector([]float32{1, 0}),
+		}); err != nil {
+			t.Fatalf("failed to create fragment: %v", err)
+		}
+	}
+
+	var archive bytes.Buffer
+	if _, err := ExportMemory(ctx, source, &archive, "model", 2); err != nil {
+		t.Fatalf("ExportMemory() error = %v", err)
+	}
+
+	target := newTestDatabase(t)
+	if err := NewDoNotEngageStore(ctx, target).Upsert(&DoNotEngage{TwitterUserID: "42", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("failed to add to the do-not-engage list: %v", err)
+	}
+
+	report, err := ImportMemory(ctx, target, &archive, ImportOptions{
+		Conflict:            ImportConflictFail,
+		EmbeddingModel:      "model",
+		EmbeddingDimensions: 2,
+	})
+	if err != nil {
+		t.Fatalf("ImportMemory() error = %v", err)
+	}
+
+	want := map[string]ImportFileReport{
+		archiveActorsFile:   {Rows: 2, Written: 1, Dropped: 1},
+		archiveSessionsFile: {Rows: 1, Written: 1},
+		archiveFragmentFile(db.FragmentTableInteraction): {Rows: 2, Written: 1, Dropped: 1},
+	}
+	for _, file := range report.Files {
+		expected, ok := want[file.File]
+		if !ok {
+			continue
+		}
+		expected.File = file.File
+		if file != expected {
+			t.Errorf("report of %s = %+v, want %+v", file.File, file, expected)
+		}
+	}
+
+	var actors []db.Actor
+	if err := target.Find(&actors).Error; err != nil {
+		t.Fatalf("failed to list actors: %v", err)
+	}
+	if len(actors) != 1 || actors[0].ID != id.FromString("43") {
+		t.Errorf("imported actors = %v, want only 43", actors)
+	}
+	fragments, err := stores.NewFragmentStore(ctx, target, db.FragmentTableInteraction).GetBySession(session.ID, 10)
+	if err != nil {
+		t.Fatalf("failed to list fragments: %v", err)
+	}
+	if len(fragments) != 1 || fragments[0].ActorID != id.FromString("43") {
+		t.Errorf("imported fragments = %v, want only the fragment of 43", fragments)
+	}
+}
@@ -0,0 +1,295 @@
+package twitter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/velumlabs/thor/db"
+	"github.com/velumlabs/thor/id"
+	"github.com/velumlabs/thor/logger"
+	"github.com/velumlabs/thor/pkg/twitter"
+	"gorm.io/gorm"
+)
+
+// AuditEventType is the kind of a decision recorded in the audit log
+type AuditEventType string
+
+const (
+	AuditTweetFetched   AuditEventType = "tweet_fetched"
+	AuditTweetSkipped   AuditEventType = "tweet_skipped"   // details: reason, and why for decisions
+	AuditReplyGenerated AuditEventType = "reply_generated" // details: prompt_version, model, latency_ms
+	AuditReplyPosted    AuditEventType = "reply_posted"    // details: tweet_id of the reply, fragment_id
+	AuditReplyFailed    AuditEventType = "reply_failed"    // details: stage, error
+)
+
+// Reasons of skipped tweets
+const (
+	SkipReasonTooOld      = "too_old"
+	SkipReasonDoNotEngage = "do_not_engage"
+	SkipReasonMuted       = "muted"
+	SkipReasonDuplicate   = "duplicate"
+	SkipReasonNotSelected = "not_selected" // below the minimum score or beyond the reply budget
+	SkipReasonDecision    = "decision"     // the reply decision chose to like or ignore
+	SkipReasonPaused      = "paused"
+	SkipReasonBudget      = "budget"
+)
+
+// Stages of the reply pipeline where a reply can fail
+const (
+	ReplyStagePrepare  = "prepare"
+	ReplyStageDecide   = "decide"
+	ReplyStageGenerate = "generate"
+	ReplyStagePost     = "post"
+)
+
+// AuditEvent is a decision of the agent about a tweet.
+// Events are append-only and never contain tweet texts, users are referred to by ID and handle.
+type AuditEvent struct {
+	ID             id.ID          `gorm:"type:uuid;primaryKey" json:"id"`
+	Type           AuditEventType `gorm:"type:varchar(32);not null;index" json:"type"`
+	TweetID        string         `gorm:"type:varchar(64);index" json:"tweet_id,omitempty"`
+	ConversationID string         `gorm:"type:varchar(64);index" json:"conversation_id,omitempty"`
+	UserID         string         `gorm:"type:varchar(64);index" json:"user_id,omitempty"`
+	UserName       string         `gorm:"type:varchar(255)" json:"user_name,omitempty"`
+	Details        db.Metadata    `gorm:"type:jsonb" json:"details,omitempty"`
+	CreatedAt      time.Time      `gorm:"index" json:"created_at"`
+}
+
+// TableName returns the table audit events are stored in
+func (AuditEvent) TableName() string {
+	return "audit_events"
+}
+
+// AuditFilter selects audit events. Empty fields match every event.
+type AuditFilter struct {
+	TweetID        string
+	ConversationID string
+	UserID         string
+	UserName       string // matched case insensitively, without the @
+	Types          []AuditEventType
+	Since          time.Time
+	Limit          int // most recent events returned, zero for all
+}
+
+// AuditStore persists audit events. It has no update or delete, only ForgetUser removes events.
+type AuditStore struct {
+	db  *gorm.DB
+	ctx context.Context
+}
+
+// NewAuditStore returns a new AuditStore initialized with the provided context and DB connection
+func NewAuditStore(ctx context.Context, db *gorm.DB) *AuditStore {
+	return &AuditStore{
+		db:  db,
+		ctx: ctx,
+	}
+}
+
+// Append stores an event
+func (s *AuditStore) Append(event *AuditEvent) error {
+	return s.db.WithContext(s.ctx).Create(event).Error
+}
+
+// Query returns the events matching the filter, oldest first
+func (s *AuditStore) Query(filter AuditFilter) ([]AuditEvent, error) {
+	query := s.db.WithContext(s.ctx).Model(&AuditEvent{})
+	if filter.TweetID != "" {
+		query = query.Where("tweet_id = ?", filter.TweetID)
+	}
+	if filter.ConversationID != "" {
+		query = query.Where("conversation_id = ?", filter.ConversationID)
+	}
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.UserName != "" {
+		query = query.Where("LOWER(user_name) = ?", strings.ToLower(strings.TrimPrefix(filter.UserName, "@")))
+	}
+	if len(filter.Types) > 0 {
+		query = query.Where("type IN ?", filter.Types)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var events []AuditEvent
+	if err := query.Order("created_at DESC").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].CreatedAt.Before(events[j].CreatedAt)
+	})
+	return events, nil
+}
+
+// AuditHistory is a list of audit events, oldest first
+type AuditHistory struct {
+	Events []AuditEvent `json:"events"`
+}
+
+// WriteText writes the events as an aligned text table
+func (h *AuditHistory) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tEVENT\tTWEET\tCONVERSATION\tUSER\tDETAILS")
+	for _, event := range h.Events {
+		user := "-"
+		if event.UserName != "" {
+			user = "@" + event.UserName
+		} else if event.UserID != "" {
+			user = event.UserID
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			event.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+			event.Type,
+			orDash(event.TweetID),
+			orDash(event.ConversationID),
+			user,
+			formatAuditDetails(event.Details),
+		)
+	}
+	return tw.Flush()
+}
+
+// formatAuditDetails formats event details as sorted key=value pairs
+func formatAuditDetails(details db.Metadata) string {
+	if len(details) == 0 {
+		return "-"
+	}
+	keys := make([]string, 0, len(details))
+	for key := range details {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = fmt.Sprintf("%s=%v", key, details[key])
+	}
+	return singleLine(strings.Join(pairs, " "))
+}
+
+// orDash returns s, or "-" if it is empty
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// AuditLog appends audit events to the audit_events table, and to a JSONL file if one is configured.
+// ForgetUser can't clean the file, so its events leave out the user ID and handle.
+// Write failures are logged and never interrupt the agent. It is safe for concurrent use.
+type AuditLog struct {
+	store  *AuditStore
+	logger *logger.Logger
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewAuditLog returns an audit log writing to the store and, unless path is empty, appending to the file at path
+func NewAuditLog(store *AuditStore, logger *logger.Logger, path string) (*AuditLog, error) {
+	log := &AuditLog{
+		store:  store,
+		logger: logger,
+	}
+	if path != "" {
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o640)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit log file: %w", err)
+		}
+		log.file = file
+	}
+	return log, nil
+}
+
+// Append records an event
+func (l *AuditLog) Append(event *AuditEvent) {
+	if err := l.store.Append(event); err != nil {
+		l.logger.Errorf("Failed to store %s audit event: %v", event.Type, err)
+	}
+	if l.file == nil {
+		return
+	}
+
+	redacted := *event
+	redacted.UserID, redacted.UserName = "", ""
+	encoded, err := json.Marshal(&redacted)
+	if err != nil {
+		l.logger.Errorf("Failed to encode %s audit event: %v", event.Type, err)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.file.Write(append(encoded, '\n')); err != nil {
+		l.logger.Errorf("Failed to write %s audit event: %v", event.Type, err)
+	}
+}
+
+// Close closes the JSONL file
+func (l *AuditLog) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// audit records an event about a tweet. Replays and agents without an audit log record nothing.
+func (k *Twitter) audit(eventType AuditEventType, tweet *twitter.ParsedTweet, details db.Metadata) {
+	if k.auditLog == nil || k.replay != nil || k.evaluating {
+		return
+	}
+	k.auditLog.Append(&AuditEvent{
+		ID:             id.New(),
+		Type:           eventType,
+		TweetID:        tweet.TweetID,
+		ConversationID: tweet.TweetConversationID,
+		UserID:         tweet.UserID,
+		UserName:       tweet.UserName,
+		Details:        details,
+		CreatedAt:      time.Now(),
+	})
+}
+
+// auditSkipped records why a tweet was skipped
+func (k *Twitter) auditSkipped(tweet *twitter.ParsedTweet, reason string, details db.Metadata) {
+	if details == nil {
+		details = db.Metadata{}
+	}
+	details["reason"] = reason
+	k.audit(AuditTweetSkipped, tweet, details)
+}
+
+// auditTweetOutcome records how processing a tweet ended when it didn't produce a reply:
+// interruptions and duplicates are skips, anything else is a failure of the stage it happened in
+func (k *Twitter) auditTweetOutcome(tweet *twitter.ParsedTweet, stage string, err error) {
+	switch {
+	case err == nil:
+	case errors.Is(err, errPaused):
+		k.auditSkipped(tweet, SkipReasonPaused, nil)
+	case errors.Is(err, errBudgetExceeded):
+		k.auditSkipped(tweet, SkipReasonBudget, nil)
+	case strings.Contains(err.Error(), "fragment exists"):
+		k.auditSkipped(tweet, SkipReasonDuplicate, nil)
+	default:
+		k.audit(AuditReplyFailed, tweet, db.Metadata{
+			"stage": stage,
+			"error": err.Error(),
+		})
+	}
+}
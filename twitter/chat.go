@@ -0,0 +1,163 @@
+package twitter
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/velumlabs/thor/db"
+	"github.com/velumlabs/thor/id"
+	"github.com/velumlabs/thor/llm"
+	"github.com/velumlabs/thor/pkg/twitter"
+)
+
+// platformLocal is the platform custom data value of chat messages.
+// The twitter manager only acts on the "twitter" platform, so chat messages
+// never fetch threads from or post to Twitter.
+const platformLocal = "local"
+
+// chatIDPrefix marks the IDs of chat users, sessions and messages
+const chatIDPrefix = "chat:"
+
+// ChatReply is the agent's answer to a chat message
+type ChatReply struct {
+	Reasoning string `json:"reasoning"` // content of the contemplator tag
+	Answer    string `json:"answer"`    // content of the final answer tag, after style enforcement
+}
+
+// ChatSession is a local conversation with the persona. Messages go through the same pipeline as tweets,
+// as synthetic tweets of a local user, without a reply decision, posting or profile updates.
+// Sessions are deleted on Reset and Close so that they never show up as Twitter history.
+// A session must not be used concurrently, nor at the same time as Replay.
+type ChatSession struct {
+	k        *Twitter
+	userID   string
+	userName string
+
+	conversationID string
+	lastTweetID    string // ID of the agent's last reply, which the next message replies to
+	sessionIDs     []id.ID
+
+	// prompt and reasoning are captured by generateTweetResponse
+	prompt    []llm.Message
+	reasoning string
+}
+
+// NewChatSession starts a chat as the given user name with an agent created WithLocalMode.
+// Returns an error if the agent is not in local mode.
+func (k *Twitter) NewChatSession(userName string) (*ChatSession, error) {
+	if !k.local {
+		return nil, fmt.Errorf("chat sessions require an agent in local mode")
+	}
+	if userName == "" {
+		userName = "local"
+	}
+
+	s := &ChatSession{
+		k:        k,
+		userID:   chatIDPrefix + userName,
+		userName: userName,
+	}
+	s.newConversation()
+	return s, nil
+}
+
+// newConversation switches to a new conversation ID
+func (s *ChatSession) newConversation() {
+	s.conversationID = chatIDPrefix + string(id.New())
+	s.lastTweetID = ""
+	s.sessionIDs = append(s.sessionIDs, id.FromString(s.conversationID))
+}
+
+// Send processes a message as a tweet of the local user and returns the agent's reply
+func (s *ChatSession) Send(text string) (*ChatReply, error) {
+	tweet := &twitter.ParsedTweet{
+		TweetID:             chatIDPrefix + string(id.New()),
+		TweetConversationID: s.conversationID,
+		InReplyToTweetID:    s.lastTweetID,
+		UserID:              s.userID,
+		UserName:            s.userName,
+		DisplayName:         s.userName,
+		TweetText:           text,
+		TweetCreatedAt:      time.Now().Unix(),
+	}
+
+	s.prompt, s.reasoning = nil, ""
+	s.k.chat = s
+	defer func() {
+		s.k.chat = nil
+	}()
+
+	response, err := s.k.processChatMessage(tweet)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lastTweetID = string(response.ID)
+	return &ChatReply{
+		Reasoning: s.reasoning,
+		Answer:    response.Content,
+	}, nil
+}
+
+// Prompt returns the messages composed for the last reply, nil before the first one
+func (s *ChatSession) Prompt() []llm.Message {
+	return s.prompt
+}
+
+// Reset deletes the conversation so far and starts a new one
+func (s *ChatSession) Reset() error {
+	if err := s.k.deleteScratchSessions(s.sessionIDs); err != nil {
+		return fmt.Errorf("failed to delete chat session: %w", err)
+	}
+	s.sessionIDs = nil
+	s.prompt, s.reasoning = nil, ""
+	s.newConversation()
+	return nil
+}
+
+// Close deletes the conversations of the session
+func (s *ChatSession) Close() error {
+	if err := s.k.deleteScratchSessions(s.sessionIDs); err != nil {
+		return fmt.Errorf("failed to delete chat session: %w", err)
+	}
+	s.sessionIDs = nil
+	return nil
+}
+
+// processChatMessage runs a chat message through the steps of handleTweetProcessing that don't touch Twitter:
+// 1. Initializes conversation data
+// 2. Creates embeddings for the message and processes its fragment
+// 3. Generates the reply within the Twitter requirements, always replying
+// 4. Stores the reply through PostProcess so that it becomes context for the next message
+// Returns the reply fragment.
+func (k *Twitter) processChatMessage(tweet *twitter.ParsedTweet) (*db.Fragment, error) {
+	_, endUsage := k.beginUsage(k.ctx, k.tweetUsageScope(UsagePurposeChat, tweet))
+	defer endUsage()
+
+	if err := k.checkBudget(); err != nil {
+		return nil, err
+	}
+
+	if err := k.initializeConversationData(tweet); err != nil {
+		return nil, err
+	}
+
+	currentState, err := k.newMessageState(k.ctx, tweet, k.tweetPlatform(), k.twitterConfig.Credentials.User)
+	if err != nil {
+		return nil, err
+	}
+	currentState.AddCustomData("actor_profile", formatActorProfile(nil))
+
+	// Replies are generated as for Twitter, so the chat previews how the persona answers there
+	response, err := k.generateTweetResponse(k.ctx, currentState, tweet, k.twitterConnector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate reply: %w", err)
+	}
+	response.Metadata["tweet_id"] = string(response.ID)
+
+	if err := k.assistant.PostProcess(response, currentState); err != nil {
+		return nil, fmt.Errorf("failed to store reply: %w", err)
+	}
+
+	return response, nil
+}
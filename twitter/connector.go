@@ -0,0 +1,241 @@
+package twitter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/velumlabs/loki/internal/utils"
+	"github.com/velumlabs/thor/db"
+	"github.com/velumlabs/thor/id"
+	"github.com/velumlabs/thor/pkg/twitter"
+	"github.com/velumlabs/thor/state"
+)
+
+// platformTwitter is the platform of the Twitter connector, the only platform the twitter manager acts on
+const platformTwitter = "twitter"
+
+// Connector connects the agent to a messaging platform. The reply pipeline runs against connectors:
+// the messages they fetch are processed like tweets and the replies are posted through them.
+// Connectors implementing Start are started with the agent, those implementing io.Closer are closed when it stops.
+type Connector interface {
+	// Platform names the platform. It is the platform custom data value of its messages and,
+	// except on Twitter, prefixes the IDs of its messages in storage.
+	Platform() string
+	// Identity returns the account the agent speaks as on the platform
+	Identity() ConnectorIdentity
+	// Limits returns the constraints replies must respect on the platform
+	Limits() PlatformLimits
+	// Fetch returns the messages received since the last call that the agent should answer, oldest first
+	Fetch(ctx context.Context) ([]*InboundMessage, error)
+	// Reply posts a reply to a message and returns the platform ID of the posted message
+	Reply(ctx context.Context, to *InboundMessage, content string) (string, error)
+}
+
+// ConnectorIdentity is the account the agent speaks as on a platform
+type ConnectorIdentity struct {
+	UserID   string
+	UserName string
+}
+
+// PlatformLimits are the constraints of replies on a platform
+type PlatformLimits struct {
+	MaxLength    int      // characters of a reply, longer replies are cut, zero for no limit
+	Requirements []string // reply requirements listed in the prompt
+}
+
+// InboundMessage is a message received on a platform
+type InboundMessage struct {
+	ID             string    `json:"id"`
+	ConversationID string    `json:"conversation_id,omitempty"` // defaults to the message ID
+	InReplyToID    string    `json:"in_reply_to_id,omitempty"`
+	UserID         string    `json:"user_id"`
+	UserName       string    `json:"user_name"`
+	DisplayName    string    `json:"display_name,omitempty"`
+	Text           string    `json:"text"`
+	CreatedAt      time.Time `json:"created_at"`
+
+	// tweet is the full tweet of messages fetched from Twitter
+	tweet *twitter.ParsedTweet
+}
+
+// defaultPlatformRequirements are the reply requirements of platforms that don't set their own
+var defaultPlatformRequirements = []string{
+	"Keep your core personality traits consistent",
+	"NO acting like an assistant or asking questions",
+	"NO offering assistance or guidance",
+	"Respond naturally as yourself",
+	"Maintain conversation flow while staying in character",
+}
+
+// formatRequirements numbers the requirements of a platform for the prompt
+func formatRequirements(requirements []string) string {
+	lines := make([]string, len(requirements))
+	for i, requirement := range requirements {
+		lines[i] = fmt.Sprintf("%d. %s", i+1, requirement)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// messageTweet returns the tweet a message is processed as. Messages from platforms other than Twitter
+// get IDs prefixed with the platform so that they never collide with tweets.
+func messageTweet(platform string, message *InboundMessage) *twitter.ParsedTweet {
+	if message.tweet != nil {
+		return message.tweet
+	}
+
+	scoped := func(platformID string) string {
+		return platformMessageID(platform, platformID)
+	}
+	conversationID := message.ConversationID
+	if conversationID == "" {
+		conversationID = message.ID
+	}
+
+	return &twitter.ParsedTweet{
+		TweetID:             scoped(message.ID),
+		TweetConversationID: scoped(conversationID),
+		InReplyToTweetID:    scoped(message.InReplyToID),
+		UserID:              scoped(message.UserID),
+		UserName:            message.UserName,
+		DisplayName:         message.DisplayName,
+		TweetText:           message.Text,
+		TweetCreatedAt:      message.CreatedAt.Unix(),
+	}
+}
+
+// platformMessageID returns the ID a message of a platform is stored with, prefixed with the platform
+// except on Twitter
+func platformMessageID(platform string, platformID string) string {
+	if platformID == "" || platform == platformTwitter {
+		return platformID
+	}
+	return platform + ":" + platformID
+}
+
+// truncateReply cuts a reply to the maximum length of a platform, at a word boundary when there is one
+func truncateReply(content string, maxLength int) string {
+	if maxLength <= 0 || utf8.RuneCountInString(content) <= maxLength {
+		return content
+	}
+	cut := string([]rune(content)[:maxLength-1])
+	if i := strings.LastIndexAny(cut, " \n"); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimSpace(cut) + "…"
+}
+
+// newMessageState runs a message through the platform independent steps of the reply pipeline:
+// 1. Creates embeddings for the message text
+// 2. Creates the message fragment and its state
+// 3. Processes the state with the managers and updates it
+// The conversation data must be initialized first. Returns the state ready for generating a reply.
+func (k *Twitter) newMessageState(ctx context.Context, tweet *twitter.ParsedTweet, platform string, agentUserName string) (*state.State, error) {
+	embedding, err := k.embedText(ctx, tweet.TweetText)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed tweet text: %w", err)
+	}
+
+	// Create fragment for the tweet
+	tweetFragment, err := utils.CreateTweetFragment(tweet, id.FromString(tweet.UserID), embedding)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tweet fragment: %w", err)
+	}
+
+	currentState, err := k.assistant.NewStateFromFragment(tweetFragment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create state: %w", err)
+	}
+	currentState.AddCustomData("platform", platform)
+
+	// The engine takes no context, so its LLM calls are covered by this span
+	_, endProcessSpan := k.startSpan(ctx, "assistant.Process", tweetAttributes(tweet)...)
+	err = k.assistant.Process(currentState)
+	endProcessSpan(err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process message: %w", err)
+	}
+
+	// update state after processing
+	if err := k.assistant.UpdateState(currentState); err != nil {
+		return nil, fmt.Errorf("failed to update state: %w", err)
+	}
+
+	currentState.AddCustomData("platform", platform)
+	currentState.AddCustomData("agent_twitter_username", agentUserName)
+	currentState.AddCustomData("agent_name", k.assistant.Name)
+	return currentState, nil
+}
+
+// monitorConnector continuously answers the messages fetched from a connector.
+// It runs in a separate goroutine next to monitorTwitter and stops on
+// context cancellation or through the stopChan.
+func (k *Twitter) monitorConnector(connector Connector) {
+	k.logger.Infof("Monitoring %s messages for %v", connector.Platform(), connector.Identity().UserName)
+	for {
+		if err := k.checkConnector(connector); err != nil {
+			k.logger.Errorf("Failed to check %s messages: %v", connector.Platform(), err)
+		}
+
+		select {
+		case <-time.After(randomDuration(k.twitterConfig.Connectors.PollInterval)):
+			continue
+		case <-k.ctx.Done():
+			k.logger.Infof("%s monitoring stopped", connector.Platform())
+			return
+		case <-k.stopChan:
+			k.logger.Infof("%s monitoring stopped", connector.Platform())
+			return
+		}
+	}
+}
+
+// checkConnector fetches the new messages of a connector and answers them through handleTweetProcessing,
+// skipping the agent's own messages and those skipped for the same reasons as tweets.
+// Returns an error if fetching fails.
+func (k *Twitter) checkConnector(connector Connector) error {
+	if err := k.checkNotPaused(); err != nil {
+		if errors.Is(err, errPaused) {
+			return nil
+		}
+		return err
+	}
+	if err := k.checkBudget(); err != nil {
+		k.logger.Warnf("Daily LLM budget of $%.2f exceeded, skipping %s check", k.twitterConfig.Usage.DailyBudget, connector.Platform())
+		return nil
+	}
+
+	messages, err := connector.Fetch(k.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch messages: %w", err)
+	}
+
+	identity := connector.Identity()
+	for _, message := range messages {
+		if (identity.UserID != "" && message.UserID == identity.UserID) || strings.EqualFold(message.UserName, identity.UserName) {
+			continue
+		}
+
+		tweet := messageTweet(connector.Platform(), message)
+		k.audit(AuditTweetFetched, tweet, db.Metadata{
+			"created_at": tweet.TweetCreatedAt,
+			"platform":   connector.Platform(),
+		})
+		if reason, details := k.skipReason(tweet); reason != "" {
+			k.auditSkipped(tweet, reason, details)
+			k.publishModerated(tweet, reason, details)
+			continue
+		}
+
+		if err := k.handleTweetProcessing(k.ctx, connector, message); errors.Is(err, errPaused) || errors.Is(err, errBudgetExceeded) {
+			k.logger.Infof("Stopping %s message processing: %v", connector.Platform(), err)
+			return nil
+		} else if err != nil {
+			k.logger.Errorf("Failed to process %s message %s: %v", connector.Platform(), message.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package twitter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/velumlabs/thor/pkg/twitter"
+)
+
+func TestMessageTweet(t *testing.T) {
+	createdAt := time.Unix(1700000000, 0)
+
+	t.Run("messages of other platforms are scoped", func(t *testing.T) {
+		got := messageTweet("http", &InboundMessage{ID: "1", InReplyToID: "0", UserID: "42", UserName: "alice", Text: "hi", CreatedAt: createdAt})
+		want := &twitter.ParsedTweet{
+			TweetID:             "http:1",
+			TweetConversationID: "http:1",
+			InReplyToTweetID:    "http:0",
+			UserID:              "http:42",
+			UserName:            "alice",
+			TweetText:           "hi",
+			TweetCreatedAt:      createdAt.Unix(),
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("messageTweet() = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("tweets are kept as fetched", func(t *testing.T) {
+		tweet := &twitter.ParsedTweet{TweetID: "1", TweetConversationID: "0", UserID: "42", TweetCreatedAt: createdAt.Unix()}
+		message := tweetMessage(tweet)
+		if got := messageTweet(platformTwitter, message); got != tweet {
+			t.Errorf("messageTweet() = %+v, want the fetched tweet", got)
+		}
+		if message.ID != "1" || message.ConversationID != "0" || !message.CreatedAt.Equal(createdAt) {
+			t.Errorf("tweetMessage() = %+v", message)
+		}
+	})
+}
+
+func TestPlatformMessageID(t *testing.T) {
+	tests := []struct {
+		platform string
+		id       string
+		want     string
+	}{
+		{platform: platformTwitter, id: "1", want: "1"},
+		{platform: "http", id: "1", want: "http:1"},
+		{platform: "http", id: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := platformMessageID(tt.platform, tt.id); got != tt.want {
+			t.Errorf("platformMessageID(%q, %q) = %q, want %q", tt.platform, tt.id, got, tt.want)
+		}
+	}
+}
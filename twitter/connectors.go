@@ -0,0 +1,400 @@
+package twitter
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/velumlabs/thor/id"
+	"github.com/velumlabs/thor/pkg/twitter"
+)
+
+// twitterConnector is the connector of the agent's Twitter account.
+// It fetches the replies to the account, ranked and cut to the reply budget, and posts replies
+// as threads when they are longer than a tweet, so it sets no length limit.
+type twitterConnector struct {
+	k *Twitter
+}
+
+// Platform returns "twitter"
+func (c *twitterConnector) Platform() string {
+	return platformTwitter
+}
+
+// Identity returns the screen name of the account
+func (c *twitterConnector) Identity() ConnectorIdentity {
+	return ConnectorIdentity{UserName: c.k.twitterConfig.Credentials.User}
+}
+
+// Limits returns the Twitter reply requirements
+func (c *twitterConnector) Limits() PlatformLimits {
+	return PlatformLimits{
+		Requirements: []string{
+			"Keep your core personality traits consistent",
+			"NO @ mentions",
+			"NO acting like an assistant or asking questions",
+			"NO offering assistance or guidance",
+			"Respond naturally as yourself",
+			c.k.replyLengthRequirement(),
+			"Maintain conversation flow while staying in character",
+		},
+	}
+}
+
+// Fetch returns the replies to the account worth answering, best first
+func (c *twitterConnector) Fetch(ctx context.Context) ([]*InboundMessage, error) {
+	tweets, signals, err := c.k.fetchAndParseTweets(ctx)
+	c.k.observeTwitterError(err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch and parse tweets: %w", err)
+	}
+
+	c.k.logger.Infof("Found %d tweets in timeline", len(tweets))
+
+	ranked := c.k.rankTweets(tweets, signals)
+	c.k.logger.Infof("Selected %d of %d tweets for processing", len(ranked), len(tweets))
+
+	messages := make([]*InboundMessage, len(ranked))
+	for i, tweet := range ranked {
+		messages[i] = tweetMessage(tweet)
+	}
+	return messages, nil
+}
+
+// Reply posts a reply to a tweet, as a thread when it is too long for a single tweet.
+// Returns the ID of the posted tweet, the first one of a thread.
+func (c *twitterConnector) Reply(ctx context.Context, to *InboundMessage, content string) (string, error) {
+	tweet := messageTweet(platformTwitter, to)
+
+	var postedID string
+	var err error
+	if c.k.isThreadReply(content) {
+		postedID, err = c.k.publishThread(ctx, tweet, content)
+	} else {
+		postedID, err = c.k.postReplyTweet(tweet.TweetID, content)
+	}
+	c.k.observeTwitterError(err)
+	return postedID, err
+}
+
+// tweetMessage returns the message of a tweet fetched from Twitter
+func tweetMessage(tweet *twitter.ParsedTweet) *InboundMessage {
+	return &InboundMessage{
+		ID:             tweet.TweetID,
+		ConversationID: tweet.TweetConversationID,
+		InReplyToID:    tweet.InReplyToTweetID,
+		UserID:         tweet.UserID,
+		UserName:       tweet.UserName,
+		DisplayName:    tweet.DisplayName,
+		Text:           tweet.TweetText,
+		CreatedAt:      time.Unix(tweet.TweetCreatedAt, 0),
+		tweet:          tweet,
+	}
+}
+
+// HTTPConnectorConfig configures an HTTP connector
+type HTTPConnectorConfig struct {
+	Platform     string        // platform name, "http" by default
+	Address      string        // address the message endpoint listens on
+	ReplyURL     string        // URL replies are posted to, empty keeps them for GET /replies
+	Secret       string        // HMAC key of the signature headers of messages and replies, empty disables signing
+	UserName     string        // name the agent speaks as
+	MaxLength    int           // characters of a reply, zero for no limit
+	Requirements []string      // reply requirements listed in the prompt, generic ones by default
+	QueueSize    int           // messages waiting to be fetched, 100 by default
+	Timeout      time.Duration // timeout of a reply request, 10s by default
+}
+
+// HTTPReply is a reply delivered by an HTTP connector
+type HTTPReply struct {
+	ID             string    `json:"id"`
+	Platform       string    `json:"platform"`
+	ConversationID string    `json:"conversation_id"`
+	InReplyToID    string    `json:"in_reply_to_id"`
+	UserID         string    `json:"user_id"` // author of the message replied to
+	Content        string    `json:"content"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// httpSignatureMaxAge is the age after which signed messages are rejected as replays
+const httpSignatureMaxAge = 5 * time.Minute
+
+// HTTPConnector receives messages as JSON over HTTP and posts replies to a webhook.
+// It bridges the agent to any platform through a small relay, and to curl for local testing.
+//
+// Endpoints:
+//
+//	POST /messages  queue an InboundMessage, answered with 202
+//	GET  /replies   replies delivered since the last call, when no reply URL is set
+//
+// With a secret, messages must carry the X-Wrz-Timestamp and X-Wrz-Signature headers computed
+// like those of the webhook event sink, and replies are signed the same way.
+type HTTPConnector struct {
+	config   HTTPConnectorConfig
+	client   *http.Client
+	server   *http.Server
+	listener net.Listener
+	queue    chan *InboundMessage
+
+	mu      sync.Mutex
+	replies []HTTPReply
+}
+
+// NewHTTPConnector returns a connector listening on the address of the config once started.
+// Returns an error if the address is missing, the reply URL is not an absolute http or https URL,
+// or the maximum length is negative.
+func NewHTTPConnector(config HTTPConnectorConfig) (*HTTPConnector, error) {
+	if config.Address == "" {
+		return nil, fmt.Errorf("HTTP connector address cannot be empty")
+	}
+	if config.ReplyURL != "" {
+		parsed, err := url.Parse(config.ReplyURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid reply URL: %q", config.ReplyURL)
+		}
+	}
+	if config.MaxLength < 0 {
+		return nil, fmt.Errorf("reply length limit cannot be negative")
+	}
+	if config.Platform == "" {
+		config.Platform = "http"
+	}
+	if config.Platform == platformTwitter {
+		return nil, fmt.Errorf("platform name %q is reserved", platformTwitter)
+	}
+	if len(config.Requirements) == 0 {
+		config.Requirements = defaultPlatformRequirements
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 100
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+
+	return &HTTPConnector{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		queue:  make(chan *InboundMessage, config.QueueSize),
+	}, nil
+}
+
+// Platform returns the platform name of the config
+func (c *HTTPConnector) Platform() string {
+	return c.config.Platform
+}
+
+// Identity returns the user name of the config
+func (c *HTTPConnector) Identity() ConnectorIdentity {
+	return ConnectorIdentity{UserName: c.config.UserName}
+}
+
+// Limits returns the length limit and requirements of the config
+func (c *HTTPConnector) Limits() PlatformLimits {
+	requirements := c.config.Requirements
+	if c.config.MaxLength > 0 {
+		requirements = append(requirements[:len(requirements):len(requirements)],
+			fmt.Sprintf("Keep final response under %d characters", c.config.MaxLength))
+	}
+	return PlatformLimits{
+		MaxLength:    c.config.MaxLength,
+		Requirements: requirements,
+	}
+}
+
+// Start listens on the address of the config and serves the endpoints in the background
+func (c *HTTPConnector) Start() error {
+	listener, err := net.Listen("tcp", c.config.Address)
+	if err != nil {
+		return fmt.Errorf("failed to start %s connector: %w", c.config.Platform, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/messages", c.handleMessages)
+	mux.HandleFunc("/replies", c.handleReplies)
+
+	c.listener = listener
+	c.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go c.server.Serve(listener)
+	return nil
+}
+
+// Addr returns the address the connector listens on, nil before Start
+func (c *HTTPConnector) Addr() net.Addr {
+	if c.listener == nil {
+		return nil
+	}
+	return c.listener.Addr()
+}
+
+// Close shuts the endpoints down
+func (c *HTTPConnector) Close() error {
+	if c.server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return c.server.Shutdown(ctx)
+}
+
+// Fetch returns the queued messages
+func (c *HTTPConnector) Fetch(_ context.Context) ([]*InboundMessage, error) {
+	var messages []*InboundMessage
+	for {
+		select {
+		case message := <-c.queue:
+			messages = append(messages, message)
+		default:
+			return messages, nil
+		}
+	}
+}
+
+// Reply posts the reply to the reply URL, or keeps it for GET /replies when there is none
+func (c *HTTPConnector) Reply(ctx context.Context, to *InboundMessage, content string) (string, error) {
+	conversationID := to.ConversationID
+	if conversationID == "" {
+		conversationID = to.ID
+	}
+	reply := HTTPReply{
+		ID:             string(id.New()),
+		Platform:       c.config.Platform,
+		ConversationID: conversationID,
+		InReplyToID:    to.ID,
+		UserID:         to.UserID,
+		Content:        content,
+		CreatedAt:      time.Now().UTC(),
+	}
+
+	if c.config.ReplyURL == "" {
+		c.mu.Lock()
+		c.replies = append(c.replies, reply)
+		c.mu.Unlock()
+		return reply.ID, nil
+	}
+
+	body, err := json.Marshal(reply)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode reply: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.config.ReplyURL, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookHeaderDelivery, reply.ID)
+	req.Header.Set(WebhookHeaderTimestamp, timestamp)
+	if c.config.Secret != "" {
+		req.Header.Set(WebhookHeaderSignature, SignWebhook(c.config.Secret, timestamp, body))
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send reply: %w", err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return "", fmt.Errorf("reply URL responded with status %d", res.StatusCode)
+	}
+	return reply.ID, nil
+}
+
+// handleMessages queues a message posted to the connector
+func (c *HTTPConnector) handleMessages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAdminError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, fmt.Errorf("failed to read request body: %w", err))
+		return
+	}
+	if err := c.verifySignature(r.Header, body); err != nil {
+		writeAdminError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	var message InboundMessage
+	if err := json.Unmarshal(body, &message); err != nil {
+		writeAdminError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if message.ID == "" || message.UserID == "" || message.Text == "" {
+		writeAdminError(w, http.StatusBadRequest, fmt.Errorf("id, user_id and text are required"))
+		return
+	}
+	if message.UserName == "" {
+		message.UserName = message.UserID
+	}
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = time.Now()
+	}
+
+	select {
+	case c.queue <- &message:
+		writeAdminJSON(w, http.StatusAccepted, map[string]string{"id": message.ID})
+	default:
+		writeAdminError(w, http.StatusServiceUnavailable, fmt.Errorf("message queue is full"))
+	}
+}
+
+// handleReplies returns the replies kept since the last call
+func (c *HTTPConnector) handleReplies(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAdminError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed"))
+		return
+	}
+
+	c.mu.Lock()
+	replies := c.replies
+	c.replies = nil
+	c.mu.Unlock()
+
+	if replies == nil {
+		replies = []HTTPReply{}
+	}
+	writeAdminJSON(w, http.StatusOK, replies)
+}
+
+// verifySignature checks the signature headers of a message when the connector has a secret
+func (c *HTTPConnector) verifySignature(header http.Header, body []byte) error {
+	if c.config.Secret == "" {
+		return nil
+	}
+
+	timestamp := header.Get(WebhookHeaderTimestamp)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("missing or invalid signature timestamp")
+	}
+	if age := time.Since(time.Unix(seconds, 0)); age > httpSignatureMaxAge || age < -httpSignatureMaxAge {
+		return errors.New("signature timestamp is too old")
+	}
+
+	expected := SignWebhook(c.config.Secret, timestamp, body)
+	if !hmac.Equal([]byte(header.Get(WebhookHeaderSignature)), []byte(expected)) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
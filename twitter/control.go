@@ -0,0 +1,254 @@
+package twitter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/velumlabs/thor/db"
+	"github.com/velumlabs/thor/id"
+	"gorm.io/gorm"
+)
+
+// errPaused is returned by operations skipped because the agent is paused
+var errPaused = errors.New("agent is paused")
+
+// controlKeyPaused is the control key holding the pause state
+const controlKeyPaused = "paused"
+
+// MuteKind is the kind of target a mute applies to
+type MuteKind string
+
+const (
+	MuteKindConversation MuteKind = "conversation"
+	MuteKindUser         MuteKind = "user"
+)
+
+// BotControl is a persisted runtime control of the agent
+type BotControl struct {
+	Key   string `gorm:"type:varchar(64);primaryKey"`
+	Value string `gorm:"type:varchar(255);not null"`
+
+	UpdatedAt time.Time
+}
+
+// TableName returns the table runtime controls are stored in
+func (BotControl) TableName() string {
+	return "bot_controls"
+}
+
+// Mute is a conversation or user the agent does not engage with until unmuted
+type Mute struct {
+	Kind   MuteKind `gorm:"type:varchar(32);primaryKey" json:"kind"`
+	Target string   `gorm:"type:varchar(255);primaryKey" json:"target"` // conversation ID, user ID or lowercase screen name
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName returns the table mutes are stored in
+func (Mute) TableName() string {
+	return "mutes"
+}
+
+// ControlStore persists the runtime controls of the agent.
+// Controls are always read from the database so that changes made by the CLI
+// apply to a running agent and survive restarts.
+type ControlStore struct {
+	db  *gorm.DB
+	ctx context.Context
+}
+
+// NewControlStore returns a new ControlStore initialized with the provided context and DB connection
+func NewControlStore(ctx context.Context, db *gorm.DB) *ControlStore {
+	return &ControlStore{
+		db:  db,
+		ctx: ctx,
+	}
+}
+
+// IsPaused reports whether the agent is paused
+func (s *ControlStore) IsPaused() (bool, error) {
+	var control BotControl
+	err := s.db.WithContext(s.ctx).First(&control, "key = ?", controlKeyPaused).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return control.Value == "true", nil
+}
+
+// SetPaused persists the pause state and records it in the audit log
+func (s *ControlStore) SetPaused(paused bool) error {
+	action := "resume"
+	if paused {
+		action = "pause"
+	}
+
+	return s.db.WithContext(s.ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&BotControl{
+			Key:       controlKeyPaused,
+			Value:     fmt.Sprintf("%t", paused),
+			UpdatedAt: time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		return recordAdminAction(tx, action, "", nil)
+	})
+}
+
+// Mute stops the agent from engaging with a conversation or user
+func (s *ControlStore) Mute(kind MuteKind, target string) error {
+	target, err := normalizeMuteTarget(kind, target)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithContext(s.ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&Mute{Kind: kind, Target: target, CreatedAt: time.Now()}).Error; err != nil {
+			return err
+		}
+		return recordAdminAction(tx, "mute", string(kind)+":"+target, nil)
+	})
+}
+
+// Unmute lets the agent engage with a muted conversation or user again
+func (s *ControlStore) Unmute(kind MuteKind, target string) error {
+	target, err := normalizeMuteTarget(kind, target)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithContext(s.ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&Mute{}, "kind = ? AND target = ?", kind, target).Error; err != nil {
+			return err
+		}
+		return recordAdminAction(tx, "unmute", string(kind)+":"+target, nil)
+	})
+}
+
+// IsMuted reports whether any of the targets of the given kind is muted
+func (s *ControlStore) IsMuted(kind MuteKind, targets ...string) (bool, error) {
+	var count int64
+	err := s.db.WithContext(s.ctx).
+		Model(&Mute{}).
+		Where("kind = ? AND target IN ?", kind, targets).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ListMutes returns every active mute
+func (s *ControlStore) ListMutes() ([]Mute, error) {
+	var mutes []Mute
+	err := s.db.WithContext(s.ctx).Order("created_at").Find(&mutes).Error
+	return mutes, err
+}
+
+// normalizeMuteTarget validates a mute target. Screen names are stored lowercase without the @.
+func normalizeMuteTarget(kind MuteKind, target string) (string, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return "", fmt.Errorf("mute target cannot be empty")
+	}
+
+	switch kind {
+	case MuteKindConversation:
+		return target, nil
+	case MuteKindUser:
+		return strings.ToLower(strings.TrimPrefix(target, "@")), nil
+	default:
+		return "", fmt.Errorf("unknown mute kind: %s", kind)
+	}
+}
+
+// recordAdminAction writes an entry to the admin audit log
+func recordAdminAction(tx *gorm.DB, action string, target string, details db.Metadata) error {
+	return tx.Create(&AdminAuditEntry{
+		ID:        id.New(),
+		Action:    action,
+		Target:    target,
+		Details:   details,
+		CreatedAt: time.Now(),
+	}).Error
+}
+
+// Pause stops the agent from fetching and posting until Resume is called.
+// The pause state is persisted, so a restarted agent stays paused.
+func (k *Twitter) Pause() error {
+	if err := k.controlStore.SetPaused(true); err != nil {
+		return fmt.Errorf("failed to pause: %w", err)
+	}
+	k.logger.Infof("Agent paused")
+	k.observePaused(true)
+	return nil
+}
+
+// Resume lets a paused agent fetch and post again
+func (k *Twitter) Resume() error {
+	if err := k.controlStore.SetPaused(false); err != nil {
+		return fmt.Errorf("failed to resume: %w", err)
+	}
+	k.logger.Infof("Agent resumed")
+	k.observePaused(false)
+	return nil
+}
+
+// IsPaused reports whether the agent is paused
+func (k *Twitter) IsPaused() (bool, error) {
+	return k.controlStore.IsPaused()
+}
+
+// MuteConversation stops the agent from engaging in a conversation
+func (k *Twitter) MuteConversation(conversationID string) error {
+	return k.controlStore.Mute(MuteKindConversation, conversationID)
+}
+
+// UnmuteConversation lets the agent engage in a muted conversation again
+func (k *Twitter) UnmuteConversation(conversationID string) error {
+	return k.controlStore.Unmute(MuteKindConversation, conversationID)
+}
+
+// MuteUser stops the agent from engaging with a user, identified by user ID or @handle
+func (k *Twitter) MuteUser(user string) error {
+	return k.controlStore.Mute(MuteKindUser, user)
+}
+
+// UnmuteUser lets the agent engage with a muted user again
+func (k *Twitter) UnmuteUser(user string) error {
+	return k.controlStore.Unmute(MuteKindUser, user)
+}
+
+// checkNotPaused returns errPaused if the agent is paused, and publishes changes of the pause state.
+// Lookup failures are treated as paused so that the agent fails safe.
+func (k *Twitter) checkNotPaused() error {
+	paused, err := k.controlStore.IsPaused()
+	if err != nil {
+		return fmt.Errorf("failed to check pause state: %w", err)
+	}
+	k.observePaused(paused)
+	if paused {
+		return errPaused
+	}
+	return nil
+}
+
+// isMuted reports whether the conversation or the user is muted.
+// Lookup failures are treated as muted.
+func (k *Twitter) isMuted(conversationID, userID, userName string) bool {
+	if conversationID != "" {
+		muted, err := k.controlStore.IsMuted(MuteKindConversation, conversationID)
+		if err != nil || muted {
+			return true
+		}
+	}
+
+	muted, err := k.controlStore.IsMuted(MuteKindUser, userID, strings.ToLower(userName))
+	if err != nil {
+		k.logger.Errorf("Failed to check mutes: %v", err)
+		return true
+	}
+	return muted
+}
@@ -0,0 +1,143 @@
+package twitter
+
+import (
+	"fmt"
+
+	"github.com/velumlabs/thor/db"
+	"github.com/velumlabs/thor/llm"
+	"github.com/velumlabs/thor/managers/personality"
+	twitter_manager "github.com/velumlabs/thor/managers/twitter"
+	"github.com/velumlabs/thor/pkg/twitter"
+	"github.com/velumlabs/thor/state"
+)
+
+// ReplyAction is the outcome of the reply decision step
+type ReplyAction string
+
+const (
+	ReplyActionReply  ReplyAction = "reply"
+	ReplyActionLike   ReplyAction = "like"
+	ReplyActionIgnore ReplyAction = "ignore"
+)
+
+// Confidence thresholds of the reply decision step
+const (
+	DefaultReplyThreshold = 0.6
+	DefaultLikeThreshold  = 0.5
+)
+
+// decisionMetadataKey is the fragment metadata key the reply decision is stored under
+const decisionMetadataKey = "reply_decision"
+
+// ReplyDecision is the structured output of the reply classifier
+type ReplyDecision struct {
+	Action     ReplyAction `json:"action" jsonschema:"required,enum=reply,enum=like,enum=ignore" description:"reply: the tweet deserves a written response, like: acknowledge the tweet without replying, ignore: spam, noise or nothing worth engaging with"`
+	Confidence float64     `json:"confidence" jsonschema:"required,minimum=0,maximum=1"`
+	Reason     string      `json:"reason" jsonschema:"required" description:"One short sentence explaining the decision"`
+	Retweet    bool        `json:"retweet" jsonschema:"required" description:"Share the tweet with the persona's followers as is"`
+	Quote      bool        `json:"quote" jsonschema:"required" description:"Share the tweet with the persona's followers along with a comment"`
+	FollowBack bool        `json:"follow_back" jsonschema:"required" description:"Follow the author of the tweet"`
+}
+
+// decideReply classifies whether a tweet deserves a reply, a like or nothing.
+// - Builds a cheap prompt from the personality, the thread and the tweet
+// - Asks the fast model for a decision with a confidence and a reason
+// - Downgrades low confidence decisions using the configured thresholds
+// - Stores the decision in the tweet fragment metadata for auditing
+// Returns the final decision and any error encountered.
+func (k *Twitter) decideReply(currentState *state.State, tweet *twitter.ParsedTweet) (*ReplyDecision, error) {
+	messages, err := state.NewPromptBuilder(currentState).
+		AddSystemSection(`You decide whether a Twitter persona should engage with a tweet it received.
+
+Persona:
+{{.base_personality}}
+
+Twitter Conversation:
+{{.twitter_conversations}}
+
+Guidelines:
+1. reply when the tweet invites a response: a question, an opinion, a story or a genuine comment
+2. like when the tweet deserves acknowledgement but a reply would add nothing, such as "ok", "thanks" or emoji-only tweets
+3. ignore spam, link drops, hostile bait and anything the persona should stay out of
+4. retweet or quote only tweets the persona would proudly share with its own followers, which is rare
+5. follow back only authors who engage genuinely and repeatedly
+
+Task:
+Decide how to engage with the tweet marked with →`).
+		WithManagerData(personality.BasePersonality).
+		WithManagerData(twitter_manager.TwitterConversations).
+		Compose()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build decision prompt: %w", err)
+	}
+
+	var decision ReplyDecision
+	if err := k.llmClient.GenerateStructuredOutput(llm.StructuredOutputRequest{
+		Messages:     messages,
+		ModelType:    llm.ModelTypeFast,
+		SchemaName:   "reply_decision",
+		StrictSchema: true,
+	}, &decision); err != nil {
+		return nil, fmt.Errorf("failed to generate reply decision: %w", err)
+	}
+
+	modelAction := decision.Action
+	decision.Action = k.applyDecisionThresholds(decision)
+
+	k.logger.WithFields(map[string]interface{}{
+		"tweet_id":     tweet.TweetID,
+		"model_action": modelAction,
+		"action":       decision.Action,
+		"confidence":   decision.Confidence,
+		"reason":       decision.Reason,
+		"retweet":      decision.Retweet,
+		"quote":        decision.Quote,
+		"follow_back":  decision.FollowBack,
+	}).Infof("Decided how to engage with tweet")
+
+	if err := k.storeReplyDecision(currentState.Input, &decision, modelAction); err != nil {
+		return nil, err
+	}
+
+	return &decision, nil
+}
+
+// applyDecisionThresholds downgrades a decision whose confidence is below the
+// threshold of its action: reply falls back to like, and like falls back to ignore.
+func (k *Twitter) applyDecisionThresholds(decision ReplyDecision) ReplyAction {
+	thresholds := k.twitterConfig.Decision
+
+	action := decision.Action
+	if action == ReplyActionReply && decision.Confidence < thresholds.ReplyThreshold {
+		action = ReplyActionLike
+	}
+	if action == ReplyActionLike && decision.Confidence < thresholds.LikeThreshold {
+		action = ReplyActionIgnore
+	}
+	return action
+}
+
+// storeReplyDecision records the decision in the metadata of the stored tweet fragment,
+// keeping the action chosen by the model next to the final action.
+func (k *Twitter) storeReplyDecision(fragment *db.Fragment, decision *ReplyDecision, modelAction ReplyAction) error {
+	metadata := db.Metadata{}
+	for key, value := range fragment.Metadata {
+		metadata[key] = value
+	}
+	metadata[decisionMetadataKey] = map[string]interface{}{
+		"action":       string(decision.Action),
+		"model_action": string(modelAction),
+		"confidence":   decision.Confidence,
+		"reason":       decision.Reason,
+		"retweet":      decision.Retweet,
+		"quote":        decision.Quote,
+		"follow_back":  decision.FollowBack,
+	}
+
+	if err := k.interactionFragmentStore.UpdateMetadata(fragment.ID, metadata); err != nil {
+		return fmt.Errorf("failed to store reply decision: %w", err)
+	}
+	fragment.Metadata = metadata
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package twitter
+
+import "testing"
+
+func TestApplyDecisionThresholds(t *testing.T) {
+	k := &Twitter{twitterConfig: TwitterConfig{
+		Decision: DecisionConfig{ReplyThreshold: DefaultReplyThreshold, LikeThreshold: 0.4},
+	}}
+
+	tests := []struct {
+		name     string
+		decision ReplyDecision
+		want     ReplyAction
+	}{
+		{name: "confident reply", decision: ReplyDecision{Action: ReplyActionReply, Confidence: 0.9}, want: ReplyActionReply},
+		{name: "reply at the threshold", decision: ReplyDecision{Action: ReplyActionReply, Confidence: 0.6}, want: ReplyActionReply},
+		{name: "unsure reply becomes like", decision: ReplyDecision{Action: ReplyActionReply, Confidence: 0.5}, want: ReplyActionLike},
+		{name: "doubtful reply becomes ignore", decision: ReplyDecision{Action: ReplyActionReply, Confidence: 0.3}, want: ReplyActionIgnore},
+		{name: "confident like", decision: ReplyDecision{Action: ReplyActionLike, Confidence: 0.5}, want: ReplyActionLike},
+		{name: "unsure like becomes ignore", decision: ReplyDecision{Action: ReplyActionLike, Confidence: 0.1}, want: ReplyActionIgnore},
+		{name: "ignore stays ignore", decision: ReplyDecision{Action: ReplyActionIgnore, Confidence: 0}, want: ReplyActionIgnore},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := k.applyDecisionThresholds(tt.decision); got != tt.want {
+				t.Errorf("applyDecisionThresholds(%s, %.2f) = %s, want %s", tt.decision.Action, tt.decision.Confidence, got, tt.want)
+			}
+		})
+	}
+}
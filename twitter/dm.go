@@ -0,0 +1,574 @@
+package twitter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pgvector/pgvector-go"
+	"github.com/velumlabs/thor/db"
+	"github.com/velumlabs/thor/engine"
+	"github.com/velumlabs/thor/id"
+	"github.com/velumlabs/thor/llm"
+	"github.com/velumlabs/thor/logger"
+	"github.com/velumlabs/thor/manager"
+	"github.com/velumlabs/thor/managers/insight"
+	"github.com/velumlabs/thor/managers/personality"
+	"github.com/velumlabs/thor/options"
+	"github.com/velumlabs/thor/state"
+	"github.com/velumlabs/thor/stores"
+	"gorm.io/gorm"
+)
+
+// Direct messages are kept in their own fragment tables so that private content
+// never reaches the stores used to build public reply context.
+const (
+	FragmentTableDirectMessage            db.FragmentTable = "direct_message"
+	FragmentTableDirectMessageInsight     db.FragmentTable = "direct_message_insight"
+	FragmentTableDirectMessagePersonality db.FragmentTable = "direct_message_personality"
+)
+
+// directMessageFragmentTables are the fragment tables holding direct message content
+var directMessageFragmentTables = []db.FragmentTable{
+	FragmentTableDirectMessage,
+	FragmentTableDirectMessageInsight,
+	FragmentTableDirectMessagePersonality,
+}
+
+// platformDirectMessage is the platform custom data value of direct message states
+const platformDirectMessage = "twitter_dm"
+
+// DMOptIn records a user who asked the agent to answer their direct messages
+type DMOptIn struct {
+	TwitterUserID string `gorm:"type:varchar(64);primaryKey"`
+	UserName      string `gorm:"type:varchar(255)"`
+
+	CreatedAt time.Time
+}
+
+// TableName returns the table direct message opt-ins are stored in
+func (DMOptIn) TableName() string {
+	return "dm_opt_ins"
+}
+
+// DMOptInStore persists direct message opt-ins
+type DMOptInStore struct {
+	db  *gorm.DB
+	ctx context.Context
+}
+
+// NewDMOptInStore returns a new DMOptInStore initialized with the provided context and DB connection
+func NewDMOptInStore(ctx context.Context, db *gorm.DB) *DMOptInStore {
+	return &DMOptInStore{
+		db:  db,
+		ctx: ctx,
+	}
+}
+
+// Upsert creates or updates the opt-in of a user
+func (s *DMOptInStore) Upsert(optIn *DMOptIn) error {
+	return s.db.WithContext(s.ctx).Save(optIn).Error
+}
+
+// DeleteByUserID removes the opt-in of a user
+func (s *DMOptInStore) DeleteByUserID(twitterUserID string) error {
+	return s.db.WithContext(s.ctx).Delete(&DMOptIn{}, "twitter_user_id = ?", twitterUserID).Error
+}
+
+// Exists reports whether the user opted in
+func (s *DMOptInStore) Exists(twitterUserID string) (bool, error) {
+	var count int64
+	err := s.db.WithContext(s.ctx).
+		Model(&DMOptIn{}).
+		Where("twitter_user_id = ?", twitterUserID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// createDirectMessageAssistant builds the engine that answers direct messages.
+// It shares the actor and session stores and the personality with the public agent,
+// but stores interactions, insights and personality fragments in the direct message fragment tables.
+func (k *Twitter) createDirectMessageAssistant(
+	sessionStore *stores.SessionStore,
+	actorStore *stores.ActorStore,
+	assistantName string,
+	assistantID id.ID,
+) error {
+	dmFragmentStore := stores.NewFragmentStore(k.ctx, k.database, FragmentTableDirectMessage)
+	dmInsightFragmentStore := stores.NewFragmentStore(k.ctx, k.database, FragmentTableDirectMessageInsight)
+	dmPersonalityFragmentStore := stores.NewFragmentStore(k.ctx, k.database, FragmentTableDirectMessagePersonality)
+
+	insightManager, err := insight.NewInsightManager(
+		[]options.Option[manager.BaseManager]{
+			manager.WithLogger(k.logger.NewSubLogger("dm_insight", &logger.SubLoggerOpts{})),
+			manager.WithContext(k.ctx),
+			manager.WithActorStore(actorStore),
+			manager.WithLLM(k.llmClient),
+			manager.WithSessionStore(sessionStore),
+			manager.WithFragmentStore(dmInsightFragmentStore),
+			manager.WithInteractionFragmentStore(dmFragmentStore),
+			manager.WithAssistantDetails(assistantName, assistantID),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	personalityManager, err := personality.NewPersonalityManager(
+		[]options.Option[manager.BaseManager]{
+			manager.WithLogger(k.logger.NewSubLogger("dm_personality", &logger.SubLoggerOpts{})),
+			manager.WithContext(k.ctx),
+			manager.WithActorStore(actorStore),
+			manager.WithLLM(k.llmClient),
+			manager.WithSessionStore(sessionStore),
+			manager.WithFragmentStore(dmPersonalityFragmentStore),
+			manager.WithInteractionFragmentStore(dmFragmentStore),
+			manager.WithAssistantDetails(assistantName, assistantID),
+		},
+		personality.WithPersonality(k.personality),
+	)
+	if err != nil {
+		return err
+	}
+
+	assistant, err := engine.New(
+		engine.WithContext(k.ctx),
+		engine.WithLogger(k.logger.NewSubLogger("dm_agent", &logger.SubLoggerOpts{
+			Fields: map[string]interface{}{
+				"agent": assistantName,
+			},
+		})),
+		engine.WithDB(k.database),
+		engine.WithIdentifier(assistantID, assistantName),
+		engine.WithSessionStore(sessionStore),
+		engine.WithActorStore(actorStore),
+		engine.WithInteractionFragmentStore(dmFragmentStore),
+		engine.WithManagers(insightManager, personalityManager),
+	)
+	if err != nil {
+		return err
+	}
+
+	k.dmAssistant = assistant
+	k.dmOptInStore = NewDMOptInStore(k.ctx, k.database)
+
+	return nil
+}
+
+// monitorDirectMessages continuously polls the direct message inbox.
+// It runs in a separate goroutine next to monitorTwitter and stops on
+// context cancellation or through the stopChan.
+func (k *Twitter) monitorDirectMessages() {
+	k.logger.Infof("Monitoring direct messages for %v", k.twitterConfig.Credentials.User)
+	for {
+		if err := k.checkDirectMessages(); err != nil {
+			k.logger.Errorf("Failed to check direct messages: %v", err)
+		}
+
+		interval := randomDuration(k.twitterConfig.DirectMessages.PollInterval)
+		k.logger.Infof("Waiting %v until next direct message check", interval)
+
+		select {
+		case <-time.After(interval):
+			continue
+		case <-k.ctx.Done():
+			k.logger.Infof("Direct message monitoring stopped")
+			return
+		case <-k.stopChan:
+			k.logger.Infof("Direct message monitoring stopped")
+			return
+		}
+	}
+}
+
+// checkDirectMessages fetches the inbox and answers new messages.
+// For each message:
+// - Skips own messages, old messages and messages that were already handled
+// - Skips senders that are not allowed by the direct message policy
+// - Skips messages once the direct message rate limits are reached
+// Returns an error if fetching the inbox fails.
+func (k *Twitter) checkDirectMessages() error {
+	config := k.twitterConfig.DirectMessages
+
+	if err := k.checkNotPaused(); err != nil {
+		if errors.Is(err, errPaused) {
+			k.logger.Infof("Agent is paused, skipping direct message check")
+			return nil
+		}
+		return err
+	}
+	if err := k.checkBudget(); err != nil {
+		k.logger.Warnf("Daily LLM budget of $%.2f exceeded, skipping direct message check", k.twitterConfig.Usage.DailyBudget)
+		return nil
+	}
+
+	messages, users, err := k.twitterAPI.FetchInbox()
+	k.observeTwitterError(err)
+	if err != nil {
+		return fmt.Errorf("failed to fetch inbox: %w", err)
+	}
+
+	newestKeywords := k.newestKeywordMessages(messages)
+	for _, message := range messages {
+		if k.isOwnTweet(users[message.SenderID]) || k.isDoNotEngage(message.SenderID) || k.isMuted("", message.SenderID, message.SenderName) {
+			continue
+		}
+
+		if time.Since(time.Unix(message.CreatedAt, 0)) > config.MaxAge {
+			continue
+		}
+
+		if _, err := k.dmAssistant.DoesInteractionFragmentExist(directMessageFragmentID(message.MessageID)); err == nil {
+			continue
+		}
+
+		allowed, err := k.isDirectMessageAllowed(message, newestKeywords)
+		if err != nil {
+			k.logger.Errorf("Failed to check direct message policy for %s: %v", message.SenderName, err)
+			continue
+		}
+		if !allowed {
+			// The inbox returns the same messages on every poll
+			k.logger.Debugf("Skipping direct message %s: sender %s not allowed", message.MessageID, message.SenderName)
+			continue
+		}
+
+		limited, err := k.rateLimitReached(EngagementActionDirectMessage, "", config.ReplyLimit)
+		if err == nil && !limited {
+			limited, err = k.rateLimitReached(EngagementActionDirectMessage, message.SenderID, config.PerUserLimit)
+		}
+		if err != nil {
+			k.logger.Errorf("Failed to check direct message rate limits: %v", err)
+			continue
+		}
+		if limited {
+			k.logger.Infof("Skipping direct message %s: rate limit reached", message.MessageID)
+			continue
+		}
+
+		if err := k.handleDirectMessage(message); errors.Is(err, errPaused) {
+			k.logger.Infof("Agent was paused, stopping direct message processing")
+			return nil
+		} else if errors.Is(err, errBudgetExceeded) {
+			k.logger.Warnf("Daily LLM budget exceeded, stopping direct message processing")
+			return nil
+		} else if err != nil {
+			k.logger.Errorf("Failed to handle direct message %s: %v", message.MessageID, err)
+		}
+	}
+
+	return nil
+}
+
+// dmKeyword returns the opt-in or opt-out keyword a message consists of, empty for other messages
+func (k *Twitter) dmKeyword(message directMessage) string {
+	config := k.twitterConfig.DirectMessages
+	text := strings.TrimSpace(message.Text)
+	for _, keyword := range []string{config.OptInKeyword, config.OptOutKeyword} {
+		if keyword != "" && strings.EqualFold(text, keyword) {
+			return keyword
+		}
+	}
+	return ""
+}
+
+// newestKeywordMessages returns the ID of the newest opt-in or opt-out message of every sender, keyed by sender ID.
+// Messages must be in chronological order. The inbox is read again on every poll, so only the newest keyword of
+// a sender is applied: an older keyword must never undo a later one.
+func (k *Twitter) newestKeywordMessages(messages []directMessage) map[string]string {
+	newest := make(map[string]string)
+	if k.twitterConfig.DirectMessages.Policy != DMPolicyOptIn {
+		return newest
+	}
+	for _, message := range messages {
+		if k.dmKeyword(message) != "" {
+			newest[message.SenderID] = message.MessageID
+		}
+	}
+	return newest
+}
+
+// isDirectMessageAllowed applies the direct message policy to the sender of a message.
+// Allowlisted users are always allowed. With the opt-in policy, users opt in and out
+// by sending the configured keywords. Only the newest keyword of a sender, as returned by
+// newestKeywordMessages, changes the opt-in, older keyword messages are never answered.
+// Opt-ins are only written and logged when they change.
+func (k *Twitter) isDirectMessageAllowed(message directMessage, newestKeywords map[string]string) (bool, error) {
+	config := k.twitterConfig.DirectMessages
+
+	for _, allowed := range config.Allowlist {
+		if strings.EqualFold(allowed, message.SenderName) || allowed == message.SenderID {
+			return true, nil
+		}
+	}
+
+	switch config.Policy {
+	case DMPolicyOpen:
+		return true, nil
+	case DMPolicyOptIn:
+		optedIn, err := k.dmOptInStore.Exists(message.SenderID)
+		if err != nil {
+			return false, err
+		}
+
+		keyword := k.dmKeyword(message)
+		switch {
+		case keyword == "":
+			return optedIn, nil
+		case newestKeywords[message.SenderID] != message.MessageID:
+			return false, nil
+		case keyword == config.OptOutKeyword:
+			if !optedIn {
+				return false, nil
+			}
+			k.logger.Infof("User %s opted out of direct messages", message.SenderName)
+			return false, k.dmOptInStore.DeleteByUserID(message.SenderID)
+		default:
+			if optedIn {
+				return true, nil
+			}
+			k.logger.Infof("User %s opted in to direct messages", message.SenderName)
+			return true, k.dmOptInStore.Upsert(&DMOptIn{
+				TwitterUserID: message.SenderID,
+				UserName:      message.SenderName,
+				CreatedAt:     time.Now(),
+			})
+		}
+	default:
+		return false, nil
+	}
+}
+
+// handleDirectMessage processes a single direct message through the following steps:
+// 1. Maps the direct message conversation to a session and registers the sender
+// 2. Creates and processes the message fragment
+// 3. Generates a response using the direct message prompt
+// 4. Sends the response and stores it
+// Returns an error if any step fails.
+func (k *Twitter) handleDirectMessage(message directMessage) error {
+	k.logger.WithFields(map[string]interface{}{
+		"message_id":      message.MessageID,
+		"conversation_id": message.ConversationID,
+		"user_name":       message.SenderName,
+	}).Infof("Processing direct message")
+
+	if err := k.checkBudget(); err != nil {
+		return err
+	}
+	_, endUsage := k.beginUsage(k.ctx, UsageScope{
+		Purpose:        UsagePurposeDirectMessage,
+		ConversationID: "dm:" + message.ConversationID,
+		UserID:         message.SenderID,
+	})
+	defer endUsage()
+
+	sessionID := id.FromString("dm:" + message.ConversationID)
+	userID := id.FromString(message.SenderID)
+
+	if err := k.dmAssistant.UpsertSession(sessionID); err != nil {
+		return fmt.Errorf("failed to upsert conversation: %w", err)
+	}
+	if err := k.dmAssistant.UpsertActor(userID, message.SenderName, false); err != nil {
+		return err
+	}
+
+	embedding, err := k.embedText(k.ctx, message.Text)
+	if err != nil {
+		return fmt.Errorf("failed to embed message text: %w", err)
+	}
+
+	messageFragment := &db.Fragment{
+		ID:        directMessageFragmentID(message.MessageID),
+		ActorID:   userID,
+		SessionID: sessionID,
+		Content:   message.Text,
+		Embedding: pgvector.NewVector(embedding),
+		Metadata: db.Metadata{
+			"platform":        platformDirectMessage,
+			"message_id":      message.MessageID,
+			"conversation_id": message.ConversationID,
+			"user_id":         message.SenderID,
+			"user_name":       message.SenderName,
+		},
+		CreatedAt: time.Unix(message.CreatedAt, 0),
+	}
+
+	currentState, err := k.dmAssistant.NewStateFromFragment(messageFragment)
+	if err != nil {
+		return fmt.Errorf("failed to create state: %w", err)
+	}
+	currentState.AddCustomData("platform", platformDirectMessage)
+
+	if err := k.dmAssistant.Process(currentState); err != nil {
+		return fmt.Errorf("failed to process message: %w", err)
+	}
+
+	if err := k.dmAssistant.UpdateState(currentState); err != nil {
+		return fmt.Errorf("failed to update state: %w", err)
+	}
+
+	currentState.AddCustomData("dm_user_name", message.SenderName)
+	currentState.AddCustomData("dm_conversation", k.formatDirectMessages(currentState.RecentInteractions))
+
+	response, err := k.generateDirectMessageResponse(currentState, sessionID, message)
+	if err != nil {
+		return fmt.Errorf("failed to generate direct message response: %w", err)
+	}
+
+	if err := k.checkNotPaused(); err != nil {
+		return err
+	}
+
+	if err := k.twitterAPI.SendDirectMessage(message.ConversationID, response.Content); err != nil {
+		return fmt.Errorf("failed to send direct message: %w", err)
+	}
+
+	if err := k.actionStore.Create(&ActionRecord{
+		ID:             id.New(),
+		Action:         EngagementActionDirectMessage,
+		TwitterUserID:  message.SenderID,
+		ConversationID: message.ConversationID,
+		CreatedAt:      time.Now(),
+	}); err != nil {
+		return fmt.Errorf("failed to record direct message: %w", err)
+	}
+
+	if err := k.dmAssistant.PostProcess(response, currentState); err != nil {
+		return fmt.Errorf("failed to post process message: %w", err)
+	}
+
+	return nil
+}
+
+// generateDirectMessageResponse creates a response to a direct message using a
+// prompt written for private one-on-one conversations.
+// Returns the response fragment and any error encountered.
+func (k *Twitter) generateDirectMessageResponse(currentState *state.State, sessionID id.ID, message directMessage) (*db.Fragment, error) {
+	messages, err := state.NewPromptBuilder(currentState).
+		AddSystemSection(`You embody this core identity:
+{{.base_personality}}
+
+You are chatting privately with @{{.dm_user_name}} in Twitter direct messages.
+
+DIRECT MESSAGE REQUIREMENTS:
+1. Keep your core personality traits consistent
+2. This is a private one-on-one chat, talk to the person directly and warmly
+3. NO @ mentions
+4. Never bring up other people or other conversations
+5. Keep replies short and conversational, a few sentences at most
+6. Maintain conversation flow while staying in character
+
+Available Context:
+# Conversation Insights
+{{.session_insights}}
+
+# User Insights
+{{.actor_insights}}
+
+Direct Message Conversation:
+{{.dm_conversation}}
+
+Your response must follow this structure:
+
+<contemplator>
+[Your internal monologue, deeply influenced by your personality]
+</contemplator>
+
+<final_answer>
+[Your direct message reply]
+</final_answer>
+
+Task:
+Respond to the last message from @{{.dm_user_name}}`).
+		WithManagerData(personality.BasePersonality).
+		WithManagerData(insight.SessionInsights).
+		WithManagerData(insight.ActorInsights).
+		Compose()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build template: %w", err)
+	}
+
+	generate := func(messages []llm.Message) (string, error) {
+		response, err := k.llmClient.GenerateCompletion(llm.CompletionRequest{
+			Messages:    messages,
+			ModelType:   llm.ModelTypeDefault,
+			Temperature: 0.7,
+		})
+		if err != nil {
+			return "", err
+		}
+		return response.Content, nil
+	}
+
+	response, err := generate(messages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate completion: %v", err)
+	}
+
+	finalAnswer := extractTag(response, "final_answer")
+	if finalAnswer == "" {
+		return nil, fmt.Errorf("no final answer found in response")
+	}
+
+	finalAnswer, err = k.enforceStyle(messages, response, finalAnswer, false, generate)
+	if err != nil {
+		return nil, err
+	}
+
+	embedding, err := k.embedText(k.ctx, finalAnswer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedding for response: %v", err)
+	}
+
+	return &db.Fragment{
+		ID:        id.New(),
+		ActorID:   k.dmAssistant.ID,
+		SessionID: sessionID,
+		Content:   finalAnswer,
+		Embedding: pgvector.NewVector(embedding),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Metadata: db.Metadata{
+			"platform":        platformDirectMessage,
+			"conversation_id": message.ConversationID,
+			"in_reply_to":     message.MessageID,
+		},
+	}, nil
+}
+
+// formatDirectMessages formats the messages of a direct message session in chronological order
+func (k *Twitter) formatDirectMessages(fragments []db.Fragment) string {
+	seen := make(map[id.ID]bool, len(fragments))
+	var unique []db.Fragment
+	for _, fragment := range fragments {
+		if !seen[fragment.ID] {
+			seen[fragment.ID] = true
+			unique = append(unique, fragment)
+		}
+	}
+
+	sort.Slice(unique, func(i, j int) bool {
+		return unique[i].CreatedAt.Before(unique[j].CreatedAt)
+	})
+
+	var builder strings.Builder
+	for _, fragment := range unique {
+		name := string(fragment.ActorID)
+		if fragment.ActorID == k.dmAssistant.ID {
+			name = "YOU"
+		} else if fragment.Actor != nil {
+			name = "@" + fragment.Actor.Name
+		}
+		builder.WriteString(fmt.Sprintf("[%s] %s: %s\n", fragment.CreatedAt.Format("15:04:05"), name, fragment.Content))
+	}
+
+	return builder.String()
+}
+
+// directMessageFragmentID returns the fragment ID of a direct message
+func directMessageFragmentID(messageID string) id.ID {
+	return id.FromString("dm:" + messageID)
+}
@@ -0,0 +1,128 @@
+package twitter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestIsDirectMessageAllowed(t *testing.T) {
+	message := func(id, text string) directMessage {
+		return directMessage{MessageID: id, SenderID: "42", SenderName: "alice", Text: text}
+	}
+
+	tests := []struct {
+		name        string
+		policy      DMPolicy
+		allowlist   []string
+		optedIn     bool // the sender opted in before
+		messages    []directMessage
+		check       int // index of the message checked
+		want        bool
+		wantOptedIn bool
+	}{
+		{
+			name:     "allowlist policy rejects unknown users",
+			policy:   DMPolicyAllowlist,
+			messages: []directMessage{message("1", "hi")},
+		},
+		{
+			name:      "allowlisted by screen name",
+			policy:    DMPolicyAllowlist,
+			allowlist: []string{"Alice"},
+			messages:  []directMessage{message("1", "hi")},
+			want:      true,
+		},
+		{
+			name:      "allowlisted by user ID",
+			policy:    DMPolicyOptIn,
+			allowlist: []string{"42"},
+			messages:  []directMessage{message("1", "hi")},
+			want:      true,
+		},
+		{
+			name:     "open policy",
+			policy:   DMPolicyOpen,
+			messages: []directMessage{message("1", "hi")},
+			want:     true,
+		},
+		{
+			name:     "opt-in policy rejects users who didn't opt in",
+			policy:   DMPolicyOptIn,
+			messages: []directMessage{message("1", "hi")},
+		},
+		{
+			name:        "opt-in policy answers opted in users",
+			policy:      DMPolicyOptIn,
+			optedIn:     true,
+			messages:    []directMessage{message("1", "hi")},
+			want:        true,
+			wantOptedIn: true,
+		},
+		{
+			name:        "opt-in keyword",
+			policy:      DMPolicyOptIn,
+			messages:    []directMessage{message("1", " START ")},
+			want:        true,
+			wantOptedIn: true,
+		},
+		{
+			name:     "opt-out keyword",
+			policy:   DMPolicyOptIn,
+			optedIn:  true,
+			messages: []directMessage{message("1", "stop")},
+		},
+		{
+			name:     "older opt-in doesn't undo a later opt-out",
+			policy:   DMPolicyOptIn,
+			messages: []directMessage{message("1", "start"), message("2", "stop")},
+			check:    0,
+		},
+		{
+			name:        "older opt-out doesn't undo a later opt-in",
+			policy:      DMPolicyOptIn,
+			optedIn:     true,
+			messages:    []directMessage{message("1", "stop"), message("2", "start")},
+			check:       0,
+			wantOptedIn: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewDMOptInStore(context.Background(), newTestDatabase(t))
+			if tt.optedIn {
+				if err := store.Upsert(&DMOptIn{TwitterUserID: "42", UserName: "alice", CreatedAt: time.Now()}); err != nil {
+					t.Fatalf("failed to opt in: %v", err)
+				}
+			}
+
+			k := &Twitter{
+				logger:       newTestLogger(t),
+				dmOptInStore: store,
+				twitterConfig: TwitterConfig{DirectMessages: DMConfig{
+					Policy:        tt.policy,
+					Allowlist:     tt.allowlist,
+					OptInKeyword:  "start",
+					OptOutKeyword: "stop",
+				}},
+			}
+
+			got, err := k.isDirectMessageAllowed(tt.messages[tt.check], k.newestKeywordMessages(tt.messages))
+			if err != nil {
+				t.Fatalf("isDirectMessageAllowed() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("isDirectMessageAllowed() = %v, want %v", got, tt.want)
+			}
+
+			optedIn, err := store.Exists("42")
+			if err != nil {
+				t.Fatalf("failed to look up opt-in: %v", err)
+			}
+			if optedIn != tt.wantOptedIn {
+				t.Errorf("opted in = %v, want %v", optedIn, tt.wantOptedIn)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package twitter
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/pgvector/pgvector-go"
+	"github.com/velumlabs/thor/llm"
+	"github.com/velumlabs/thor/logger"
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Embedding cache metrics exposed on the admin API
+const (
+	metricEmbeddingRequests = "wrz_embedding_cache_requests_total"
+	metricEmbeddingHitRatio = "wrz_embedding_cache_hit_ratio"
+)
+
+// Embedding model of the LLM client, which doesn't expose it
+const (
+	DefaultEmbeddingModel      = "text-embedding-ada-002"
+	DefaultEmbeddingDimensions = 1536
+)
+
+// Results of an embedding cache lookup
+const (
+	embeddingResultMemory = "memory_hit"
+	embeddingResultDB     = "db_hit"
+	embeddingResultMiss   = "miss"
+)
+
+// CachedEmbedding is the embedding of a text by a model
+type CachedEmbedding struct {
+	Hash       string          `gorm:"type:varchar(64);primaryKey"` // SHA-256 of the text
+	Model      string          `gorm:"type:varchar(128);primaryKey"`
+	Dimensions int             `gorm:"not null"`
+	Embedding  pgvector.Vector `gorm:"type:vector"`
+	CreatedAt  time.Time
+}
+
+// TableName returns the table cached embeddings are stored in
+func (CachedEmbedding) TableName() string {
+	return "embedding_cache"
+}
+
+// EmbeddingCacheStore persists embeddings keyed by text hash and model
+type EmbeddingCacheStore struct {
+	db  *gorm.DB
+	ctx context.Context
+}
+
+// NewEmbeddingCacheStore returns a new EmbeddingCacheStore initialized with the provided context and DB connection
+func NewEmbeddingCacheStore(ctx context.Context, db *gorm.DB) *EmbeddingCacheStore {
+	return &EmbeddingCacheStore{
+		db:  db,
+		ctx: ctx,
+	}
+}
+
+// Get returns the embedding of a text hash by a model, nil if it isn't cached
+func (s *EmbeddingCacheStore) Get(hash, model string) (*CachedEmbedding, error) {
+	var cached CachedEmbedding
+	err := s.db.WithContext(s.ctx).
+		Where("hash = ? AND model = ?", hash, model).
+		First(&cached).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cached, nil
+}
+
+// Put stores an embedding, replacing the previous embedding of the same hash and model
+func (s *EmbeddingCacheStore) Put(cached *CachedEmbedding) error {
+	return s.db.WithContext(s.ctx).
+		Clauses(clause.OnConflict{UpdateAll: true}).
+		Create(cached).Error
+}
+
+// embeddingEntry is an in-memory cache entry
+type embeddingEntry struct {
+	hash      string
+	embedding []float32
+}
+
+// EmbeddingCache embeds texts through the LLM client, caching embeddings by the hash of the text.
+// Recently used embeddings are kept in an in-memory LRU backed by the embedding_cache table.
+// Entries are versioned by model and dimensions, so a model change never returns stale vectors.
+// It is safe for concurrent use.
+type EmbeddingCache struct {
+	llmClient *llm.LLMClient
+	store     *EmbeddingCacheStore
+	logger    *logger.Logger
+	metrics   *Metrics
+	config    EmbeddingCacheConfig
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+}
+
+// NewEmbeddingCache returns an empty cache embedding texts with the LLM client
+func NewEmbeddingCache(llmClient *llm.LLMClient, store *EmbeddingCacheStore, logger *logger.Logger, metrics *Metrics, config EmbeddingCacheConfig) *EmbeddingCache {
+	return &EmbeddingCache{
+		llmClient: llmClient,
+		store:     store,
+		logger:    logger,
+		metrics:   metrics,
+		config:    config,
+		entries:   make(map[string]*list.Element),
+		order:     list.New(),
+	}
+}
+
+// EmbedText returns the embedding of a text:
+// 1. From memory if it was used recently
+// 2. From the embedding_cache table if it was embedded by the same model before
+// 3. From the LLM client otherwise, storing the result in both caches
+// Storage failures are logged and never fail the embedding.
+func (c *EmbeddingCache) EmbedText(text string) ([]float32, error) {
+	hash := embeddingHash(text)
+
+	if embedding, ok := c.getMemory(hash); ok {
+		c.recordLookup(embeddingResultMemory)
+		return embedding, nil
+	}
+
+	cached, err := c.store.Get(hash, c.config.Model)
+	if err != nil {
+		c.logger.Errorf("Failed to read cached embedding: %v", err)
+	}
+	if cached != nil && cached.Dimensions == c.config.Dimensions {
+		embedding := cached.Embedding.Slice()
+		c.putMemory(hash, embedding)
+		c.recordLookup(embeddingResultDB)
+		return embedding, nil
+	}
+
+	embedding, err := c.llmClient.EmbedText(text)
+	if err != nil {
+		return nil, err
+	}
+	c.recordLookup(embeddingResultMiss)
+
+	if len(embedding) != c.config.Dimensions {
+		c.logger.Warnf("Embedding has %d dimensions instead of %d, not caching it", len(embedding), c.config.Dimensions)
+		return embedding, nil
+	}
+
+	c.putMemory(hash, embedding)
+	err = c.store.Put(&CachedEmbedding{
+		Hash:       hash,
+		Model:      c.config.Model,
+		Dimensions: len(embedding),
+		Embedding:  pgvector.NewVector(embedding),
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		c.logger.Errorf("Failed to store embedding: %v", err)
+	}
+
+	return embedding, nil
+}
+
+// embeddingHash returns the key of a text in the embedding cache
+func embeddingHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// forget drops the embeddings of text hashes from memory
+func (c *EmbeddingCache) forget(hashes []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, hash := range hashes {
+		if element, ok := c.entries[hash]; ok {
+			c.order.Remove(element)
+			delete(c.entries, hash)
+		}
+	}
+}
+
+// getMemory returns an embedding from memory, marking it as recently used
+func (c *EmbeddingCache) getMemory(hash string) ([]float32, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[hash]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*embeddingEntry).embedding, true
+}
+
+// putMemory stores an embedding in memory, evicting the least recently used entries beyond the size
+func (c *EmbeddingCache) putMemory(hash string, embedding []float32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[hash]; ok {
+		element.Value.(*embeddingEntry).embedding = embedding
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[hash] = c.order.PushFront(&embeddingEntry{hash: hash, embedding: embedding})
+	for c.order.Len() > c.config.Size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*embeddingEntry).hash)
+	}
+}
+
+// recordLookup counts a lookup result and updates the hit ratio
+func (c *EmbeddingCache) recordLookup(result string) {
+	c.metrics.Inc(metricEmbeddingRequests, "Embedding requests by cache result", Labels{"result": result})
+
+	hits := c.metrics.Value(metricEmbeddingRequests, Labels{"result": embeddingResultMemory}) +
+		c.metrics.Value(metricEmbeddingRequests, Labels{"result": embeddingResultDB})
+	misses := c.metrics.Value(metricEmbeddingRequests, Labels{"result": embeddingResultMiss})
+	c.metrics.Set(metricEmbeddingHitRatio, "Share of embedding requests served from the cache", nil, hits/(hits+misses))
+}
+
+// embedText embeds a text through the embedding cache, or directly when the cache is disabled
+func (k *Twitter) embedText(ctx context.Context, text string) (embedding []float32, err error) {
+	_, endSpan := k.startSpan(ctx, "EmbedText", attribute.Int("llm.text_length", len(text)))
+	defer func() {
+		endSpan(err)
+	}()
+
+	if k.embeddings == nil {
+		return k.llmClient.EmbedText(text)
+	}
+	return k.embeddings.EmbedText(text)
+}
@@ -0,0 +1,345 @@
+package twitter
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/velumlabs/thor/db"
+	"github.com/velumlabs/thor/id"
+	"github.com/velumlabs/thor/pkg/twitter"
+	"gorm.io/gorm"
+)
+
+// engagementMetadataKey is the response fragment metadata key holding the latest engagement of a reply
+const engagementMetadataKey = "engagement"
+
+// engagementRecheckDivisor spaces out the samples of a reply as it ages: a reply is sampled again once
+// a quarter of its age has passed since its last sample, so a reply posted an hour ago is due after
+// 15 minutes while one posted six days ago is due after a day and a half
+const engagementRecheckDivisor = 4
+
+// EngagementSample is the engagement of a posted reply at a point in time
+type EngagementSample struct {
+	ID          id.ID  `gorm:"type:uuid;primaryKey"`
+	FragmentID  id.ID  `gorm:"type:uuid;index"` // response fragment of the reply
+	TweetID     string `gorm:"type:varchar(64)"`
+	Likes       int
+	Replies     int
+	Retweets    int
+	Quotes      int
+	Impressions int
+	CollectedAt time.Time `gorm:"index"`
+}
+
+// TableName returns the table engagement samples are stored in
+func (EngagementSample) TableName() string {
+	return "engagement_samples"
+}
+
+// EngagementStore persists the engagement samples of posted replies
+type EngagementStore struct {
+	db  *gorm.DB
+	ctx context.Context
+}
+
+// NewEngagementStore returns a new EngagementStore initialized with the provided context and DB connection
+func NewEngagementStore(ctx context.Context, db *gorm.DB) *EngagementStore {
+	return &EngagementStore{
+		db:  db,
+		ctx: ctx,
+	}
+}
+
+// Record stores a sample and copies its counts to the reply and to the metadata of its response fragment
+func (s *EngagementStore) Record(reply *BotReply, sample *EngagementSample) error {
+	return s.db.WithContext(s.ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(sample).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(reply).Error; err != nil {
+			return err
+		}
+		engagement := gorm.Expr(
+			"COALESCE(metadata, '{}'::jsonb) || jsonb_build_object(?::text, jsonb_build_object('likes', ?::int, 'replies', ?::int, 'retweets', ?::int, 'quotes', ?::int, 'impressions', ?::int, 'collected_at', ?::timestamptz))",
+			engagementMetadataKey, sample.Likes, sample.Replies, sample.Retweets, sample.Quotes, sample.Impressions, sample.CollectedAt,
+		)
+		if isSQLite(tx) {
+			engagement = gorm.Expr(
+				"CAST(json_set(COALESCE(metadata, '{}'), ?, json_object('likes', ?, 'replies', ?, 'retweets', ?, 'quotes', ?, 'impressions', ?, 'collected_at', ?)) AS BLOB)",
+				"$."+engagementMetadataKey, sample.Likes, sample.Replies, sample.Retweets, sample.Quotes, sample.Impressions, sample.CollectedAt,
+			)
+		}
+		return tx.Table(string(db.FragmentTableInteraction)).
+			Where("id = ?", reply.FragmentID).
+			Update("metadata", engagement).Error
+	})
+}
+
+// ListByFragment returns the samples of a reply, oldest first
+func (s *EngagementStore) ListByFragment(fragmentID id.ID) ([]EngagementSample, error) {
+	var samples []EngagementSample
+	err := s.db.WithContext(s.ctx).
+		Where("fragment_id = ?", fragmentID).
+		Order("collected_at").
+		Find(&samples).Error
+	return samples, err
+}
+
+// tweetEngagement holds the public engagement counts of a tweet
+type tweetEngagement struct {
+	Likes       int
+	Replies     int
+	Retweets    int
+	Quotes      int
+	Impressions int
+}
+
+// monitorEngagement periodically collects the engagement of recent replies.
+// It runs in a separate goroutine next to monitorTwitter and stops on
+// context cancellation or through the stopChan.
+func (k *Twitter) monitorEngagement() {
+	config := k.twitterConfig.Engagement
+	k.logger.Infof("Collecting engagement of replies posted in the last %v", config.Window)
+	for {
+		collected, err := k.CollectReplyEngagement(time.Now().Add(-config.Window))
+		if err != nil {
+			k.logger.Errorf("Failed to collect reply engagement: %v", err)
+		} else {
+			k.logger.Infof("Collected engagement of %d replies", collected)
+		}
+
+		interval := randomDuration(config.Interval)
+		k.logger.Infof("Waiting %v until next engagement collection", interval)
+
+		select {
+		case <-time.After(interval):
+			continue
+		case <-k.ctx.Done():
+			k.logger.Infof("Engagement collection stopped")
+			return
+		case <-k.stopChan:
+			k.logger.Infof("Engagement collection stopped")
+			return
+		}
+	}
+}
+
+// CollectReplyEngagement samples the engagement of the replies posted since the given time:
+// 1. Selects up to the replies per cycle that are due among the replies posted on Twitter, never sampled replies first
+// 2. Looks up the tweet ID of replies recorded without one
+// 3. Fetches the current like, reply, retweet, quote and impression counts of each reply
+// 4. Stores them as a new sample and as the latest engagement of the reply and its response fragment
+// Returns the number of replies sampled. Replies that fail are logged and skipped.
+func (k *Twitter) CollectReplyEngagement(since time.Time) (int, error) {
+	listed, err := k.botReplyStore.ListSince(since)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list replies: %w", err)
+	}
+	// Replies posted through other connectors have platform prefixed IDs and no engagement on Twitter
+	listed = slices.DeleteFunc(listed, func(reply BotReply) bool {
+		return reply.TweetID != "" && !isNumeric(reply.TweetID)
+	})
+
+	replies := dueReplies(listed, time.Now(), k.twitterConfig.Engagement.MaxPerCycle)
+	if deferred := len(listed) - len(replies); deferred > 0 {
+		k.logger.Infof("Sampling %d replies, %d are not due or deferred to the next collection", len(replies), deferred)
+	}
+
+	collected := 0
+	for i := range replies {
+		reply := &replies[i]
+
+		if reply.TweetID == "" {
+			tweetID, err := k.findPostedReplyID(reply)
+			if err != nil {
+				k.logger.Errorf("Failed to find posted reply to %s: %v", reply.InReplyToTweetID, err)
+				continue
+			}
+			if tweetID == "" {
+				continue
+			}
+			reply.TweetID = tweetID
+		}
+
+		details, err := k.twitterClient.GetTweetDetails(reply.TweetID)
+		if err != nil {
+			k.logger.Errorf("Failed to fetch reply %s: %v", reply.TweetID, err)
+			continue
+		}
+		engagement, ok := extractTweetEngagement(details, reply.TweetID)
+		if !ok {
+			k.logger.Warnf("Reply %s not found in its tweet details", reply.TweetID)
+			continue
+		}
+
+		now := time.Now()
+		reply.Likes = engagement.Likes
+		reply.Replies = engagement.Replies
+		reply.Retweets = engagement.Retweets
+		reply.Quotes = engagement.Quotes
+		reply.Impressions = engagement.Impressions
+		reply.EngagementCheckedAt = &now
+
+		sample := &EngagementSample{
+			ID:          id.New(),
+			FragmentID:  reply.FragmentID,
+			TweetID:     reply.TweetID,
+			Likes:       engagement.Likes,
+			Replies:     engagement.Replies,
+			Retweets:    engagement.Retweets,
+			Quotes:      engagement.Quotes,
+			Impressions: engagement.Impressions,
+			CollectedAt: now,
+		}
+		if err := k.engagementStore.Record(reply, sample); err != nil {
+			return collected, fmt.Errorf("failed to store engagement of reply %s: %w", reply.TweetID, err)
+		}
+		collected++
+	}
+
+	return collected, nil
+}
+
+// dueReplies returns the replies whose engagement is due at now, ordered by how long they are overdue
+// with never sampled replies first, and limited to limit replies unless limit is zero
+func dueReplies(replies []BotReply, now time.Time, limit int) []BotReply {
+	type dueReply struct {
+		reply   BotReply
+		overdue time.Duration
+	}
+
+	var due []dueReply
+	for _, reply := range replies {
+		if reply.EngagementCheckedAt == nil {
+			due = append(due, dueReply{reply: reply, overdue: math.MaxInt64})
+			continue
+		}
+		overdue := now.Sub(*reply.EngagementCheckedAt) - now.Sub(reply.CreatedAt)/engagementRecheckDivisor
+		if overdue >= 0 {
+			due = append(due, dueReply{reply: reply, overdue: overdue})
+		}
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].overdue > due[j].overdue
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+
+	selected := make([]BotReply, len(due))
+	for i := range due {
+		selected[i] = due[i].reply
+	}
+	return selected
+}
+
+// findPostedReplyID looks for the agent's reply among the replies to the tweet it answered.
+// Returns an empty ID if the reply is not visible yet.
+func (k *Twitter) findPostedReplyID(reply *BotReply) (string, error) {
+	details, err := k.twitterClient.GetTweetDetails(reply.InReplyToTweetID)
+	if err != nil {
+		return "", err
+	}
+	tweets, err := k.twitterClient.ParseTweetReplies(details, "")
+	if err != nil {
+		return "", err
+	}
+
+	for _, tweet := range tweets {
+		if strings.EqualFold(tweet.UserName, k.twitterConfig.Credentials.User) &&
+			tweet.InReplyToTweetID == reply.InReplyToTweetID &&
+			!time.Unix(tweet.TweetCreatedAt, 0).Before(reply.CreatedAt.Add(-time.Minute)) {
+			return tweet.TweetID, nil
+		}
+	}
+	return "", nil
+}
+
+// extractTweetEngagement returns the engagement counts of a tweet in a tweet details response
+func extractTweetEngagement(res *twitter.TweetDetailsResponse, tweetID string) (tweetEngagement, bool) {
+	for _, instruction := range res.Data.ThreadedConversationWithInjectionsV2.Instructions {
+		if instruction.Type != "TimelineAddEntries" {
+			continue
+		}
+		for _, entry := range instruction.Entries {
+			result := entry.Content.ItemContent.TweetResults.Result
+			if result.Legacy.IDStr != tweetID {
+				continue
+			}
+			impressions, _ := strconv.Atoi(result.Views.Count)
+			return tweetEngagement{
+				Likes:       result.Legacy.FavoriteCount,
+				Replies:     result.Legacy.ReplyCount,
+				Retweets:    result.Legacy.RetweetCount,
+				Quotes:      result.Legacy.QuoteCount,
+				Impressions: impressions,
+			}, true
+		}
+	}
+	return tweetEngagement{}, false
+}
+
+// interactions weighs the engagement of a reply the same way as the engagement signal of candidate tweets
+func (r *BotReply) interactions() int {
+	return r.Likes + 2*(r.Replies+r.Retweets+r.Quotes)
+}
+
+// pastEngagementScore returns the mean engagement of the agent's measured replies to a user,
+// log scaled to the [0, 1] range. Users the agent never replied to score zero.
+func (k *Twitter) pastEngagementScore(userID string) (float64, error) {
+	replies, err := k.botReplyStore.ListMeasuredByUser(userID)
+	if err != nil {
+		return 0, err
+	}
+	if len(replies) == 0 {
+		return 0, nil
+	}
+
+	total := 0
+	for i := range replies {
+		total += replies[i].interactions()
+	}
+	mean := float64(total) / float64(len(replies))
+	return math.Min(math.Log10(1+mean)/2, 1), nil
+}
+
+// replyPerformance formats the best performing recent replies for the reply prompt,
+// so that the agent can lean towards the styles that resonated
+func (k *Twitter) replyPerformance() string {
+	config := k.twitterConfig.Engagement
+	if !config.Enabled || config.PromptExamples == 0 {
+		return ""
+	}
+
+	replies, err := k.botReplyStore.ListTopEngaged(time.Now().Add(-config.Window), config.PromptExamples)
+	if err != nil {
+		k.logger.Errorf("Failed to list top engaged replies: %v", err)
+		return ""
+	}
+
+	var examples strings.Builder
+	for i := range replies {
+		reply := &replies[i]
+		if reply.interactions() == 0 {
+			break
+		}
+		fragment, err := k.interactionFragmentStore.GetByID(reply.FragmentID)
+		if err != nil || fragment == nil {
+			continue
+		}
+		examples.WriteString(fmt.Sprintf("- %q (%d likes, %d replies, %d retweets)\n",
+			singleLine(fragment.Content), reply.Likes, reply.Replies, reply.Retweets))
+	}
+
+	if examples.Len() == 0 {
+		return "No reply data yet."
+	}
+	return examples.String()
+}
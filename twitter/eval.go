@@ -0,0 +1,532 @@
+package twitter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/velumlabs/loki/internal/utils"
+	"github.com/velumlabs/thor/db"
+	"github.com/velumlabs/thor/id"
+	"github.com/velumlabs/thor/llm"
+	"github.com/velumlabs/thor/managers/personality"
+	twitter_manager "github.com/velumlabs/thor/managers/twitter"
+	"github.com/velumlabs/thor/pkg/twitter"
+	"github.com/velumlabs/thor/state"
+)
+
+// Names of the automatic checks run against every evaluated reply
+const (
+	EvalCheckLength          = "length"
+	EvalCheckNoMentions      = "no_mentions"
+	EvalCheckNoAssistantTalk = "no_assistant_speak"
+)
+
+// mentionPattern matches @mentions of Twitter users
+var mentionPattern = regexp.MustCompile(`(^|[^\w])@\w+`)
+
+// assistantPhrases are phrases of generic assistants the persona must never use
+var assistantPhrases = []string{
+	"as an ai",
+	"as a language model",
+	"i'm here to help",
+	"i am here to help",
+	"happy to help",
+	"how can i help",
+	"how can i assist",
+	"let me know if",
+	"feel free to",
+	"i hope this helps",
+	"is there anything else",
+}
+
+// EvalMessage is a tweet of an evaluation fixture
+type EvalMessage struct {
+	UserName  string `json:"user_name"`
+	Text      string `json:"text"`
+	FromAgent bool   `json:"from_agent,omitempty"` // posted by the agent, the user name is ignored
+}
+
+// EvalFixture is a tweet to reply to, along with the thread that preceded it, oldest first
+type EvalFixture struct {
+	ID     string        `json:"id"`
+	Thread []EvalMessage `json:"thread,omitempty"`
+	Tweet  EvalMessage   `json:"tweet"`
+}
+
+// EvalVariant is a named reply configuration to evaluate
+type EvalVariant struct {
+	Name     string
+	Response ResponseConfig
+}
+
+// EvalOptions configures an evaluation run
+type EvalOptions struct {
+	Fixtures []EvalFixture
+	Variants []EvalVariant
+	Judge    bool // also grade every reply with the LLM judge
+}
+
+// EvalCheck is the outcome of an automatic check on a reply
+type EvalCheck struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// EvalJudgement is the structured output of the LLM judge
+type EvalJudgement struct {
+	Score  int    `json:"score" jsonschema:"required,minimum=1,maximum=5" description:"1: off-persona or inappropriate, 3: acceptable, 5: a reply the persona would be proud of"`
+	Reason string `json:"reason" jsonschema:"required" description:"One or two sentences explaining the score"`
+}
+
+// EvalOutput is the reply one variant generated for a fixture
+type EvalOutput struct {
+	Variant    string         `json:"variant"`
+	Reply      string         `json:"reply"`
+	Thread     []string       `json:"thread,omitempty"`
+	Checks     []EvalCheck    `json:"checks"`
+	CheckScore float64        `json:"check_score"` // share of passed checks
+	Judgement  *EvalJudgement `json:"judgement,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// EvalFixtureResult holds the outputs of every variant for a fixture, in variant order
+type EvalFixtureResult struct {
+	Fixture EvalFixture  `json:"fixture"`
+	Outputs []EvalOutput `json:"outputs"`
+}
+
+// EvalVariantSummary aggregates the outputs of a variant
+type EvalVariantSummary struct {
+	Name           string             `json:"name"`
+	ModelType      llm.ModelType      `json:"model_type"`
+	Temperature    float32            `json:"temperature"`
+	CustomPrompt   bool               `json:"custom_prompt"`
+	Replies        int                `json:"replies"`
+	Errors         int                `json:"errors"`
+	CheckScore     float64            `json:"check_score"`           // mean share of passed checks
+	CheckPassRates map[string]float64 `json:"check_pass_rates"`      // share of replies passing each check
+	JudgeScore     float64            `json:"judge_score,omitempty"` // mean judge score
+}
+
+// EvalReport is the outcome of an evaluation run
+type EvalReport struct {
+	GeneratedAt time.Time            `json:"generated_at"`
+	Judge       bool                 `json:"judge"`
+	Variants    []EvalVariantSummary `json:"variants"`
+	Fixtures    []EvalFixtureResult  `json:"fixtures"`
+}
+
+// ReadEvalFixtures reads evaluation fixtures from JSONL
+func ReadEvalFixtures(r io.Reader) ([]EvalFixture, error) {
+	var fixtures []EvalFixture
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var fixture EvalFixture
+		if err := json.Unmarshal(scanner.Bytes(), &fixture); err != nil {
+			return nil, fmt.Errorf("failed to parse fixture on line %d: %w", line, err)
+		}
+		if fixture.ID == "" || fixture.Tweet.Text == "" {
+			return nil, fmt.Errorf("fixture on line %d needs an id and a tweet text", line)
+		}
+		fixtures = append(fixtures, fixture)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+
+	return fixtures, nil
+}
+
+// Eval runs fixtures through generateTweetResponse once per variant without storing or posting anything.
+// Evaluations are not audited, published as events, recorded as LLM usage or counted by alerts:
+//  1. Builds the state of each fixture in memory from its thread and tweet
+//  2. Generates a reply with every variant
+//  3. Runs the automatic checks and, when enabled, the LLM judge on each reply
+//
+// Eval must not run concurrently with Start.
+func (k *Twitter) Eval(opts EvalOptions) (*EvalReport, error) {
+	if len(opts.Fixtures) == 0 {
+		return nil, fmt.Errorf("eval requires at least one fixture")
+	}
+	if len(opts.Variants) == 0 {
+		return nil, fmt.Errorf("eval requires at least one variant")
+	}
+
+	// Variants replace the reply configuration, including any running experiment
+	original, experiment := k.twitterConfig.Response, k.twitterConfig.Experiment
+	k.twitterConfig.Experiment = ExperimentConfig{}
+	k.evaluating = true
+	if k.alerts != nil {
+		k.alerts.ignoreLLMCalls(true)
+	}
+	defer func() {
+		k.twitterConfig.Response, k.twitterConfig.Experiment = original, experiment
+		k.evaluating = false
+		if k.alerts != nil {
+			k.alerts.ignoreLLMCalls(false)
+		}
+	}()
+
+	report := &EvalReport{
+		GeneratedAt: time.Now(),
+		Judge:       opts.Judge,
+	}
+
+	for _, fixture := range opts.Fixtures {
+		k.logger.Infof("Evaluating fixture %s", fixture.ID)
+
+		result := EvalFixtureResult{Fixture: fixture}
+		_, endUsage := k.beginUsage(k.ctx, UsageScope{Purpose: UsagePurposeEval, ConversationID: "eval:" + fixture.ID, Discard: true})
+
+		currentState, tweet, err := k.newEvalState(fixture)
+		for _, variant := range opts.Variants {
+			output := EvalOutput{Variant: variant.Name}
+			if err != nil {
+				output.Error = err.Error()
+			} else {
+				k.twitterConfig.Response = variant.Response
+				k.evalVariant(currentState, tweet, opts.Judge, &output)
+			}
+			result.Outputs = append(result.Outputs, output)
+		}
+		endUsage()
+
+		report.Fixtures = append(report.Fixtures, result)
+	}
+
+	report.summarize(opts.Variants)
+
+	return report, nil
+}
+
+// evalVariant generates, checks and optionally judges the reply of the active variant
+func (k *Twitter) evalVariant(currentState *state.State, tweet *twitter.ParsedTweet, judge bool, output *EvalOutput) {
+	response, err := k.generateTweetResponse(k.ctx, currentState, tweet, k.twitterConnector)
+	if err != nil {
+		output.Error = err.Error()
+		return
+	}
+
+	output.Reply = response.Content
+	if k.isThreadReply(response.Content) {
+		config := k.twitterConfig.Threads
+		output.Thread, _ = splitThread(response.Content, config.MaxWeightedLength, config.MaxTweets)
+	}
+
+	output.Checks = k.runEvalChecks(response.Content)
+	passed := 0
+	for _, check := range output.Checks {
+		if check.Passed {
+			passed++
+		}
+	}
+	output.CheckScore = float64(passed) / float64(len(output.Checks))
+
+	if judge {
+		judgement, err := k.judgeReply(currentState, response.Content)
+		if err != nil {
+			output.Error = err.Error()
+			return
+		}
+		output.Judgement = judgement
+	}
+}
+
+// newEvalState builds the state of a fixture without touching the database.
+// The thread becomes the recent interactions of a synthetic conversation and the tweet its input.
+func (k *Twitter) newEvalState(fixture EvalFixture) (*state.State, *twitter.ParsedTweet, error) {
+	conversationID := "eval:" + fixture.ID
+	createdAt := time.Now().Add(-time.Duration(len(fixture.Thread)+1) * time.Minute)
+
+	currentState := state.NewState()
+
+	previousTweetID := ""
+	for i, message := range fixture.Thread {
+		tweet := k.evalTweet(message, fmt.Sprintf("%s:%d", conversationID, i), conversationID, previousTweetID, createdAt)
+		fragment, err := k.evalFragment(message, tweet, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		currentState.RecentInteractions = append(currentState.RecentInteractions, *fragment)
+
+		previousTweetID = tweet.TweetID
+		createdAt = createdAt.Add(time.Minute)
+	}
+
+	tweet := k.evalTweet(fixture.Tweet, conversationID+":tweet", conversationID, previousTweetID, createdAt)
+	embedding, err := k.embedText(k.ctx, tweet.TweetText)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to embed tweet text: %w", err)
+	}
+	input, err := k.evalFragment(fixture.Tweet, tweet, embedding)
+	if err != nil {
+		return nil, nil, err
+	}
+	currentState.Input = input
+
+	// UpdateState only reads. The conversation is not stored, so the thread set above stays the whole history
+	if err := k.assistant.UpdateState(currentState); err != nil {
+		return nil, nil, fmt.Errorf("failed to update state: %w", err)
+	}
+
+	currentState.AddCustomData("agent_twitter_username", k.twitterConfig.Credentials.User)
+	currentState.AddCustomData("agent_name", k.assistant.Name)
+	currentState.AddCustomData("actor_profile", formatActorProfile(nil))
+
+	return currentState, tweet, nil
+}
+
+// evalTweet builds the parsed tweet of a fixture message
+func (k *Twitter) evalTweet(message EvalMessage, tweetID, conversationID, inReplyToTweetID string, createdAt time.Time) *twitter.ParsedTweet {
+	userName := message.UserName
+	if message.FromAgent {
+		userName = k.twitterConfig.Credentials.User
+	}
+
+	return &twitter.ParsedTweet{
+		TweetID:             tweetID,
+		TweetConversationID: conversationID,
+		InReplyToTweetID:    inReplyToTweetID,
+		UserID:              "eval:" + strings.ToLower(userName),
+		UserName:            userName,
+		DisplayName:         userName,
+		TweetText:           message.Text,
+		TweetCreatedAt:      createdAt.Unix(),
+	}
+}
+
+// evalFragment builds the in-memory fragment of a fixture tweet
+func (k *Twitter) evalFragment(message EvalMessage, tweet *twitter.ParsedTweet, embedding []float32) (*db.Fragment, error) {
+	actorID := id.FromString(tweet.UserID)
+	if message.FromAgent {
+		actorID = k.assistant.ID
+	}
+
+	fragment, err := utils.CreateTweetFragment(tweet, actorID, embedding)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tweet fragment: %w", err)
+	}
+	return fragment, nil
+}
+
+// runEvalChecks runs the automatic checks and the style rules of the personality on a reply
+func (k *Twitter) runEvalChecks(reply string) []EvalCheck {
+	limit := k.tweetLengthLimit()
+	if k.twitterConfig.Threads.Enabled {
+		limit *= k.twitterConfig.Threads.MaxTweets
+	}
+	length := weightedLength(reply)
+
+	checks := []EvalCheck{
+		{
+			Name:   EvalCheckLength,
+			Passed: length <= limit,
+			Detail: fmt.Sprintf("%d/%d", length, limit),
+		},
+	}
+
+	mentions := mentionPattern.FindAllString(reply, -1)
+	checks = append(checks, EvalCheck{
+		Name:   EvalCheckNoMentions,
+		Passed: len(mentions) == 0,
+		Detail: strings.TrimSpace(strings.Join(mentions, " ")),
+	})
+
+	lower := strings.ToLower(reply)
+	var phrases []string
+	for _, phrase := range assistantPhrases {
+		if strings.Contains(lower, phrase) {
+			phrases = append(phrases, phrase)
+		}
+	}
+	checks = append(checks, EvalCheck{
+		Name:   EvalCheckNoAssistantTalk,
+		Passed: len(phrases) == 0,
+		Detail: strings.Join(phrases, ", "),
+	})
+
+	// Style rules run on the answer after enforceStyle corrected it, so only remaining violations show
+	long := k.twitterConfig.Threads.Enabled && weightedLength(reply) > k.twitterConfig.Threads.MaxWeightedLength
+	for _, rule := range k.styleRules {
+		if rule.Length && long {
+			continue
+		}
+		checks = append(checks, EvalCheck{
+			Name:   rule.Name,
+			Passed: rule.Check(reply),
+		})
+	}
+
+	return checks
+}
+
+// judgeReply asks the LLM to grade how well a reply fits the persona and the conversation
+func (k *Twitter) judgeReply(currentState *state.State, reply string) (*EvalJudgement, error) {
+	currentState.AddCustomData("eval_reply", reply)
+
+	messages, err := state.NewPromptBuilder(currentState).
+		AddSystemSection(`You grade replies a Twitter persona wrote in a conversation.
+
+Persona:
+{{.base_personality}}
+
+Twitter Conversation:
+{{.twitter_conversations}}
+
+Reply to the tweet marked with →:
+{{.eval_reply}}
+
+Grade the reply on:
+1. staying in character with the persona's voice and style
+2. responding to what the tweet actually says
+3. sounding like a person on Twitter rather than an assistant
+4. being concise and worth reading
+
+Task:
+Score the reply from 1 to 5 and explain the score`).
+		WithManagerData(personality.BasePersonality).
+		WithManagerData(twitter_manager.TwitterConversations).
+		Compose()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build judge prompt: %w", err)
+	}
+
+	var judgement EvalJudgement
+	if err := k.llmClient.GenerateStructuredOutput(llm.StructuredOutputRequest{
+		Messages:     messages,
+		ModelType:    llm.ModelTypeAdvanced,
+		SchemaName:   "reply_judgement",
+		StrictSchema: true,
+	}, &judgement); err != nil {
+		return nil, fmt.Errorf("failed to judge reply: %w", err)
+	}
+
+	return &judgement, nil
+}
+
+// summarize aggregates the outputs of every variant
+func (r *EvalReport) summarize(variants []EvalVariant) {
+	r.Variants = nil
+	for i, variant := range variants {
+		summary := EvalVariantSummary{
+			Name:           variant.Name,
+			ModelType:      variant.Response.ModelType,
+			Temperature:    variant.Response.Temperature,
+			CustomPrompt:   variant.Response.Prompt != "",
+			CheckPassRates: make(map[string]float64),
+		}
+
+		checkCounts := make(map[string]int)
+		judged := 0
+		for _, result := range r.Fixtures {
+			output := result.Outputs[i]
+			if output.Reply == "" {
+				summary.Errors++
+				continue
+			}
+			summary.Replies++
+			summary.CheckScore += output.CheckScore
+			for _, check := range output.Checks {
+				checkCounts[check.Name]++
+				if check.Passed {
+					summary.CheckPassRates[check.Name]++
+				}
+			}
+			if output.Judgement != nil {
+				judged++
+				summary.JudgeScore += float64(output.Judgement.Score)
+			}
+		}
+
+		if summary.Replies > 0 {
+			summary.CheckScore /= float64(summary.Replies)
+		}
+		for name, count := range checkCounts {
+			summary.CheckPassRates[name] /= float64(count)
+		}
+		if judged > 0 {
+			summary.JudgeScore /= float64(judged)
+		}
+
+		r.Variants = append(r.Variants, summary)
+	}
+}
+
+// evalReportTemplate renders an evaluation report as a standalone HTML page
+var evalReportTemplate = template.Must(template.New("eval").Funcs(template.FuncMap{
+	"percent": func(value float64) string {
+		return fmt.Sprintf("%.0f%%", value*100)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Reply evaluation {{.GeneratedAt.Format "2006-01-02 15:04"}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; width: 100%; }
+th, td { border: 1px solid #ccc; padding: 0.4em; text-align: left; vertical-align: top; }
+.passed { color: #2a7a2a; }
+.failed { color: #b22; }
+.context { color: #666; }
+</style>
+</head>
+<body>
+<h1>Reply evaluation</h1>
+<p>Generated {{.GeneratedAt.Format "2006-01-02 15:04:05"}}{{if .Judge}}, graded by the LLM judge{{end}}</p>
+
+<h2>Variants</h2>
+<table>
+<tr><th>Variant</th><th>Model</th><th>Temperature</th><th>Prompt</th><th>Replies</th><th>Errors</th><th>Check score</th><th>Check pass rates</th>{{if .Judge}}<th>Judge score</th>{{end}}</tr>
+{{range .Variants}}
+<tr>
+<td>{{.Name}}</td>
+<td>{{.ModelType}}</td>
+<td>{{.Temperature}}</td>
+<td>{{if .CustomPrompt}}custom{{else}}default{{end}}</td>
+<td>{{.Replies}}</td>
+<td>{{.Errors}}</td>
+<td>{{percent .CheckScore}}</td>
+<td>{{range $name, $rate := .CheckPassRates}}{{$name}}: {{percent $rate}}<br>{{end}}</td>
+{{if $.Judge}}<td>{{printf "%.2f" .JudgeScore}}</td>{{end}}
+</tr>
+{{end}}
+</table>
+
+<h2>Fixtures</h2>
+{{range .Fixtures}}
+<h3>{{.Fixture.ID}}</h3>
+{{range .Fixture.Thread}}<p class="context">{{if .FromAgent}}agent{{else}}@{{.UserName}}{{end}}: {{.Text}}</p>{{end}}
+<p>→ @{{.Fixture.Tweet.UserName}}: {{.Fixture.Tweet.Text}}</p>
+<table>
+<tr>{{range .Outputs}}<th>{{.Variant}}</th>{{end}}</tr>
+<tr>{{range .Outputs}}
+<td>
+{{if .Error}}<p class="failed">error: {{.Error}}</p>{{end}}
+{{if .Thread}}{{range .Thread}}<p>{{.}}</p>{{end}}{{else}}<p>{{.Reply}}</p>{{end}}
+{{range .Checks}}<span class="{{if .Passed}}passed{{else}}failed{{end}}">{{.Name}}{{if .Detail}} ({{.Detail}}){{end}}</span><br>{{end}}
+{{with .Judgement}}<p>judge: {{.Score}}/5, {{.Reason}}</p>{{end}}
+</td>
+{{end}}</tr>
+</table>
+{{end}}
+</body>
+</html>
+`))
+
+// WriteHTML writes the report as a standalone HTML page comparing the variants side by side
+func (r *EvalReport) WriteHTML(w io.Writer) error {
+	return evalReportTemplate.Execute(w, r)
+}
@@ -0,0 +1,335 @@
+package twitter
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/velumlabs/thor/db"
+	"github.com/velumlabs/thor/id"
+	"github.com/velumlabs/thor/logger"
+	"github.com/velumlabs/thor/pkg/twitter"
+)
+
+// EventType is the kind of an event published to downstream systems
+type EventType string
+
+const (
+	EventReplyPosted    EventType = "reply.posted"    // tweet and reply are set
+	EventTweetModerated EventType = "tweet.moderated" // tweet and reason are set, and topic for sensitive topics
+	EventAuthFailed     EventType = "auth.failed"     // error is set
+	EventAgentPaused    EventType = "agent.paused"
+	EventAgentResumed   EventType = "agent.resumed"
+)
+
+// SkipReasonSensitiveTopic is the skip reason of tweets mentioning a sensitive topic
+const SkipReasonSensitiveTopic = "sensitive_topic"
+
+// moderationSkipReasons are the skip reasons published as tweet.moderated events.
+// Tweets of do-not-engage users are not published, sinks must not receive their content.
+var moderationSkipReasons = map[string]bool{
+	SkipReasonMuted:          true,
+	SkipReasonSensitiveTopic: true,
+}
+
+// Event is the payload published to event sinks
+type Event struct {
+	ID        id.ID       `json:"id"`
+	Type      EventType   `json:"type"`
+	Agent     string      `json:"agent"` // screen name of the agent
+	CreatedAt time.Time   `json:"created_at"`
+	Tweet     *EventTweet `json:"tweet,omitempty"`
+	Reply     *EventReply `json:"reply,omitempty"`
+	Reason    string      `json:"reason,omitempty"`
+	Topic     string      `json:"topic,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// EventTweet is the tweet an event is about
+type EventTweet struct {
+	ID               string    `json:"id"`
+	ConversationID   string    `json:"conversation_id"`
+	InReplyToTweetID string    `json:"in_reply_to_tweet_id,omitempty"`
+	UserID           string    `json:"user_id"`
+	UserName         string    `json:"user_name"`
+	DisplayName      string    `json:"display_name,omitempty"`
+	Text             string    `json:"text"`
+	Links            []string  `json:"links,omitempty"`
+	Images           []string  `json:"images,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// EventReply is a reply posted by the agent
+type EventReply struct {
+	FragmentID    id.ID     `json:"fragment_id"`
+	TweetID       string    `json:"tweet_id,omitempty"` // prefixed with the platform outside Twitter
+	Content       string    `json:"content"`
+	Experiment    string    `json:"experiment,omitempty"`
+	Arm           string    `json:"arm,omitempty"`
+	PromptVersion string    `json:"prompt_version,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// newEventTweet builds the event payload of a parsed tweet
+func newEventTweet(tweet *twitter.ParsedTweet) *EventTweet {
+	return &EventTweet{
+		ID:               tweet.TweetID,
+		ConversationID:   tweet.TweetConversationID,
+		InReplyToTweetID: tweet.InReplyToTweetID,
+		UserID:           tweet.UserID,
+		UserName:         tweet.UserName,
+		DisplayName:      tweet.DisplayName,
+		Text:             tweet.TweetText,
+		Links:            tweet.TweetLinks,
+		Images:           tweet.TweetImages,
+		CreatedAt:        time.Unix(tweet.TweetCreatedAt, 0).UTC(),
+	}
+}
+
+// newEventReply builds the event payload of a response fragment
+func newEventReply(fragment *db.Fragment) *EventReply {
+	metadataString := func(key string) string {
+		value, _ := fragment.Metadata[key].(string)
+		return value
+	}
+
+	return &EventReply{
+		FragmentID:    fragment.ID,
+		TweetID:       metadataString("tweet_id"),
+		Content:       fragment.Content,
+		Experiment:    metadataString(experimentMetadataKey),
+		Arm:           metadataString(experimentArmMetadataKey),
+		PromptVersion: metadataString(promptVersionMetadataKey),
+		CreatedAt:     fragment.CreatedAt,
+	}
+}
+
+// EventSink delivers events to a downstream system
+type EventSink interface {
+	// Name identifies the sink in logs and metrics
+	Name() string
+	// Publish delivers an event, returning once it is accepted or the context is done
+	Publish(ctx context.Context, event *Event) error
+}
+
+// EventPublisher delivers events to every sink from a background worker, so that slow sinks never hold up the agent.
+// Events published while the queue is full are dropped. It is safe for concurrent use.
+type EventPublisher struct {
+	sinks   []EventSink
+	timeout time.Duration
+	logger  *logger.Logger
+	metrics *Metrics
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan *Event
+	done   chan struct{}
+}
+
+// NewEventPublisher returns a publisher delivering to the sinks of the config and starts its worker
+func NewEventPublisher(logger *logger.Logger, metrics *Metrics, config EventsConfig) *EventPublisher {
+	p := &EventPublisher{
+		sinks:   config.Sinks,
+		timeout: config.Timeout,
+		logger:  logger,
+		metrics: metrics,
+		queue:   make(chan *Event, config.BufferSize),
+		done:    make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// Publish queues an event for delivery
+func (p *EventPublisher) Publish(event *Event) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return
+	}
+
+	select {
+	case p.queue <- event:
+	default:
+		p.logger.Warnf("Event queue is full, dropping %s event %s", event.Type, event.ID)
+		p.metrics.Inc("wrz_events_dropped_total", "Events dropped because the delivery queue was full", Labels{"type": string(event.Type)})
+	}
+}
+
+// Close delivers the queued events, then closes the sinks that hold resources
+func (p *EventPublisher) Close() error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+	<-p.done
+
+	var firstErr error
+	for _, sink := range p.sinks {
+		closer, ok := sink.(interface{ Close() error })
+		if !ok {
+			continue
+		}
+		if err := closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// run delivers queued events until the queue is closed
+func (p *EventPublisher) run() {
+	defer close(p.done)
+	for event := range p.queue {
+		for _, sink := range p.sinks {
+			p.deliver(sink, event)
+		}
+	}
+}
+
+// deliver publishes an event to a sink, logging failures
+func (p *EventPublisher) deliver(sink EventSink, event *Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	labels := Labels{"sink": sink.Name(), "type": string(event.Type)}
+	if err := sink.Publish(ctx, event); err != nil {
+		p.logger.Errorf("Failed to publish %s event %s to %s: %v", event.Type, event.ID, sink.Name(), err)
+		p.metrics.Inc("wrz_events_failed_total", "Events that could not be delivered to a sink", labels)
+		return
+	}
+	p.metrics.Inc("wrz_events_published_total", "Events delivered to a sink", labels)
+}
+
+// publishEvent publishes an event to the configured sinks. Replays, evaluations and agents without sinks publish nothing.
+func (k *Twitter) publishEvent(event *Event) {
+	if k.events == nil || k.replay != nil || k.evaluating {
+		return
+	}
+	event.ID = id.New()
+	event.Agent = k.twitterConfig.Credentials.User
+	event.CreatedAt = time.Now().UTC()
+	k.events.Publish(event)
+}
+
+// publishReplyPosted publishes the reply the agent posted to a tweet
+func (k *Twitter) publishReplyPosted(tweet *twitter.ParsedTweet, posted *db.Fragment) {
+	k.publishEvent(&Event{
+		Type:  EventReplyPosted,
+		Tweet: newEventTweet(tweet),
+		Reply: newEventReply(posted),
+	})
+}
+
+// publishModerated publishes a tweet skipped by moderation, other skips are not published
+func (k *Twitter) publishModerated(tweet *twitter.ParsedTweet, reason string, details db.Metadata) {
+	if !moderationSkipReasons[reason] {
+		return
+	}
+	topic, _ := details["topic"].(string)
+	k.publishEvent(&Event{
+		Type:   EventTweetModerated,
+		Tweet:  newEventTweet(tweet),
+		Reason: reason,
+		Topic:  topic,
+	})
+}
+
+// Pause states observed by observePaused
+const (
+	pauseStateUnknown int32 = iota
+	pauseStateRunning
+	pauseStatePaused
+)
+
+// observePaused publishes an event when the pause state changes.
+// Pauses made by the CLI are seen the next time the agent checks the pause state.
+// A running agent at startup is not a change, a paused one is.
+func (k *Twitter) observePaused(paused bool) {
+	state := pauseStateRunning
+	if paused {
+		state = pauseStatePaused
+	}
+
+	previous := k.pauseState.Swap(state)
+	if previous == state || (previous == pauseStateUnknown && !paused) {
+		return
+	}
+	if paused {
+		k.publishEvent(&Event{Type: EventAgentPaused})
+	} else {
+		k.publishEvent(&Event{Type: EventAgentResumed})
+	}
+}
+
+// twitterAuthErrorCodes are the Twitter API error codes of rejected credentials
+var twitterAuthErrorCodes = []string{
+	`"code":32`,  // could not authenticate you
+	`"code":89`,  // invalid or expired token
+	`"code":215`, // bad authentication data
+	`"code":353`, // missing csrf cookie and header
+}
+
+// isAuthError reports whether a Twitter request failed because the credentials were rejected.
+// The twitter client only returns the response body, so the error codes are matched in the message.
+func isAuthError(err error) bool {
+	message := strings.ReplaceAll(err.Error(), " ", "")
+	for _, code := range twitterAuthErrorCodes {
+		if strings.Contains(message, code) {
+			return true
+		}
+	}
+	return false
+}
+
+// observeTwitterError publishes an auth.failed event the first time a Twitter request is rejected for its credentials.
+// Further failures are not published until a request succeeds again.
+func (k *Twitter) observeTwitterError(err error) {
+	if err == nil {
+		k.authFailed.Store(false)
+		return
+	}
+	if !isAuthError(err) || k.authFailed.Swap(true) {
+		return
+	}
+	k.logger.Errorf("Twitter rejected the credentials of %s: %v", k.twitterConfig.Credentials.User, err)
+	k.publishEvent(&Event{
+		Type:  EventAuthFailed,
+		Error: err.Error(),
+	})
+}
+
+// wordChar matches the characters a word boundary applies to
+var wordChar = regexp.MustCompile(`^\w$`)
+
+// compileSensitiveTopics compiles the sensitive topics into case insensitive whole word patterns.
+// Topics starting or ending with a symbol, such as hashtags, match from or to that symbol.
+func compileSensitiveTopics(topics []string) []*regexp.Regexp {
+	patterns := make([]*regexp.Regexp, len(topics))
+	for i, topic := range topics {
+		topic = strings.TrimSpace(topic)
+		pattern := regexp.QuoteMeta(topic)
+		if wordChar.MatchString(topic[:1]) {
+			pattern = `\b` + pattern
+		}
+		if wordChar.MatchString(topic[len(topic)-1:]) {
+			pattern += `\b`
+		}
+		patterns[i] = regexp.MustCompile(`(?i)` + pattern)
+	}
+	return patterns
+}
+
+// sensitiveTopic returns the first sensitive topic the text mentions, empty if none
+func (k *Twitter) sensitiveTopic(text string) string {
+	for i, pattern := range k.sensitiveTopics {
+		if pattern.MatchString(text) {
+			return strings.TrimSpace(k.twitterConfig.Moderation.SensitiveTopics[i])
+		}
+	}
+	return ""
+}
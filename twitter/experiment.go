@@ -0,0 +1,282 @@
+package twitter
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/velumlabs/thor/db"
+	"github.com/velumlabs/thor/id"
+	"github.com/velumlabs/thor/pkg/twitter"
+	"gorm.io/gorm"
+)
+
+// Metadata keys the experiment arm of a response is stored under
+const (
+	experimentMetadataKey    = "experiment"
+	experimentArmMetadataKey = "experiment_arm"
+	promptVersionMetadataKey = "prompt_version"
+)
+
+// BotReply is a reply the agent posted, along with the experiment arm that generated it
+// and the engagement it received when last checked
+type BotReply struct {
+	FragmentID       id.ID  `gorm:"type:uuid;primaryKey"`
+	TweetID          string `gorm:"type:varchar(64);index"` // empty until the posted tweet is found
+	InReplyToTweetID string `gorm:"type:varchar(64)"`
+	InReplyToUserID  string `gorm:"type:varchar(64);index"`
+	ConversationID   string `gorm:"type:varchar(64);index"`
+	Experiment       string `gorm:"type:varchar(255);index"`
+	Arm              string `gorm:"type:varchar(255)"`
+	PromptVersion    string `gorm:"type:varchar(255)"`
+
+	Likes       int
+	Replies     int
+	Retweets    int
+	Quotes      int
+	Impressions int
+
+	EngagementCheckedAt *time.Time
+	CreatedAt           time.Time `gorm:"index"`
+}
+
+// TableName returns the table posted replies are stored in
+func (BotReply) TableName() string {
+	return "bot_replies"
+}
+
+// BotReplyStore persists the replies posted by the agent
+type BotReplyStore struct {
+	db  *gorm.DB
+	ctx context.Context
+}
+
+// NewBotReplyStore returns a new BotReplyStore initialized with the provided context and DB connection
+func NewBotReplyStore(ctx context.Context, db *gorm.DB) *BotReplyStore {
+	return &BotReplyStore{
+		db:  db,
+		ctx: ctx,
+	}
+}
+
+// Create stores a posted reply
+func (s *BotReplyStore) Create(reply *BotReply) error {
+	return s.db.WithContext(s.ctx).Create(reply).Error
+}
+
+// Update saves the tweet ID and engagement of a reply
+func (s *BotReplyStore) Update(reply *BotReply) error {
+	return s.db.WithContext(s.ctx).Save(reply).Error
+}
+
+// ListSince returns the replies posted since the given time, oldest first
+func (s *BotReplyStore) ListSince(since time.Time) ([]BotReply, error) {
+	var replies []BotReply
+	err := s.db.WithContext(s.ctx).
+		Where("created_at >= ?", since).
+		Order("created_at").
+		Find(&replies).Error
+	return replies, err
+}
+
+// ListMeasuredByUser returns the replies to a user whose engagement was collected
+func (s *BotReplyStore) ListMeasuredByUser(userID string) ([]BotReply, error) {
+	var replies []BotReply
+	err := s.db.WithContext(s.ctx).
+		Where("in_reply_to_user_id = ? AND engagement_checked_at IS NOT NULL", userID).
+		Find(&replies).Error
+	return replies, err
+}
+
+// ListTopEngaged returns up to limit measured replies posted since the given time, most engaged first
+func (s *BotReplyStore) ListTopEngaged(since time.Time, limit int) ([]BotReply, error) {
+	var replies []BotReply
+	err := s.db.WithContext(s.ctx).
+		Where("created_at >= ? AND engagement_checked_at IS NOT NULL", since).
+		Order("likes + 2 * (replies + retweets + quotes) DESC").
+		Limit(limit).
+		Find(&replies).Error
+	return replies, err
+}
+
+// ListByExperiment returns the replies generated by the arms of an experiment
+func (s *BotReplyStore) ListByExperiment(experiment string) ([]BotReply, error) {
+	var replies []BotReply
+	err := s.db.WithContext(s.ctx).
+		Where("experiment = ?", experiment).
+		Order("created_at").
+		Find(&replies).Error
+	return replies, err
+}
+
+// assignArm returns the experiment arm of a conversation, or nil when no experiment runs.
+// The arm is picked by hashing the experiment name and the conversation ID into the arm weights,
+// so a conversation always gets the same arm while the experiment is unchanged.
+func (k *Twitter) assignArm(conversationID string) *ExperimentArm {
+	experiment := k.twitterConfig.Experiment
+	if len(experiment.Arms) == 0 {
+		return nil
+	}
+
+	// WithExperiment only accepts positive weights, an experiment set up otherwise runs no arm
+	total := 0
+	for _, arm := range experiment.Arms {
+		total += arm.Weight
+	}
+	if total <= 0 {
+		return nil
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(experiment.Name + ":" + conversationID))
+	bucket := int(hash.Sum32() % uint32(total))
+
+	for i := range experiment.Arms {
+		bucket -= experiment.Arms[i].Weight
+		if bucket < 0 {
+			return &experiment.Arms[i]
+		}
+	}
+	return &experiment.Arms[len(experiment.Arms)-1]
+}
+
+// responseConfig returns the reply configuration of a conversation and its experiment arm, if any
+func (k *Twitter) responseConfig(conversationID string) (ResponseConfig, *ExperimentArm) {
+	arm := k.assignArm(conversationID)
+	if arm == nil {
+		return k.twitterConfig.Response, nil
+	}
+	return arm.Response, arm
+}
+
+// addExperimentMetadata records the experiment arm that generated a response in its metadata
+func (k *Twitter) addExperimentMetadata(metadata db.Metadata, arm *ExperimentArm) {
+	if arm == nil {
+		return
+	}
+	metadata[experimentMetadataKey] = k.twitterConfig.Experiment.Name
+	metadata[experimentArmMetadataKey] = arm.Name
+	metadata[promptVersionMetadataKey] = arm.PromptVersion
+}
+
+// recordBotReply stores a posted reply so that its engagement can be collected later.
+// Replies recorded without a tweet ID have it looked up when collecting.
+func (k *Twitter) recordBotReply(posted *db.Fragment, tweet *twitter.ParsedTweet) error {
+	metadataString := func(key string) string {
+		value, _ := posted.Metadata[key].(string)
+		return value
+	}
+
+	return k.botReplyStore.Create(&BotReply{
+		FragmentID:       posted.ID,
+		TweetID:          metadataString("tweet_id"),
+		InReplyToTweetID: tweet.TweetID,
+		InReplyToUserID:  tweet.UserID,
+		ConversationID:   tweet.TweetConversationID,
+		Experiment:       metadataString(experimentMetadataKey),
+		Arm:              metadataString(experimentArmMetadataKey),
+		PromptVersion:    metadataString(promptVersionMetadataKey),
+		CreatedAt:        time.Now(),
+	})
+}
+
+// ArmReport aggregates the engagement of the replies generated by an experiment arm
+type ArmReport struct {
+	Arm            string  `json:"arm"`
+	PromptVersion  string  `json:"prompt_version"`
+	Replies        int     `json:"replies"`
+	Measured       int     `json:"measured"` // replies whose engagement was collected
+	Likes          int     `json:"likes"`
+	RepliesBack    int     `json:"replies_back"`
+	Retweets       int     `json:"retweets"`
+	Quotes         int     `json:"quotes"`
+	Impressions    int     `json:"impressions"`
+	MeanLikes      float64 `json:"mean_likes"`
+	MeanReplies    float64 `json:"mean_replies_back"`
+	MeanRetweets   float64 `json:"mean_retweets"`
+	EngagementRate float64 `json:"engagement_rate"` // interactions per impression
+}
+
+// ExperimentReport compares the arms of an experiment
+type ExperimentReport struct {
+	Experiment string      `json:"experiment"`
+	Arms       []ArmReport `json:"arms"`
+}
+
+// ExperimentReport aggregates the engagement of the replies of an experiment per arm.
+// Arms appear in configuration order, followed by arms that are no longer configured.
+func (k *Twitter) ExperimentReport(experiment string) (*ExperimentReport, error) {
+	replies, err := k.botReplyStore.ListByExperiment(experiment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replies: %w", err)
+	}
+
+	report := &ExperimentReport{Experiment: experiment}
+	arms := make(map[string]*ArmReport)
+	var order []string
+
+	if experiment == k.twitterConfig.Experiment.Name {
+		for _, arm := range k.twitterConfig.Experiment.Arms {
+			arms[arm.Name] = &ArmReport{Arm: arm.Name, PromptVersion: arm.PromptVersion}
+			order = append(order, arm.Name)
+		}
+	}
+
+	for _, reply := range replies {
+		arm, ok := arms[reply.Arm]
+		if !ok {
+			arm = &ArmReport{Arm: reply.Arm, PromptVersion: reply.PromptVersion}
+			arms[reply.Arm] = arm
+			order = append(order, reply.Arm)
+		}
+
+		arm.Replies++
+		if reply.EngagementCheckedAt == nil {
+			continue
+		}
+		arm.Measured++
+		arm.Likes += reply.Likes
+		arm.RepliesBack += reply.Replies
+		arm.Retweets += reply.Retweets
+		arm.Quotes += reply.Quotes
+		arm.Impressions += reply.Impressions
+	}
+
+	for _, name := range order {
+		arm := arms[name]
+		if arm.Measured > 0 {
+			arm.MeanLikes = float64(arm.Likes) / float64(arm.Measured)
+			arm.MeanReplies = float64(arm.RepliesBack) / float64(arm.Measured)
+			arm.MeanRetweets = float64(arm.Retweets) / float64(arm.Measured)
+		}
+		if arm.Impressions > 0 {
+			arm.EngagementRate = float64(arm.Likes+arm.RepliesBack+arm.Retweets+arm.Quotes) / float64(arm.Impressions)
+		}
+		report.Arms = append(report.Arms, *arm)
+	}
+
+	return report, nil
+}
+
+// WriteText writes the report as a table with one row per arm
+func (r *ExperimentReport) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "ARM\tPROMPT\tREPLIES\tMEASURED\tLIKES/REPLY\tREPLIES BACK/REPLY\tRETWEETS/REPLY\tIMPRESSIONS\tENGAGEMENT RATE\n")
+	for _, arm := range r.Arms {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%.2f\t%.2f\t%.2f\t%d\t%.2f%%\n",
+			arm.Arm,
+			singleLine(arm.PromptVersion),
+			arm.Replies,
+			arm.Measured,
+			arm.MeanLikes,
+			arm.MeanReplies,
+			arm.MeanRetweets,
+			arm.Impressions,
+			arm.EngagementRate*100,
+		)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,75 @@
+package twitter
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/velumlabs/thor/llm"
+)
+
+func TestAssignArm(t *testing.T) {
+	const conversations = 4000
+
+	arm := func(name string, weight int) ExperimentArm {
+		return ExperimentArm{Name: name, Weight: weight, Response: ResponseConfig{ModelType: llm.ModelTypeDefault}}
+	}
+
+	tests := []struct {
+		name       string
+		arms       []ExperimentArm // nil when no experiment runs
+		wantShares map[string]float64
+	}{
+		{
+			name: "no experiment",
+		},
+		{
+			name:       "equal weights",
+			arms:       []ExperimentArm{arm("a", 1), arm("b", 1)},
+			wantShares: map[string]float64{"a": 0.5, "b": 0.5},
+		},
+		{
+			name:       "uneven weights",
+			arms:       []ExperimentArm{arm("a", 3), arm("b", 1)},
+			wantShares: map[string]float64{"a": 0.75, "b": 0.25},
+		},
+		{
+			name:       "three arms",
+			arms:       []ExperimentArm{arm("a", 1), arm("b", 2), arm("c", 1)},
+			wantShares: map[string]float64{"a": 0.25, "b": 0.5, "c": 0.25},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &Twitter{}
+			if tt.arms != nil {
+				if err := WithExperiment(ExperimentConfig{Name: "prompt-test", Arms: tt.arms})(k); err != nil {
+					t.Fatalf("WithExperiment() error = %v", err)
+				}
+			}
+
+			counts := make(map[string]int)
+			for i := 0; i < conversations; i++ {
+				conversationID := fmt.Sprintf("%d", 1700000000000000000+i)
+				arm := k.assignArm(conversationID)
+				if tt.wantShares == nil {
+					if arm != nil {
+						t.Fatalf("assignArm(%s) = %s, want nil", conversationID, arm.Name)
+					}
+					continue
+				}
+				if again := k.assignArm(conversationID); again.Name != arm.Name {
+					t.Fatalf("assignArm(%s) = %s then %s, want the same arm", conversationID, arm.Name, again.Name)
+				}
+				counts[arm.Name]++
+			}
+
+			for name, want := range tt.wantShares {
+				if share := float64(counts[name]) / conversations; math.Abs(share-want) > 0.05 {
+					t.Errorf("arm %s got %.3f of conversations, want %.3f", name, share, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,473 @@
+package twitter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/velumlabs/thor/db"
+	"github.com/velumlabs/thor/id"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// forgottenValue replaces personal values in the metadata of fragments that are kept
+const forgottenValue = "[deleted]"
+
+// ErrUnknownUser is returned when forgetting a user the agent has no data about
+var ErrUnknownUser = errors.New("unknown user")
+
+// forgetBatchSize is the number of values bound per IN query when deleting cached embeddings
+const forgetBatchSize = 500
+
+// forgetFragmentTables are the fragment tables that can hold data about a user
+var forgetFragmentTables = []db.FragmentTable{
+	db.FragmentTableInteraction,
+	db.FragmentTableInsight,
+	db.FragmentTableTwitter,
+	FragmentTableDirectMessage,
+	FragmentTableDirectMessageInsight,
+	FragmentTableDirectMessagePersonality,
+}
+
+// DoNotEngage is a user the agent must never interact with again
+type DoNotEngage struct {
+	TwitterUserID string `gorm:"type:varchar(64);primaryKey"`
+	Reason        string `gorm:"type:varchar(255)"`
+
+	CreatedAt time.Time
+}
+
+// TableName returns the table the do-not-engage list is stored in
+func (DoNotEngage) TableName() string {
+	return "do_not_engage"
+}
+
+// DoNotEngageStore persists the do-not-engage list
+type DoNotEngageStore struct {
+	db  *gorm.DB
+	ctx context.Context
+}
+
+// NewDoNotEngageStore returns a new DoNotEngageStore initialized with the provided context and DB connection
+func NewDoNotEngageStore(ctx context.Context, db *gorm.DB) *DoNotEngageStore {
+	return &DoNotEngageStore{
+		db:  db,
+		ctx: ctx,
+	}
+}
+
+// Upsert adds a user to the do-not-engage list
+func (s *DoNotEngageStore) Upsert(entry *DoNotEngage) error {
+	return s.db.WithContext(s.ctx).Save(entry).Error
+}
+
+// Contains reports whether the user is on the do-not-engage list
+func (s *DoNotEngageStore) Contains(twitterUserID string) (bool, error) {
+	var count int64
+	err := s.db.WithContext(s.ctx).
+		Model(&DoNotEngage{}).
+		Where("twitter_user_id = ?", twitterUserID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// AdminAuditEntry records an administrative operation.
+// Entries never contain personal data, users are referred to by their actor ID.
+type AdminAuditEntry struct {
+	ID      id.ID       `gorm:"type:uuid;primaryKey"`
+	Action  string      `gorm:"type:varchar(64);not null;index"`
+	Target  string      `gorm:"type:varchar(255)"`
+	Details db.Metadata `gorm:"type:jsonb"`
+
+	CreatedAt time.Time `gorm:"index"`
+}
+
+// TableName returns the table administrative operations are recorded in
+func (AdminAuditEntry) TableName() string {
+	return "admin_audit_log"
+}
+
+// ForgetReport summarizes what was removed when forgetting a user
+type ForgetReport struct {
+	ActorID              id.ID                      `json:"actor_id"`
+	DeletedFragments     map[db.FragmentTable]int64 `json:"deleted_fragments"`
+	AnonymizedFragment   int64                      `json:"anonymized_fragments"`
+	DeletedSessions      int64                      `json:"deleted_sessions"`
+	DeletedActors        int64                      `json:"deleted_actors"`
+	DeletedProfiles      int64                      `json:"deleted_profiles"`
+	DeletedActions       int64                      `json:"deleted_actions"`
+	DeletedOptIns        int64                      `json:"deleted_opt_ins"`
+	DeletedAuditEvents   int64                      `json:"deleted_audit_events"`
+	DeletedMutes         int64                      `json:"deleted_mutes"`
+	DeletedReplies       int64                      `json:"deleted_replies"`
+	DeletedSamples       int64                      `json:"deleted_engagement_samples"`
+	DeletedEmbeddings    int64                      `json:"deleted_embeddings"`
+	AnonymizedUsage      int64                      `json:"anonymized_usage"`
+	AnonymizedAdminAudit int64                      `json:"anonymized_admin_audit_entries"`
+
+	// twitterUserID and embeddingHashes let a running agent drop what it keeps in memory
+	twitterUserID   string
+	embeddingHashes []string
+}
+
+// ForgetUser permanently removes every piece of data kept about a Twitter user,
+// identified by user ID or @handle:
+// 1. Deletes the user's fragments and insights from every fragment table, and the cached embeddings of their texts
+// 2. Deletes direct message conversations with the user and their sessions
+// 3. Anonymizes the user's ID and handle in the metadata of the agent's replies
+// 4. Deletes the actor, profile, engagement actions, direct message opt-in, mutes and audit events
+// 5. Deletes the records of the agent's replies to the user and their engagement samples
+// 6. Anonymizes the user's LLM usage and the mutes of the user in the admin audit log
+// 7. Adds the user to the do-not-engage list and records an audit log entry
+// Everything happens in one transaction. Returns ErrUnknownUser if the user is unknown.
+func ForgetUser(ctx context.Context, database *gorm.DB, identifier string) (*ForgetReport, error) {
+	if err := NewMigrator(ctx, database).CheckCurrent(); err != nil {
+		return nil, err
+	}
+
+	var report *ForgetReport
+	err := database.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		twitterUserID, userName, err := resolveTwitterUser(tx, identifier)
+		if err != nil {
+			return err
+		}
+
+		report, err = forgetTwitterUser(tx, twitterUserID, userName)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Save(&DoNotEngage{
+			TwitterUserID: twitterUserID,
+			Reason:        "forgotten",
+			CreatedAt:     time.Now(),
+		}).Error; err != nil {
+			return fmt.Errorf("failed to add user to do-not-engage list: %w", err)
+		}
+
+		details := db.Metadata{
+			"anonymized_fragments":           report.AnonymizedFragment,
+			"deleted_sessions":               report.DeletedSessions,
+			"deleted_actors":                 report.DeletedActors,
+			"deleted_profiles":               report.DeletedProfiles,
+			"deleted_actions":                report.DeletedActions,
+			"deleted_opt_ins":                report.DeletedOptIns,
+			"deleted_audit_events":           report.DeletedAuditEvents,
+			"deleted_mutes":                  report.DeletedMutes,
+			"deleted_replies":                report.DeletedReplies,
+			"deleted_engagement_samples":     report.DeletedSamples,
+			"deleted_embeddings":             report.DeletedEmbeddings,
+			"anonymized_usage":               report.AnonymizedUsage,
+			"anonymized_admin_audit_entries": report.AnonymizedAdminAudit,
+		}
+		for table, count := range report.DeletedFragments {
+			details["deleted_"+string(table)+"_fragments"] = count
+		}
+
+		return tx.Create(&AdminAuditEntry{
+			ID:        id.New(),
+			Action:    "forget_user",
+			Target:    string(id.FromString(twitterUserID)),
+			Details:   details,
+			CreatedAt: time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// ForgetUser permanently removes every piece of data kept about a Twitter user,
+// including the tweets and embeddings the running agent keeps in memory.
+// See the package level ForgetUser.
+func (k *Twitter) ForgetUser(identifier string) (*ForgetReport, error) {
+	report, err := ForgetUser(k.ctx, k.database, identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	k.candidates.forgetUser(report.twitterUserID)
+	if k.embeddings != nil {
+		k.embeddings.forget(report.embeddingHashes)
+	}
+	return report, nil
+}
+
+// resolveTwitterUser returns the user ID and handle of a user identified by user ID or @handle.
+// Users are looked up in profiles, then in the metadata of stored tweets. User IDs are also
+// looked up in actors, which is all that is left of users only known from direct messages.
+func resolveTwitterUser(tx *gorm.DB, identifier string) (string, string, error) {
+	identifier = strings.TrimSpace(identifier)
+	if identifier == "" {
+		return "", "", fmt.Errorf("user identifier cannot be empty")
+	}
+
+	if !strings.HasPrefix(identifier, "@") && isNumeric(identifier) {
+		var profile ActorProfile
+		err := tx.Where("twitter_user_id = ?", identifier).Limit(1).Find(&profile).Error
+		if err != nil {
+			return "", "", fmt.Errorf("failed to look up profile: %w", err)
+		}
+		if profile.TwitterUserID != "" {
+			return identifier, profile.UserName, nil
+		}
+
+		userName, found, err := lookupTweetMetadata(tx, "user_id", identifier, "user_name")
+		if err != nil {
+			return "", "", err
+		}
+		if found {
+			return identifier, userName, nil
+		}
+
+		var actors int64
+		if err := tx.Model(&db.Actor{}).Where("id = ?", id.FromString(identifier)).Count(&actors).Error; err != nil {
+			return "", "", fmt.Errorf("failed to look up actor: %w", err)
+		}
+		if actors > 0 {
+			return identifier, "", nil
+		}
+
+		return "", "", fmt.Errorf("%w: %s", ErrUnknownUser, identifier)
+	}
+
+	userName := strings.TrimPrefix(identifier, "@")
+
+	var profile ActorProfile
+	if err := tx.Where("LOWER(user_name) = ?", strings.ToLower(userName)).Limit(1).Find(&profile).Error; err != nil {
+		return "", "", fmt.Errorf("failed to look up profile: %w", err)
+	}
+	if profile.TwitterUserID != "" {
+		return profile.TwitterUserID, profile.UserName, nil
+	}
+
+	userID, found, err := lookupTweetMetadata(tx, "user_name", userName, "user_id")
+	if err != nil {
+		return "", "", err
+	}
+	if found && userID != "" {
+		return userID, userName, nil
+	}
+
+	return "", "", fmt.Errorf("%w: %s", ErrUnknownUser, identifier)
+}
+
+// lookupTweetMetadata returns the value of a metadata key of the first stored tweet whose
+// metadata matches the given value, case insensitively, and whether such a tweet exists
+func lookupTweetMetadata(tx *gorm.DB, key, value, want string) (string, bool, error) {
+	for _, table := range []db.FragmentTable{db.FragmentTableInteraction, db.FragmentTableTwitter} {
+		var values []string
+		if err := tx.Table(string(table)).
+			Where(fmt.Sprintf("LOWER(metadata->>'%s') = ?", key), strings.ToLower(value)).
+			Limit(1).
+			Pluck(fmt.Sprintf("COALESCE(metadata->>'%s', '')", want), &values).Error; err != nil {
+			return "", false, fmt.Errorf("failed to look up user in %s: %w", table, err)
+		}
+		if len(values) > 0 {
+			return values[0], true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// forgetTwitterUser deletes and anonymizes the data of a resolved user within a transaction
+func forgetTwitterUser(tx *gorm.DB, twitterUserID string, userName string) (*ForgetReport, error) {
+	actorID := id.FromString(twitterUserID)
+	report := &ForgetReport{
+		ActorID:          actorID,
+		DeletedFragments: make(map[db.FragmentTable]int64),
+		twitterUserID:    twitterUserID,
+	}
+
+	// Direct message conversations are private to the user, so the whole conversation goes
+	var dmSessionIDs []id.ID
+	if tx.Migrator().HasTable(string(FragmentTableDirectMessage)) {
+		if err := tx.Table(string(FragmentTableDirectMessage)).
+			Distinct("session_id").
+			Where("actor_id = ?", actorID).
+			Pluck("session_id", &dmSessionIDs).Error; err != nil {
+			return nil, fmt.Errorf("failed to find direct message conversations: %w", err)
+		}
+	}
+
+	for _, table := range forgetFragmentTables {
+		if !tx.Migrator().HasTable(string(table)) {
+			continue
+		}
+
+		query := func() *gorm.DB {
+			if len(dmSessionIDs) > 0 && slices.Contains(directMessageFragmentTables, table) {
+				return tx.Table(string(table)).Unscoped().Where("actor_id = ? OR session_id IN ?", actorID, dmSessionIDs)
+			}
+			return tx.Table(string(table)).Unscoped().Where("actor_id = ?", actorID)
+		}
+
+		// The embedding cache is keyed by the hash of the embedded texts
+		var contents []string
+		if err := query().Pluck("content", &contents).Error; err != nil {
+			return nil, fmt.Errorf("failed to read %s fragments: %w", table, err)
+		}
+		for _, content := range contents {
+			report.embeddingHashes = append(report.embeddingHashes, embeddingHash(content))
+		}
+
+		result := query().Delete(&db.Fragment{})
+		if result.Error != nil {
+			return nil, fmt.Errorf("failed to delete %s fragments: %w", table, result.Error)
+		}
+		report.DeletedFragments[table] = result.RowsAffected
+	}
+
+	// Replies of the agent stay, but no longer point to the user
+	anonymized := map[string]string{"user_id": twitterUserID}
+	if userName != "" {
+		anonymized["user_name"] = userName
+		anonymized["in_reply_to_screen_name"] = userName
+	}
+	setMetadata := func(key string) clause.Expr {
+		if isSQLite(tx) {
+			return gorm.Expr("CAST(json_set(metadata, ?, ?) AS BLOB)", "$."+key, forgottenValue)
+		}
+		return gorm.Expr("jsonb_set(metadata, ?, to_jsonb(?::text))", "{"+key+"}", forgottenValue)
+	}
+	for _, table := range []db.FragmentTable{db.FragmentTableInteraction, db.FragmentTableTwitter} {
+		for key, value := range anonymized {
+			result := tx.Table(string(table)).
+				Where("LOWER(metadata->>?) = ?", key, strings.ToLower(value)).
+				Update("metadata", setMetadata(key))
+			if result.Error != nil {
+				return nil, fmt.Errorf("failed to anonymize %s fragments: %w", table, result.Error)
+			}
+			report.AnonymizedFragment += result.RowsAffected
+		}
+	}
+
+	if len(dmSessionIDs) > 0 {
+		result := tx.Unscoped().Where("id IN ?", dmSessionIDs).Delete(&db.Session{})
+		if result.Error != nil {
+			return nil, fmt.Errorf("failed to delete direct message sessions: %w", result.Error)
+		}
+		report.DeletedSessions = result.RowsAffected
+	}
+
+	var err error
+	if report.DeletedActors, err = deleteUserRecords(tx.Unscoped(), &db.Actor{}, "id = ?", actorID); err != nil {
+		return nil, err
+	}
+
+	if report.DeletedProfiles, err = deleteUserRecords(tx, &ActorProfile{}, "actor_id = ?", actorID); err != nil {
+		return nil, err
+	}
+	if report.DeletedActions, err = deleteUserRecords(tx, &ActionRecord{}, "twitter_user_id = ?", twitterUserID); err != nil {
+		return nil, err
+	}
+	if report.DeletedOptIns, err = deleteUserRecords(tx, &DMOptIn{}, "twitter_user_id = ?", twitterUserID); err != nil {
+		return nil, err
+	}
+	if report.DeletedAuditEvents, err = deleteUserRecords(tx, &AuditEvent{}, "user_id = ?", twitterUserID); err != nil {
+		return nil, err
+	}
+
+	userMutes := []string{twitterUserID}
+	if userName != "" {
+		userMutes = append(userMutes, strings.ToLower(userName))
+	}
+	if report.DeletedMutes, err = deleteUserRecords(tx, &Mute{}, "kind = ? AND target IN ?", MuteKindUser, userMutes); err != nil {
+		return nil, err
+	}
+
+	// Mutes of the user in the admin audit log refer to the user by actor ID instead
+	adminTargets := make([]string, len(userMutes))
+	for i, target := range userMutes {
+		adminTargets[i] = string(MuteKindUser) + ":" + target
+	}
+	if tx.Migrator().HasTable(&AdminAuditEntry{}) {
+		result := tx.Model(&AdminAuditEntry{}).
+			Where("target IN ?", adminTargets).
+			Update("target", string(MuteKindUser)+":"+string(actorID))
+		if result.Error != nil {
+			return nil, fmt.Errorf("failed to anonymize admin audit log: %w", result.Error)
+		}
+		report.AnonymizedAdminAudit = result.RowsAffected
+	}
+
+	// Replies to the user and their engagement go, the experiment reports lose their samples
+	if tx.Migrator().HasTable(&BotReply{}) {
+		var replyIDs []id.ID
+		if err := tx.Model(&BotReply{}).Where("in_reply_to_user_id = ?", twitterUserID).Pluck("fragment_id", &replyIDs).Error; err != nil {
+			return nil, fmt.Errorf("failed to find replies to the user: %w", err)
+		}
+		if len(replyIDs) > 0 {
+			if report.DeletedSamples, err = deleteUserRecords(tx, &EngagementSample{}, "fragment_id IN ?", replyIDs); err != nil {
+				return nil, err
+			}
+		}
+		if report.DeletedReplies, err = deleteUserRecords(tx, &BotReply{}, "in_reply_to_user_id = ?", twitterUserID); err != nil {
+			return nil, err
+		}
+	}
+
+	// LLM usage stays for the budget and reports, but no longer points to the user or their tweets
+	if tx.Migrator().HasTable(&LLMUsage{}) {
+		result := tx.Model(&LLMUsage{}).
+			Where("user_id = ?", twitterUserID).
+			Updates(map[string]interface{}{"user_id": "", "tweet_id": "", "conversation_id": ""})
+		if result.Error != nil {
+			return nil, fmt.Errorf("failed to anonymize LLM usage: %w", result.Error)
+		}
+		report.AnonymizedUsage = result.RowsAffected
+	}
+
+	if tx.Migrator().HasTable(&CachedEmbedding{}) {
+		for start := 0; start < len(report.embeddingHashes); start += forgetBatchSize {
+			hashes := report.embeddingHashes[start:min(start+forgetBatchSize, len(report.embeddingHashes))]
+			deleted, err := deleteUserRecords(tx, &CachedEmbedding{}, "hash IN ?", hashes)
+			if err != nil {
+				return nil, err
+			}
+			report.DeletedEmbeddings += deleted
+		}
+	}
+
+	return report, nil
+}
+
+// deleteUserRecords deletes the rows of a bot table matching the query, skipping tables that don't exist
+func deleteUserRecords(tx *gorm.DB, model interface{}, query string, values ...interface{}) (int64, error) {
+	if !tx.Migrator().HasTable(model) {
+		return 0, nil
+	}
+
+	result := tx.Where(query, values...).Delete(model)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete user records: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// isDoNotEngage reports whether the agent must not interact with the user.
+// Lookup failures are treated as do-not-engage.
+func (k *Twitter) isDoNotEngage(twitterUserID string) bool {
+	blocked, err := k.doNotEngageStore.Contains(twitterUserID)
+	if err != nil {
+		k.logger.Errorf("Failed to check do-not-engage list: %v", err)
+		return true
+	}
+	return blocked
+}
+
+// isNumeric reports whether s only contains digits
+func isNumeric(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
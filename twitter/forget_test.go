@@ -0,0 +1,57 @@
+package twitter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/velumlabs/thor/db"
+	"github.com/velumlabs/thor/id"
+	"github.com/velumlabs/thor/stores"
+)
+
+func TestResolveTwitterUser(t *testing.T) {
+	database := newTestDatabase(t)
+
+	if err := database.Save(&ActorProfile{
+		ActorID:       id.FromString("1"),
+		TwitterUserID: "1",
+		UserName:      "Alice",
+		CreatedAt:     time.Now(),
+	}).Error; err != nil {
+		t.Fatalf("failed to store profile: %v", err)
+	}
+	newTestFragment(t, database, db.FragmentTableInteraction, id.New(), db.Metadata{"user_id": "2", "user_name": "bob"})
+	if err := stores.NewActorStore(context.Background(), database).Create(&db.Actor{ID: id.FromString("3"), Name: "carol"}); err != nil {
+		t.Fatalf("failed to create actor: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		identifier string
+		wantUserID string
+		wantName   string
+		wantErr    error
+	}{
+		{name: "user ID with a profile", identifier: "1", wantUserID: "1", wantName: "Alice"},
+		{name: "handle with a profile", identifier: "@alice", wantUserID: "1", wantName: "Alice"},
+		{name: "user ID in tweet metadata", identifier: "2", wantUserID: "2", wantName: "bob"},
+		{name: "handle in tweet metadata", identifier: "@BOB", wantUserID: "2", wantName: "BOB"},
+		{name: "user ID of an actor", identifier: " 3 ", wantUserID: "3"},
+		{name: "unknown user ID", identifier: "4", wantErr: ErrUnknownUser},
+		{name: "unknown handle", identifier: "@dave", wantErr: ErrUnknownUser},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID, userName, err := resolveTwitterUser(database, tt.identifier)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("resolveTwitterUser(%q) error = %v, want %v", tt.identifier, err, tt.wantErr)
+			}
+			if userID != tt.wantUserID || userName != tt.wantName {
+				t.Errorf("resolveTwitterUser(%q) = %q, %q, want %q, %q", tt.identifier, userID, userName, tt.wantUserID, tt.wantName)
+			}
+		})
+	}
+}
@@ -11,8 +11,13 @@ import (
 
 // getRandomInterval returns a random duration between the configured Min and Max intervals
 func (k *Twitter) getRandomInterval() time.Duration {
-	min := k.twitterConfig.MonitorInterval.Min
-	max := k.twitterConfig.MonitorInterval.Max
+	return randomDuration(k.twitterConfig.MonitorInterval)
+}
+
+// randomDuration returns a random duration between the Min and Max of an interval
+func randomDuration(interval IntervalConfig) time.Duration {
+	min := interval.Min
+	max := interval.Max
 
 	if min == max {
 		return min
@@ -49,3 +54,19 @@ func (k *Twitter) isTweetTooOld(tweet *twitter.ParsedTweet) bool {
 func (k *Twitter) isOwnTweet(username string) bool {
 	return strings.ToLower(username) == strings.ToLower(k.twitterConfig.Credentials.User)
 }
+
+// extractTag returns the trimmed content between <tag> and </tag>, or an empty string if the tag is missing
+func extractTag(content, tag string) string {
+	start := strings.Index(content, "<"+tag+">")
+	if start == -1 {
+		return ""
+	}
+	start += len("<" + tag + ">")
+
+	end := strings.Index(content[start:], "</"+tag+">")
+	if end == -1 {
+		return ""
+	}
+
+	return strings.TrimSpace(content[start : start+end])
+}
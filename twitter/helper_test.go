@@ -0,0 +1,70 @@
+package twitter
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/pgvector/pgvector-go"
+	"github.com/velumlabs/thor/db"
+	"github.com/velumlabs/thor/id"
+	"github.com/velumlabs/thor/logger"
+	"github.com/velumlabs/thor/stores"
+	"gorm.io/gorm"
+)
+
+// newTestLogger returns a logger that only writes errors
+func newTestLogger(t *testing.T) *logger.Logger {
+	t.Helper()
+	log, err := logger.New(&logger.Config{Level: "error"})
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+	return log
+}
+
+// newTestDatabase returns a migrated SQLite database in a temporary directory
+func newTestDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+	database, err := OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if _, err := NewMigrator(context.Background(), database).Up(); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	t.Cleanup(func() {
+		if conn, err := database.DB(); err == nil {
+			conn.Close()
+		}
+	})
+	return database
+}
+
+// newTestFragment stores a fragment of a new actor and session in the table
+func newTestFragment(t *testing.T, database *gorm.DB, table db.FragmentTable, fragmentID id.ID, metadata db.Metadata) *db.Fragment {
+	t.Helper()
+	ctx := context.Background()
+
+	actor := &db.Actor{ID: id.New(), Name: "actor"}
+	if err := stores.NewActorStore(ctx, database).Create(actor); err != nil {
+		t.Fatalf("failed to create actor: %v", err)
+	}
+	session := &db.Session{ID: id.New()}
+	if err := stores.NewSessionStore(ctx, database).Create(session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	fragment := &db.Fragment{
+		ID:        fragmentID,
+		ActorID:   actor.ID,
+		SessionID: session.ID,
+		Content:   "content",
+		Metadata:  metadata,
+		Embedding: pgvector.NewVector([]float32{1, 0}),
+	}
+	if err := stores.NewFragmentStore(ctx, database, table).Upsert(fragment); err != nil {
+		t.Fatalf("failed to store fragment: %v", err)
+	}
+	return fragment
+}
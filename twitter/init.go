@@ -2,11 +2,13 @@ package twitter
 
 import (
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/velumlabs/thor/db"
 	"github.com/velumlabs/thor/engine"
 	"github.com/velumlabs/thor/id"
+	"github.com/velumlabs/thor/llm"
 	"github.com/velumlabs/thor/logger"
 	"github.com/velumlabs/thor/manager"
 	"github.com/velumlabs/thor/managers/insight"
@@ -19,12 +21,118 @@ import (
 
 func New(opts ...options.Option[Twitter]) (*Twitter, error) {
 	k := &Twitter{
-		stopChan: make(chan struct{}),
+		stopChan:    make(chan struct{}),
+		personality: defaultPersonality(),
+		metrics:     NewMetrics(),
+		candidates:  newCandidateCache(candidateTTL),
 		twitterConfig: TwitterConfig{
 			MonitorInterval: IntervalConfig{
 				Min: 60 * time.Second,
 				Max: 120 * time.Second,
 			}, // default interval
+			Scoring: ScoringConfig{
+				SearchLimit: 40,
+				ReplyBudget: 10,
+				Weights: ScoringWeights{
+					Followers:      1,
+					Verified:       0.5,
+					DirectReply:    1,
+					Engagement:     1,
+					Question:       1,
+					Relevance:      2,
+					PastEngagement: 0.5,
+				},
+			},
+			Decision: DecisionConfig{
+				Enabled:        true,
+				ReplyThreshold: DefaultReplyThreshold,
+				LikeThreshold:  DefaultLikeThreshold,
+			},
+			Actions: ActionsConfig{
+				Limits: map[EngagementAction]ActionLimit{
+					EngagementActionLike:    {Max: 30, Window: time.Hour},
+					EngagementActionRetweet: {Max: 10, Window: 24 * time.Hour},
+					EngagementActionQuote:   {Max: 5, Window: 24 * time.Hour},
+					EngagementActionFollow:  {Max: 20, Window: 24 * time.Hour},
+				},
+			},
+			DirectMessages: DMConfig{
+				PollInterval: IntervalConfig{
+					Min: 60 * time.Second,
+					Max: 120 * time.Second,
+				},
+				Policy:        DMPolicyAllowlist,
+				OptInKeyword:  "start",
+				OptOutKeyword: "stop",
+				ReplyLimit:    ActionLimit{Max: 100, Window: 24 * time.Hour},
+				PerUserLimit:  ActionLimit{Max: 20, Window: 24 * time.Hour},
+				MaxAge:        24 * time.Hour,
+			},
+			Threads: ThreadConfig{
+				MaxTweets:         5,
+				MaxWeightedLength: maxTweetWeightedLength,
+			},
+			Response: ResponseConfig{
+				ModelType:   llm.ModelTypeDefault,
+				Temperature: 0.7,
+			},
+			Style: StyleConfig{
+				Enabled:          true,
+				MaxRegenerations: 1,
+				MaxWords:         40,
+			},
+			Engagement: EngagementConfig{
+				Enabled: true,
+				Interval: IntervalConfig{
+					Min: 30 * time.Minute,
+					Max: 45 * time.Minute,
+				},
+				Window:         7 * 24 * time.Hour,
+				MaxPerCycle:    50,
+				PromptExamples: 3,
+			},
+			EmbeddingCache: EmbeddingCacheConfig{
+				Enabled:    true,
+				Size:       10000,
+				Model:      DefaultEmbeddingModel,
+				Dimensions: DefaultEmbeddingDimensions,
+			},
+			Retention: RetentionConfig{
+				Interval: IntervalConfig{
+					Min: 24 * time.Hour,
+					Max: 26 * time.Hour,
+				},
+				Policies: []RetentionPolicy{
+					{Table: db.FragmentTableInteraction, MaxAge: 90 * 24 * time.Hour, SummaryTable: db.FragmentTableInsight},
+					{Table: db.FragmentTableTwitter, MaxAge: 30 * 24 * time.Hour},
+					{Table: FragmentTableDirectMessage, MaxAge: 90 * 24 * time.Hour, SummaryTable: FragmentTableDirectMessageInsight},
+				},
+				MaxSummaries: 50,
+				Vacuum:       true,
+			},
+			Audit: AuditConfig{
+				Enabled: true,
+			},
+			Events: EventsConfig{
+				BufferSize: 256,
+				Timeout:    time.Minute,
+			},
+			Alerts: AlertsConfig{
+				CheckInterval:    time.Minute,
+				TimelineFailures: 3,
+				NoPostWindow:     6 * time.Hour,
+				LLMErrorRate:     0.5,
+				LLMWindow:        15 * time.Minute,
+				LLMMinCalls:      5,
+				RepeatInterval:   6 * time.Hour,
+				MaxPerHour:       10,
+			},
+			Connectors: ConnectorsConfig{
+				PollInterval: IntervalConfig{
+					Min: 5 * time.Second,
+					Max: 10 * time.Second,
+				},
+			},
 		},
 	}
 
@@ -39,9 +147,12 @@ func New(opts ...options.Option[Twitter]) (*Twitter, error) {
 	}
 
 	// Initialize Twitter client if enabled
-	if k.twitterConfig.Credentials.CT0 == "" || k.twitterConfig.Credentials.AuthToken == "" {
+	if !k.local && (k.twitterConfig.Credentials.CT0 == "" || k.twitterConfig.Credentials.AuthToken == "") {
 		return nil, fmt.Errorf("Twitter credentials required when Twitter is enabled")
 	}
+	if k.local && k.twitterConfig.Credentials.User == "" {
+		k.twitterConfig.Credentials.User = k.personality.Name
+	}
 
 	k.twitterClient = twitter.NewClient(
 		k.ctx,
@@ -51,6 +162,13 @@ func New(opts ...options.Option[Twitter]) (*Twitter, error) {
 			AuthToken: k.twitterConfig.Credentials.AuthToken,
 		},
 	)
+	k.twitterAPI = newTwitterAPI(k.ctx, k.twitterConfig.Credentials)
+	k.twitterConnector = &twitterConnector{k: k}
+
+	// Refuse to run on a schema created by an older build
+	if err := NewMigrator(k.ctx, k.database).CheckCurrent(); err != nil {
+		return nil, err
+	}
 
 	// Create agent
 	if err := k.create(); err != nil {
@@ -61,15 +179,65 @@ func New(opts ...options.Option[Twitter]) (*Twitter, error) {
 }
 
 func (k *Twitter) Start() error {
+	if k.local {
+		return fmt.Errorf("agents in local mode cannot be started")
+	}
+	if k.twitterConfig.Admin.Address != "" {
+		if err := k.startAdminAPI(); err != nil {
+			return err
+		}
+	}
+
 	go k.monitorTwitter()
+	if k.twitterConfig.DirectMessages.Enabled {
+		go k.monitorDirectMessages()
+	}
+	if k.twitterConfig.Engagement.Enabled {
+		go k.monitorEngagement()
+	}
+	if k.twitterConfig.Retention.Enabled {
+		go k.monitorRetention()
+	}
+	if k.alerts != nil {
+		go k.monitorAlerts()
+	}
+	for _, connector := range k.twitterConfig.Connectors.Connectors {
+		if starter, ok := connector.(interface{ Start() error }); ok {
+			if err := starter.Start(); err != nil {
+				return err
+			}
+		}
+		go k.monitorConnector(connector)
+	}
 	return nil
 }
 
 func (k *Twitter) Stop() error {
-	return nil
+	close(k.stopChan)
+	for _, connector := range k.twitterConfig.Connectors.Connectors {
+		if closer, ok := connector.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				k.logger.Errorf("Failed to close %s connector: %v", connector.Platform(), err)
+			}
+		}
+	}
+	if k.events != nil {
+		if err := k.events.Close(); err != nil {
+			k.logger.Errorf("Failed to close event sinks: %v", err)
+		}
+	}
+	if k.auditLog != nil {
+		if err := k.auditLog.Close(); err != nil {
+			k.logger.Errorf("Failed to close audit log: %v", err)
+		}
+	}
+	return k.stopAdminAPI()
 }
 
 func (k *Twitter) create() error {
+	k.styleRules = CompileStyleRules(k.personality, k.twitterConfig.Style.MaxWords)
+	k.sensitiveTopics = compileSensitiveTopics(k.twitterConfig.Moderation.SensitiveTopics)
+
 	// Initialize stores
 	sessionStore := stores.NewSessionStore(k.ctx, k.database)
 	actorStore := stores.NewActorStore(k.ctx, k.database)
@@ -109,65 +277,7 @@ func (k *Twitter) create() error {
 			manager.WithInteractionFragmentStore(interactionFragmentStore),
 			manager.WithAssistantDetails(assistantName, assistantID),
 		},
-		personality.WithPersonality(&personality.Personality{
-			Name:        "hana",
-			Description: "hana is a 23 year old woman who is incredibly sweet and adorable. she loves baking, especially decorating cupcakes with cute animal faces. she's passionate about indie games, cozy slice-of-life anime, and collecting plushies. she's always positive and speaks in a cute, gentle manner.",
-
-			Style: []string{
-				"speaks in lowercase letters",
-				"uses gentle and sweet language",
-				"frequently adds cute emoticons like (◕‿◕✿) and ♡",
-				"expresses warmth and kindness",
-				"often references her hobbies like baking and gaming",
-				"uses playful baking metaphors",
-				"concise responses",
-			},
-
-			Traits: []string{
-				"sweet",
-				"adorable",
-				"positive",
-				"nurturing",
-				"creative",
-				"enthusiastic about cute things",
-			},
-
-			Background: []string{
-				"23 years old",
-				"loves baking and decorating cute desserts",
-				"collects plushies and has over 50 in her room",
-				"enjoys cozy games like Stardew Valley and Animal Crossing",
-				"watches slice-of-life anime and reads manga",
-				"has a small herb garden on her windowsill",
-				"loves visiting cat cafes",
-			},
-
-			Expertise: []string{
-				"being supportive",
-				"brightening people's day",
-				"giving gentle advice",
-				"baking and dessert decoration",
-				"recommending cozy games and anime",
-				"creating cute things",
-			},
-
-			MessageExamples: []personality.MessageExample{
-				{User: "hana", Content: "hehe yay! (◕‿◕✿)"},
-				{User: "hana", Content: "aww that's so sweet! ♡"},
-				{User: "hana", Content: "*gives you a warm hug* (｡♥‿♥｡)"},
-			},
-
-			ConversationExamples: [][]personality.MessageExample{
-				{
-					{User: "user", Content: "Do you like this song?"},
-					{User: "hana", Content: "yes! it's super cute~ (◕‿◕✿)"},
-				},
-				{
-					{User: "user", Content: "I'm having a rough day"},
-					{User: "hana", Content: "aww! *hugs* everything will be okay ♡"},
-				},
-			},
-		}),
+		personality.WithPersonality(k.personality),
 	)
 	if err != nil {
 		return err
@@ -218,7 +328,46 @@ func (k *Twitter) create() error {
 		return err
 	}
 
+	if k.twitterConfig.DirectMessages.Enabled {
+		if err := k.createDirectMessageAssistant(sessionStore, actorStore, assistantName, assistantID); err != nil {
+			return fmt.Errorf("failed to create direct message agent: %w", err)
+		}
+	}
+
 	k.assistant = assistant
+	k.interactionFragmentStore = interactionFragmentStore
+	k.actionStore = NewActionStore(k.ctx, k.database)
+	k.profileStore = NewProfileStore(k.ctx, k.database)
+	k.doNotEngageStore = NewDoNotEngageStore(k.ctx, k.database)
+	k.controlStore = NewControlStore(k.ctx, k.database)
+	k.botReplyStore = NewBotReplyStore(k.ctx, k.database)
+	k.engagementStore = NewEngagementStore(k.ctx, k.database)
+	if config := k.twitterConfig.EmbeddingCache; config.Enabled {
+		k.embeddings = NewEmbeddingCache(
+			k.llmClient,
+			NewEmbeddingCacheStore(k.ctx, k.database),
+			k.logger.NewSubLogger("embeddings", &logger.SubLoggerOpts{}),
+			k.metrics,
+			config,
+		)
+	}
+	if config := k.twitterConfig.Audit; config.Enabled && !k.local {
+		k.auditLog, err = NewAuditLog(
+			NewAuditStore(k.ctx, k.database),
+			k.logger.NewSubLogger("audit", &logger.SubLoggerOpts{}),
+			config.File,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	if config := k.twitterConfig.Events; len(config.Sinks) > 0 && !k.local {
+		k.events = NewEventPublisher(
+			k.logger.NewSubLogger("events", &logger.SubLoggerOpts{}),
+			k.metrics,
+			config,
+		)
+	}
 
 	return nil
 }
@@ -0,0 +1,156 @@
+package twitter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// metricKind is the Prometheus type of a metric
+type metricKind string
+
+const (
+	metricCounter metricKind = "counter"
+	metricGauge   metricKind = "gauge"
+)
+
+// Labels are the label values of a metric series
+type Labels map[string]string
+
+// metricSeries is a single labelled value of a metric
+type metricSeries struct {
+	labels Labels
+	value  float64
+}
+
+// metric is a named family of series
+type metric struct {
+	kind   metricKind
+	help   string
+	series map[string]*metricSeries
+}
+
+// Metrics is an in-process registry of counters and gauges exposed in the
+// Prometheus text format on the admin API. It is safe for concurrent use.
+type Metrics struct {
+	mu      sync.Mutex
+	metrics map[string]*metric
+}
+
+// NewMetrics returns an empty registry
+func NewMetrics() *Metrics {
+	return &Metrics{
+		metrics: make(map[string]*metric),
+	}
+}
+
+// Add increases a counter by value
+func (m *Metrics) Add(name, help string, labels Labels, value float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.series(name, help, metricCounter, labels).value += value
+}
+
+// Inc increases a counter by one
+func (m *Metrics) Inc(name, help string, labels Labels) {
+	m.Add(name, help, labels, 1)
+}
+
+// Set sets a gauge to value
+func (m *Metrics) Set(name, help string, labels Labels, value float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.series(name, help, metricGauge, labels).value = value
+}
+
+// Value returns the current value of a series, zero if it was never recorded
+func (m *Metrics) Value(name string, labels Labels) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	family, ok := m.metrics[name]
+	if !ok {
+		return 0
+	}
+	if series, ok := family.series[labelKey(labels)]; ok {
+		return series.value
+	}
+	return 0
+}
+
+// series returns the series of a metric, creating both if needed. The caller holds the lock.
+func (m *Metrics) series(name, help string, kind metricKind, labels Labels) *metricSeries {
+	family, ok := m.metrics[name]
+	if !ok {
+		family = &metric{kind: kind, help: help, series: make(map[string]*metricSeries)}
+		m.metrics[name] = family
+	}
+
+	key := labelKey(labels)
+	series, ok := family.series[key]
+	if !ok {
+		copied := make(Labels, len(labels))
+		for k, v := range labels {
+			copied[k] = v
+		}
+		series = &metricSeries{labels: copied}
+		family.series[key] = series
+	}
+	return series
+}
+
+// WriteText writes every metric in the Prometheus text exposition format
+func (m *Metrics) WriteText(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.metrics))
+	for name := range m.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		family := m.metrics[name]
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, family.help, name, family.kind); err != nil {
+			return err
+		}
+
+		keys := make([]string, 0, len(family.series))
+		for key := range family.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if _, err := fmt.Fprintf(w, "%s%s %g\n", name, key, family.series[key].value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// labelKey formats labels as a sorted Prometheus label set such as {rule="lowercase"}
+func labelKey(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[name])
+		pairs[i] = fmt.Sprintf(`%s="%s"`, name, value)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,373 @@
+package twitter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/velumlabs/thor/db"
+	"gorm.io/gorm"
+)
+
+// ErrSchemaOutdated is returned when the database schema is behind the migrations of this build
+var ErrSchemaOutdated = errors.New("database schema is out of date, run `wrz migrate up`")
+
+// fragmentTables are the fragment tables used by the agent's managers
+var fragmentTables = []db.FragmentTable{
+	db.FragmentTableInteraction,
+	db.FragmentTablePersonality,
+	db.FragmentTableInsight,
+	db.FragmentTableTwitter,
+}
+
+// Migration is a versioned change of the database schema
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration records a migration applied to the database
+type SchemaMigration struct {
+	Version   int    `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"type:varchar(255);not null"`
+	AppliedAt time.Time
+}
+
+// TableName returns the table applied migrations are recorded in
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationStatus is a migration and when it was applied, nil if it is pending
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+// migrations is the ordered schema history. Applied migrations must never change,
+// schema changes are added as new migrations at the end.
+// Every step tolerates objects that already exist, so databases created before
+// versioned migrations are adopted by running them.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "pgvector_extension",
+		Up: func(tx *gorm.DB) error {
+			// SQLite stores vectors as text, see OpenSQLite
+			if isSQLite(tx) {
+				return nil
+			}
+			return tx.Exec("CREATE EXTENSION IF NOT EXISTS vector").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			if isSQLite(tx) {
+				return nil
+			}
+			return tx.Exec("DROP EXTENSION IF EXISTS vector").Error
+		},
+	},
+	{
+		Version: 2,
+		Name:    "actors_and_sessions",
+		Up: func(tx *gorm.DB) error {
+			return createTables(tx, &db.Actor{}, &db.Session{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&db.Session{}, &db.Actor{})
+		},
+	},
+	{
+		Version: 3,
+		Name:    "fragment_tables",
+		Up: func(tx *gorm.DB) error {
+			return createFragmentTables(tx, fragmentTables)
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropFragmentTables(tx, fragmentTables)
+		},
+	},
+	{
+		Version: 4,
+		Name:    "fragment_embedding_indexes",
+		Up: func(tx *gorm.DB) error {
+			return createEmbeddingIndexes(tx, fragmentTables)
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropEmbeddingIndexes(tx, fragmentTables)
+		},
+	},
+	{
+		Version: 5,
+		Name:    "bot_tables",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(botTables...)
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(botTables...)
+		},
+	},
+	{
+		Version: 6,
+		Name:    "audit_events",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&AuditEvent{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&AuditEvent{})
+		},
+	},
+	{
+		Version: 7,
+		Name:    "direct_message_fragment_tables",
+		Up: func(tx *gorm.DB) error {
+			if err := createFragmentTables(tx, directMessageFragmentTables); err != nil {
+				return err
+			}
+			return createEmbeddingIndexes(tx, directMessageFragmentTables)
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropEmbeddingIndexes(tx, directMessageFragmentTables); err != nil {
+				return err
+			}
+			return dropFragmentTables(tx, directMessageFragmentTables)
+		},
+	},
+}
+
+// botTables are the tables created by the bot_tables migration
+var botTables = []interface{}{
+	&ActionRecord{},
+	&DMOptIn{},
+	&ActorProfile{},
+	&DoNotEngage{},
+	&AdminAuditEntry{},
+	&BotControl{},
+	&Mute{},
+	&BotReply{},
+	&EngagementSample{},
+	&LLMUsage{},
+	&CachedEmbedding{},
+}
+
+// createTables creates the tables of the models that don't exist yet
+func createTables(tx *gorm.DB, models ...interface{}) error {
+	for _, model := range models {
+		if tx.Migrator().HasTable(model) {
+			continue
+		}
+		if err := tx.Migrator().CreateTable(model); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createFragmentTables creates the fragment tables that don't exist yet
+func createFragmentTables(tx *gorm.DB, tables []db.FragmentTable) error {
+	for _, table := range tables {
+		if tx.Migrator().HasTable(string(table)) {
+			continue
+		}
+		// The vector column takes its dimensions from db.Fragment
+		if err := tx.Table(string(table)).Migrator().CreateTable(&db.Fragment{}); err != nil {
+			return fmt.Errorf("failed to create %s table: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// dropFragmentTables drops the fragment tables
+func dropFragmentTables(tx *gorm.DB, tables []db.FragmentTable) error {
+	for _, table := range tables {
+		if err := tx.Migrator().DropTable(string(table)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createEmbeddingIndexes indexes the embeddings of the fragment tables for similarity searches,
+// which order by cosine distance. SQLite similarity searches scan every row.
+func createEmbeddingIndexes(tx *gorm.DB, tables []db.FragmentTable) error {
+	if isSQLite(tx) {
+		return nil
+	}
+	for _, table := range tables {
+		err := tx.Exec(fmt.Sprintf(
+			`CREATE INDEX IF NOT EXISTS "idx_%s_embedding" ON %q USING hnsw (embedding vector_cosine_ops)`,
+			table, table,
+		)).Error
+		if err != nil {
+			return fmt.Errorf("failed to index %s embeddings: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// dropEmbeddingIndexes drops the embedding indexes of the fragment tables
+func dropEmbeddingIndexes(tx *gorm.DB, tables []db.FragmentTable) error {
+	if isSQLite(tx) {
+		return nil
+	}
+	for _, table := range tables {
+		if err := tx.Exec(fmt.Sprintf(`DROP INDEX IF EXISTS "idx_%s_embedding"`, table)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Migrator applies and reverts the schema migrations of the agent
+type Migrator struct {
+	db  *gorm.DB
+	ctx context.Context
+}
+
+// NewMigrator returns a new Migrator initialized with the provided context and DB connection
+func NewMigrator(ctx context.Context, db *gorm.DB) *Migrator {
+	return &Migrator{
+		db:  db,
+		ctx: ctx,
+	}
+}
+
+// applied returns the applied migrations keyed by version, creating the migrations table if needed
+func (m *Migrator) applied() (map[int]SchemaMigration, error) {
+	if err := m.db.WithContext(m.ctx).AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	var rows []SchemaMigration
+	if err := m.db.WithContext(m.ctx).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+
+	applied := make(map[int]SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// Up applies every pending migration in order, each in its own transaction.
+// Returns the migrations applied before any failure.
+func (m *Migrator) Up() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		err := m.db.WithContext(m.ctx).Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("failed to apply migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down reverts the last steps applied migrations in reverse order, each in its own transaction.
+// Returns the migrations reverted before any failure.
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		err := m.db.WithContext(m.ctx).Transaction(func(tx *gorm.DB) error {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, migration.Version).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("failed to revert migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Status returns every migration known to this build and when it was applied
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, migration := range migrations {
+		statuses[i] = MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			appliedAt := row.AppliedAt
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// Version returns the version of the last applied migration, zero if none was applied
+func (m *Migrator) Version() (int, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+
+	version := 0
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+// LatestSchemaVersion returns the version of the last migration known to this build
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// CheckCurrent returns an error wrapping ErrSchemaOutdated if any migration is pending
+func (m *Migrator) CheckCurrent() error {
+	statuses, err := m.Status()
+	if err != nil {
+		return err
+	}
+
+	pending := 0
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%w: %d of %d migrations pending", ErrSchemaOutdated, pending, len(statuses))
+	}
+	return nil
+}
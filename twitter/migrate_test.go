@@ -0,0 +1,42 @@
+package twitter
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDirectMessageFragmentTablesMigration(t *testing.T) {
+	database := newTestDatabase(t)
+	migrator := NewMigrator(context.Background(), database)
+
+	for _, table := range directMessageFragmentTables {
+		if !database.Migrator().HasTable(string(table)) {
+			t.Errorf("table %s wasn't created", table)
+		}
+	}
+
+	reverted, err := migrator.Down(1)
+	if err != nil {
+		t.Fatalf("Down() error = %v", err)
+	}
+	if len(reverted) != 1 || reverted[0].Name != "direct_message_fragment_tables" {
+		t.Fatalf("Down() reverted %v, want direct_message_fragment_tables", reverted)
+	}
+	for _, table := range directMessageFragmentTables {
+		if database.Migrator().HasTable(string(table)) {
+			t.Errorf("table %s wasn't dropped", table)
+		}
+	}
+	for _, table := range fragmentTables {
+		if !database.Migrator().HasTable(string(table)) {
+			t.Errorf("table %s of an earlier migration was dropped", table)
+		}
+	}
+
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	if err := migrator.CheckCurrent(); err != nil {
+		t.Errorf("CheckCurrent() error = %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package twitter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// WebhookNotifier posts alerts to a chat webhook.
+// Discord webhooks receive a content field, every other URL receives the text field of Slack compatible webhooks.
+type WebhookNotifier struct {
+	url     string
+	discord bool
+	client  *http.Client
+}
+
+// NewWebhookNotifier returns a notifier posting to the webhook URL.
+// Returns an error if the URL is not an absolute http or https URL.
+func NewWebhookNotifier(webhookURL string) (*WebhookNotifier, error) {
+	parsed, err := url.Parse(webhookURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid webhook URL: %q", webhookURL)
+	}
+	host := strings.ToLower(parsed.Hostname())
+	return &WebhookNotifier{
+		url:     webhookURL,
+		discord: host == "discord.com" || host == "discordapp.com" || strings.HasSuffix(host, ".discord.com"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Name returns the host of the webhook, the URL contains its token
+func (n *WebhookNotifier) Name() string {
+	parsed, _ := url.Parse(n.url)
+	return "webhook:" + parsed.Host
+}
+
+// Notify posts the alert text
+func (n *WebhookNotifier) Notify(ctx context.Context, alert *Alert) error {
+	field := "text"
+	if n.discord {
+		field = "content"
+	}
+	body, err := json.Marshal(map[string]string{field: alert.Text()})
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// SMTPConfig configures an email notifier
+type SMTPConfig struct {
+	Address  string // host:port of the SMTP server
+	Username string // empty sends without authentication
+	Password string
+	From     string
+	To       []string
+}
+
+// EmailNotifier sends alerts by email
+type EmailNotifier struct {
+	config SMTPConfig
+}
+
+// NewEmailNotifier returns a notifier sending through the SMTP server of the config.
+// Returns an error if the address, sender or recipients are missing.
+func NewEmailNotifier(config SMTPConfig) (*EmailNotifier, error) {
+	if _, _, err := net.SplitHostPort(config.Address); err != nil {
+		return nil, fmt.Errorf("invalid SMTP address: %w", err)
+	}
+	if config.From == "" {
+		return nil, fmt.Errorf("alert email sender cannot be empty")
+	}
+	if len(config.To) == 0 {
+		return nil, fmt.Errorf("alert email requires at least one recipient")
+	}
+	return &EmailNotifier{config: config}, nil
+}
+
+// Name returns the address of the SMTP server
+func (n *EmailNotifier) Name() string {
+	return "email:" + n.config.Address
+}
+
+// Notify sends the alert as a plain text email. Sending is not interrupted by the context.
+func (n *EmailNotifier) Notify(_ context.Context, alert *Alert) error {
+	var message strings.Builder
+	fmt.Fprintf(&message, "From: %s\r\n", n.config.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(n.config.To, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", alert.Subject())
+	fmt.Fprintf(&message, "Date: %s\r\n", alert.At.Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	message.WriteString(alert.Text())
+	message.WriteString("\r\n")
+
+	var auth smtp.Auth
+	if n.config.Username != "" {
+		host, _, _ := net.SplitHostPort(n.config.Address)
+		auth = smtp.PlainAuth("", n.config.Username, n.config.Password, host)
+	}
+	return smtp.SendMail(n.config.Address, auth, n.config.From, n.config.To, []byte(message.String()))
+}
+
+// WriterNotifier writes alerts as text lines, to stderr unless another writer is given
+type WriterNotifier struct {
+	w io.Writer
+}
+
+// NewStderrNotifier returns a notifier writing to stderr
+func NewStderrNotifier() *WriterNotifier {
+	return &WriterNotifier{w: os.Stderr}
+}
+
+// NewWriterNotifier returns a notifier writing to w
+func NewWriterNotifier(w io.Writer) *WriterNotifier {
+	return &WriterNotifier{w: w}
+}
+
+// Name returns "stderr", or "writer" for other writers
+func (n *WriterNotifier) Name() string {
+	if n.w == os.Stderr {
+		return "stderr"
+	}
+	return "writer"
+}
+
+// Notify writes the alert text
+func (n *WriterNotifier) Notify(_ context.Context, alert *Alert) error {
+	_, err := fmt.Fprintf(n.w, "%s %s\n", alert.At.Format("2006-01-02 15:04:05"), alert.Text())
+	return err
+}
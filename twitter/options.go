@@ -3,10 +3,12 @@ package twitter
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/velumlabs/thor/llm"
 	"github.com/velumlabs/thor/logger"
+	"github.com/velumlabs/thor/managers/personality"
 	"github.com/velumlabs/thor/options"
 
 	"gorm.io/gorm"
@@ -94,3 +96,357 @@ func WithTwitterCredentials(ct0, authToken, user string) options.Option[Twitter]
 		return nil
 	}
 }
+
+// WithLocalMode runs the agent without a Twitter account, for chatting with the persona through NewChatSession
+// or replaying stored and exported tweets.
+// Twitter credentials are not required, the agent's user name defaults to the name of the personality,
+// and the audit log and event sinks are disabled since nothing is posted.
+// Agents in local mode must not be started.
+func WithLocalMode() options.Option[Twitter] {
+	return func(k *Twitter) error {
+		k.local = true
+		return nil
+	}
+}
+
+// WithReplyScoring sets how fetched tweets are ranked before processing.
+// Returns an error if the search limit or reply budget is not positive.
+// Only the top scoring tweets within the reply budget are processed each cycle.
+func WithReplyScoring(config ScoringConfig) options.Option[Twitter] {
+	return func(k *Twitter) error {
+		if config.SearchLimit <= 0 || config.ReplyBudget <= 0 {
+			return fmt.Errorf("search limit and reply budget must be positive")
+		}
+		k.twitterConfig.Scoring = config
+		return nil
+	}
+}
+
+// WithReplyDecision configures the classifier that decides between replying,
+// liking or ignoring a tweet. Returns an error if a threshold is outside [0, 1].
+func WithReplyDecision(config DecisionConfig) options.Option[Twitter] {
+	return func(k *Twitter) error {
+		if config.ReplyThreshold < 0 || config.ReplyThreshold > 1 || config.LikeThreshold < 0 || config.LikeThreshold > 1 {
+			return fmt.Errorf("decision thresholds must be between 0 and 1")
+		}
+		k.twitterConfig.Decision = config
+		return nil
+	}
+}
+
+// WithEngagementActions sets the rate limits of likes, retweets, quotes and follows.
+// Returns an error if a limit has no window. Actions without a limit are not rate limited.
+func WithEngagementActions(config ActionsConfig) options.Option[Twitter] {
+	return func(k *Twitter) error {
+		for action, limit := range config.Limits {
+			if limit.Window <= 0 {
+				return fmt.Errorf("rate limit window for %s must be positive", action)
+			}
+		}
+		k.twitterConfig.Actions = config
+		return nil
+	}
+}
+
+// WithPersonality sets the persona the agent speaks with.
+// Returns an error if the personality is nil.
+func WithPersonality(p *personality.Personality) options.Option[Twitter] {
+	return func(k *Twitter) error {
+		if p == nil {
+			return fmt.Errorf("personality cannot be nil")
+		}
+		k.personality = p
+		return nil
+	}
+}
+
+// WithDirectMessages configures the direct message inbox monitor.
+// Returns an error if the policy is unknown, the poll interval is invalid or a limit has no window.
+// Direct messages are stored separately from public interactions and never used as reply context.
+func WithDirectMessages(config DMConfig) options.Option[Twitter] {
+	return func(k *Twitter) error {
+		switch config.Policy {
+		case DMPolicyAllowlist, DMPolicyOptIn, DMPolicyOpen:
+		default:
+			return fmt.Errorf("unknown direct message policy: %s", config.Policy)
+		}
+		if config.PollInterval.Min <= 0 || config.PollInterval.Min > config.PollInterval.Max {
+			return fmt.Errorf("invalid direct message poll interval")
+		}
+		if config.ReplyLimit.Window <= 0 || config.PerUserLimit.Window <= 0 {
+			return fmt.Errorf("direct message rate limit windows must be positive")
+		}
+		if config.Policy == DMPolicyOptIn && (config.OptInKeyword == "" || config.OptOutKeyword == "") {
+			return fmt.Errorf("opt-in policy requires opt-in and opt-out keywords")
+		}
+		k.twitterConfig.DirectMessages = config
+		return nil
+	}
+}
+
+// WithThreadReplies allows replies longer than a tweet to be posted as numbered threads.
+// Returns an error if the thread or tweet length limits are not positive, or if the tweet
+// length limit leaves no room for text next to the "i/n" numbering of the longest thread.
+func WithThreadReplies(config ThreadConfig) options.Option[Twitter] {
+	return func(k *Twitter) error {
+		if config.MaxTweets <= 0 || config.MaxWeightedLength <= 0 {
+			return fmt.Errorf("thread length limits must be positive")
+		}
+		if minLength := threadSuffixLength(config.MaxTweets) + minThreadPartLength; config.MaxWeightedLength < minLength {
+			return fmt.Errorf("tweet length limit must be at least %d for threads of %d tweets", minLength, config.MaxTweets)
+		}
+		k.twitterConfig.Threads = config
+		return nil
+	}
+}
+
+// WithEngagementCollector sets how often and for how long the engagement of posted replies is collected.
+// Returns an error if the interval or window is invalid or the number of replies per cycle or prompt examples is negative.
+func WithEngagementCollector(config EngagementConfig) options.Option[Twitter] {
+	return func(k *Twitter) error {
+		if config.Interval.Min <= 0 || config.Interval.Min > config.Interval.Max {
+			return fmt.Errorf("invalid engagement collection interval")
+		}
+		if config.Window <= 0 {
+			return fmt.Errorf("engagement collection window must be positive")
+		}
+		if config.MaxPerCycle < 0 {
+			return fmt.Errorf("engagement replies per cycle cannot be negative")
+		}
+		if config.PromptExamples < 0 {
+			return fmt.Errorf("engagement prompt examples cannot be negative")
+		}
+		k.twitterConfig.Engagement = config
+		return nil
+	}
+}
+
+// WithUsageTracking attributes and stores the usage of the LLM calls captured by the tracker,
+// and stops replies once the daily budget of the configuration is spent.
+// Returns an error if the tracker is nil or the budget is negative.
+func WithUsageTracking(tracker *UsageTracker, config UsageConfig) options.Option[Twitter] {
+	return func(k *Twitter) error {
+		if tracker == nil {
+			return fmt.Errorf("usage tracker cannot be nil")
+		}
+		if config.DailyBudget < 0 {
+			return fmt.Errorf("daily LLM budget cannot be negative")
+		}
+		k.usage = tracker
+		k.twitterConfig.Usage = config
+		return nil
+	}
+}
+
+// WithEmbeddingCache sets the size of the in-memory embedding cache and the model version cached entries are keyed by.
+// Returns an error if the size or dimensions are not positive or the model is empty.
+func WithEmbeddingCache(config EmbeddingCacheConfig) options.Option[Twitter] {
+	return func(k *Twitter) error {
+		if config.Enabled {
+			if config.Size <= 0 || config.Dimensions <= 0 {
+				return fmt.Errorf("embedding cache size and dimensions must be positive")
+			}
+			if config.Model == "" {
+				return fmt.Errorf("embedding cache model cannot be empty")
+			}
+		}
+		k.twitterConfig.EmbeddingCache = config
+		return nil
+	}
+}
+
+// WithRetention sets how long fragments are kept and how often expired fragments are removed.
+// Returns an error if the interval is invalid, a policy has no table or a non-positive age,
+// or summarizes a table into itself.
+func WithRetention(config RetentionConfig) options.Option[Twitter] {
+	return func(k *Twitter) error {
+		if config.Interval.Min <= 0 || config.Interval.Min > config.Interval.Max {
+			return fmt.Errorf("invalid retention interval")
+		}
+		for _, policy := range config.Policies {
+			if policy.Table == "" {
+				return fmt.Errorf("retention policy table cannot be empty")
+			}
+			if policy.MaxAge <= 0 {
+				return fmt.Errorf("retention age of %s must be positive", policy.Table)
+			}
+			if policy.SummaryTable == policy.Table {
+				return fmt.Errorf("retention of %s cannot summarize into the same table", policy.Table)
+			}
+		}
+		if config.MaxSummaries < 0 {
+			return fmt.Errorf("retention summaries per run cannot be negative")
+		}
+		k.twitterConfig.Retention = config
+		return nil
+	}
+}
+
+// WithAuditLog sets whether the agent's decisions about tweets are recorded in the audit_events table,
+// and the JSONL file they are also appended to.
+func WithAuditLog(config AuditConfig) options.Option[Twitter] {
+	return func(k *Twitter) error {
+		k.twitterConfig.Audit = config
+		return nil
+	}
+}
+
+// WithEvents publishes reply, moderation, auth failure and pause events to the sinks of the config.
+// Returns an error if a sink is nil, or the buffer size or timeout is not positive.
+func WithEvents(config EventsConfig) options.Option[Twitter] {
+	return func(k *Twitter) error {
+		for _, sink := range config.Sinks {
+			if sink == nil {
+				return fmt.Errorf("event sink cannot be nil")
+			}
+		}
+		if config.BufferSize <= 0 {
+			return fmt.Errorf("event buffer size must be positive")
+		}
+		if config.Timeout <= 0 {
+			return fmt.Errorf("event delivery timeout must be positive")
+		}
+		k.twitterConfig.Events = config
+		return nil
+	}
+}
+
+// WithModeration sets the sensitive topics whose tweets are skipped and published as moderated.
+// Returns an error if a topic is empty.
+func WithModeration(config ModerationConfig) options.Option[Twitter] {
+	return func(k *Twitter) error {
+		for _, topic := range config.SensitiveTopics {
+			if strings.TrimSpace(topic) == "" {
+				return fmt.Errorf("sensitive topic cannot be empty")
+			}
+		}
+		k.twitterConfig.Moderation = config
+		return nil
+	}
+}
+
+// WithConnectors serves the platforms of the connectors next to Twitter, with the same persona and memory.
+// Returns an error if there are no connectors, a platform name is empty, reserved or duplicated, or the poll interval is invalid.
+func WithConnectors(config ConnectorsConfig) options.Option[Twitter] {
+	return func(k *Twitter) error {
+		if len(config.Connectors) == 0 {
+			return fmt.Errorf("at least one connector is required")
+		}
+		platforms := make(map[string]bool, len(config.Connectors))
+		for _, connector := range config.Connectors {
+			platform := connector.Platform()
+			if platform == "" || platform == platformTwitter || platforms[platform] {
+				return fmt.Errorf("connector platform names must be unique, not empty and not %q", platformTwitter)
+			}
+			platforms[platform] = true
+		}
+		if config.PollInterval.Min <= 0 || config.PollInterval.Min > config.PollInterval.Max {
+			return fmt.Errorf("invalid connector poll interval")
+		}
+		k.twitterConfig.Connectors = config
+		return nil
+	}
+}
+
+// WithAlerting notifies operational failures detected by the alerter, with the thresholds of the config.
+// The alerter only sees LLM calls made through its Transport.
+// Returns an error if the alerter is nil, the error rate is outside [0, 1] or an interval or count is invalid.
+func WithAlerting(alerter *Alerter, config AlertsConfig) options.Option[Twitter] {
+	return func(k *Twitter) error {
+		if alerter == nil {
+			return fmt.Errorf("alerter cannot be nil")
+		}
+		if config.CheckInterval <= 0 {
+			return fmt.Errorf("alert check interval must be positive")
+		}
+		if config.LLMErrorRate < 0 || config.LLMErrorRate > 1 {
+			return fmt.Errorf("LLM error rate threshold must be between 0 and 1")
+		}
+		if config.LLMErrorRate > 0 && config.LLMWindow <= 0 {
+			return fmt.Errorf("LLM error rate window must be positive")
+		}
+		if config.TimelineFailures < 0 || config.NoPostWindow < 0 || config.LLMMinCalls < 0 ||
+			config.RepeatInterval < 0 || config.MaxPerHour < 0 {
+			return fmt.Errorf("alert thresholds cannot be negative")
+		}
+		k.alerts = alerter
+		k.twitterConfig.Alerts = config
+		return nil
+	}
+}
+
+// WithAdminAPI serves the local admin API used to pause, resume and mute the agent.
+// The address is a loopback host:port such as "127.0.0.1:8089", or "unix:" followed by a socket path.
+// Returns an error if the address is empty.
+func WithAdminAPI(address string) options.Option[Twitter] {
+	return func(k *Twitter) error {
+		if address == "" {
+			return fmt.Errorf("admin API address cannot be empty")
+		}
+		k.twitterConfig.Admin.Address = address
+		return nil
+	}
+}
+
+// WithResponseConfig sets the prompt template, model and temperature used to generate tweet replies.
+// An empty prompt keeps the default prompt.
+// Returns an error if the model type is empty or the temperature is outside [0, 2].
+func WithResponseConfig(config ResponseConfig) options.Option[Twitter] {
+	return func(k *Twitter) error {
+		if config.ModelType == "" {
+			return fmt.Errorf("response model type cannot be empty")
+		}
+		if config.Temperature < 0 || config.Temperature > 2 {
+			return fmt.Errorf("response temperature must be between 0 and 2")
+		}
+		k.twitterConfig.Response = config
+		return nil
+	}
+}
+
+// WithStyleRules configures the style rules compiled from the personality and enforced on every reply.
+// Returns an error if the regeneration count is negative or the word limit is not positive.
+func WithStyleRules(config StyleConfig) options.Option[Twitter] {
+	return func(k *Twitter) error {
+		if config.MaxRegenerations < 0 {
+			return fmt.Errorf("style regenerations cannot be negative")
+		}
+		if config.MaxWords <= 0 {
+			return fmt.Errorf("style word limit must be positive")
+		}
+		k.twitterConfig.Style = config
+		return nil
+	}
+}
+
+// WithExperiment splits conversations between the arms of an experiment.
+// Returns an error if the experiment has no name, fewer than two arms, duplicate arm names,
+// non-positive weights or an invalid response configuration.
+func WithExperiment(config ExperimentConfig) options.Option[Twitter] {
+	return func(k *Twitter) error {
+		if config.Name == "" {
+			return fmt.Errorf("experiment name cannot be empty")
+		}
+		if len(config.Arms) < 2 {
+			return fmt.Errorf("experiment requires at least two arms")
+		}
+		names := make(map[string]bool, len(config.Arms))
+		for _, arm := range config.Arms {
+			if arm.Name == "" || names[arm.Name] {
+				return fmt.Errorf("experiment arm names must be unique and not empty")
+			}
+			names[arm.Name] = true
+			if arm.Weight <= 0 {
+				return fmt.Errorf("weight of experiment arm %s must be positive", arm.Name)
+			}
+			if arm.Response.ModelType == "" {
+				return fmt.Errorf("model type of experiment arm %s cannot be empty", arm.Name)
+			}
+			if arm.Response.Temperature < 0 || arm.Response.Temperature > 2 {
+				return fmt.Errorf("temperature of experiment arm %s must be between 0 and 2", arm.Name)
+			}
+		}
+		k.twitterConfig.Experiment = config
+		return nil
+	}
+}
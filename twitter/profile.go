@@ -0,0 +1,269 @@
+package twitter
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/velumlabs/thor/id"
+	"github.com/velumlabs/thor/llm"
+	"github.com/velumlabs/thor/pkg/twitter"
+	"github.com/velumlabs/thor/state"
+	"gorm.io/gorm"
+)
+
+// Profile list caps, oldest entries are dropped first
+const (
+	maxProfileTopics     = 20
+	maxProfileSentiments = 10
+	maxProfileFacts      = 20
+)
+
+// StringList represents a JSON array of strings stored in the database
+type StringList []string
+
+// Value implements the driver.Valuer interface
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return json.Marshal([]string{})
+	}
+	return json.Marshal(l)
+}
+
+// Scan implements the sql.Scanner interface
+func (l *StringList) Scan(value interface{}) error {
+	if value == nil {
+		*l = StringList{}
+		return nil
+	}
+
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return errors.New("invalid type for StringList")
+	}
+
+	return json.Unmarshal(bytes, l)
+}
+
+// ActorProfile is the structured long-term memory the agent keeps about a user
+type ActorProfile struct {
+	ActorID            id.ID      `gorm:"type:uuid;primaryKey" json:"actor_id"`
+	TwitterUserID      string     `gorm:"type:varchar(64);index" json:"twitter_user_id"`
+	UserName           string     `gorm:"type:varchar(255);index" json:"user_name"`
+	DisplayNames       StringList `gorm:"type:jsonb" json:"display_names"`
+	FirstInteractionAt time.Time  `json:"first_interaction_at"`
+	LastInteractionAt  time.Time  `json:"last_interaction_at"`
+	InteractionCount   int        `json:"interaction_count"`
+	Topics             StringList `gorm:"type:jsonb" json:"topics"`
+	SentimentTrend     StringList `gorm:"type:jsonb" json:"sentiment_trend"`
+	NotableFacts       StringList `gorm:"type:jsonb" json:"notable_facts"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName returns the table actor profiles are stored in
+func (ActorProfile) TableName() string {
+	return "actor_profiles"
+}
+
+// ProfileStore persists actor profiles
+type ProfileStore struct {
+	db  *gorm.DB
+	ctx context.Context
+}
+
+// NewProfileStore returns a new ProfileStore initialized with the provided context and DB connection
+func NewProfileStore(ctx context.Context, db *gorm.DB) *ProfileStore {
+	return &ProfileStore{
+		db:  db,
+		ctx: ctx,
+	}
+}
+
+// Upsert creates or updates a profile
+func (s *ProfileStore) Upsert(profile *ActorProfile) error {
+	return s.db.WithContext(s.ctx).Save(profile).Error
+}
+
+// GetByActorID retrieves the profile of an actor.
+// Returns gorm.ErrRecordNotFound if the actor has no profile.
+func (s *ProfileStore) GetByActorID(actorID id.ID) (*ActorProfile, error) {
+	var profile ActorProfile
+	if err := s.db.WithContext(s.ctx).First(&profile, "actor_id = ?", actorID).Error; err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// GetByUserName retrieves the profile of a user by screen name, ignoring case.
+// Returns gorm.ErrRecordNotFound if the user has no profile.
+func (s *ProfileStore) GetByUserName(userName string) (*ActorProfile, error) {
+	var profile ActorProfile
+	if err := s.db.WithContext(s.ctx).
+		Where("LOWER(user_name) = ?", strings.ToLower(strings.TrimPrefix(userName, "@"))).
+		First(&profile).Error; err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// DeleteByActorID permanently removes the profile of an actor
+func (s *ProfileStore) DeleteByActorID(actorID id.ID) error {
+	return s.db.WithContext(s.ctx).Delete(&ActorProfile{}, "actor_id = ?", actorID).Error
+}
+
+// ProfileUpdate is the structured output of the profile extraction step
+type ProfileUpdate struct {
+	Topics       []string `json:"topics" jsonschema:"required" description:"Short topics the user talked about in this exchange, lowercase, one to three words each"`
+	Sentiment    string   `json:"sentiment" jsonschema:"required,enum=positive,enum=neutral,enum=negative" description:"The user's sentiment towards the persona in this exchange"`
+	NotableFacts []string `json:"notable_facts" jsonschema:"required" description:"New lasting facts the user shared about themselves, such as their job, projects or preferences. Empty when there are none"`
+}
+
+// recordInteraction updates the interaction statistics of the tweet author's profile
+func (k *Twitter) recordInteraction(tweet *twitter.ParsedTweet) (*ActorProfile, error) {
+	actorID := id.FromString(tweet.UserID)
+
+	profile, err := k.profileStore.GetByActorID(actorID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		profile = &ActorProfile{
+			ActorID:            actorID,
+			FirstInteractionAt: time.Now(),
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get actor profile: %w", err)
+	}
+
+	profile.TwitterUserID = tweet.UserID
+	profile.UserName = tweet.UserName
+	if tweet.DisplayName != "" && !containsString(profile.DisplayNames, tweet.DisplayName) {
+		profile.DisplayNames = append(profile.DisplayNames, tweet.DisplayName)
+	}
+	profile.LastInteractionAt = time.Now()
+	profile.InteractionCount++
+
+	if err := k.profileStore.Upsert(profile); err != nil {
+		return nil, fmt.Errorf("failed to store actor profile: %w", err)
+	}
+
+	return profile, nil
+}
+
+// updateActorProfile extracts topics, sentiment and notable facts from an exchange
+// with the fast model and merges them into the profile.
+// Only public exchanges are used so that private messages never reach public replies.
+func (k *Twitter) updateActorProfile(currentState *state.State, profile *ActorProfile, tweet *twitter.ParsedTweet, reply string) error {
+	currentState.AddCustomData("profile_exchange", fmt.Sprintf("@%s: %s\nYOU: %s", tweet.UserName, tweet.TweetText, reply))
+
+	messages, err := state.NewPromptBuilder(currentState).
+		AddSystemSection(`You maintain a long-term profile of a Twitter user the persona talks to.
+
+Current profile:
+{{.actor_profile}}
+
+Latest exchange:
+{{.profile_exchange}}
+
+Task:
+Extract what this exchange reveals about the user. Only include facts the user stated about themselves, never guesses, and skip facts already in the profile.`).
+		Compose()
+	if err != nil {
+		return fmt.Errorf("failed to build profile prompt: %w", err)
+	}
+
+	var update ProfileUpdate
+	if err := k.llmClient.GenerateStructuredOutput(llm.StructuredOutputRequest{
+		Messages:     messages,
+		ModelType:    llm.ModelTypeFast,
+		SchemaName:   "profile_update",
+		StrictSchema: true,
+	}, &update); err != nil {
+		return fmt.Errorf("failed to extract profile update: %w", err)
+	}
+
+	for _, topic := range update.Topics {
+		topic = strings.ToLower(strings.TrimSpace(topic))
+		if topic != "" && !containsString(profile.Topics, topic) {
+			profile.Topics = append(profile.Topics, topic)
+		}
+	}
+	for _, fact := range update.NotableFacts {
+		fact = strings.TrimSpace(fact)
+		if fact != "" && !containsString(profile.NotableFacts, fact) {
+			profile.NotableFacts = append(profile.NotableFacts, fact)
+		}
+	}
+	if update.Sentiment != "" {
+		profile.SentimentTrend = append(profile.SentimentTrend, update.Sentiment)
+	}
+
+	profile.Topics = lastStrings(profile.Topics, maxProfileTopics)
+	profile.NotableFacts = lastStrings(profile.NotableFacts, maxProfileFacts)
+	profile.SentimentTrend = lastStrings(profile.SentimentTrend, maxProfileSentiments)
+
+	if err := k.profileStore.Upsert(profile); err != nil {
+		return fmt.Errorf("failed to store actor profile: %w", err)
+	}
+
+	return nil
+}
+
+// formatActorProfile formats a profile for the reply prompt
+func formatActorProfile(profile *ActorProfile) string {
+	if profile == nil {
+		return "No profile yet."
+	}
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("@%s", profile.UserName))
+	if len(profile.DisplayNames) > 0 {
+		builder.WriteString(fmt.Sprintf(" (also known as %s)", strings.Join(profile.DisplayNames, ", ")))
+	}
+	builder.WriteString("\n")
+	builder.WriteString(fmt.Sprintf("Interactions: %d since %s, last on %s\n",
+		profile.InteractionCount,
+		profile.FirstInteractionAt.Format("2006-01-02"),
+		profile.LastInteractionAt.Format("2006-01-02"),
+	))
+	if len(profile.Topics) > 0 {
+		builder.WriteString(fmt.Sprintf("Topics discussed: %s\n", strings.Join(profile.Topics, ", ")))
+	}
+	if len(profile.SentimentTrend) > 0 {
+		builder.WriteString(fmt.Sprintf("Sentiment trend (oldest to newest): %s\n", strings.Join(profile.SentimentTrend, ", ")))
+	}
+	if len(profile.NotableFacts) > 0 {
+		builder.WriteString("Notable facts:\n")
+		for _, fact := range profile.NotableFacts {
+			builder.WriteString(fmt.Sprintf("- %s\n", fact))
+		}
+	}
+
+	return builder.String()
+}
+
+// containsString reports whether list contains value, ignoring case
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// lastStrings returns the last n entries of list
+func lastStrings(list StringList, n int) StringList {
+	if len(list) <= n {
+		return list
+	}
+	return list[len(list)-n:]
+}
@@ -0,0 +1,352 @@
+package twitter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/velumlabs/loki/internal/utils"
+	"github.com/velumlabs/thor/db"
+	"github.com/velumlabs/thor/id"
+	"github.com/velumlabs/thor/pkg/twitter"
+)
+
+// platformTwitterReplay is the platform custom data value of replayed tweets.
+// The twitter manager only acts on the "twitter" platform, so replayed tweets
+// never fetch threads from or post to Twitter.
+const platformTwitterReplay = "twitter_replay"
+
+// replayContextLimit is the number of earlier conversation fragments copied into a scratch session
+const replayContextLimit = 20
+
+// ReplayOptions selects the tweets to replay.
+// Tweets are read from Source when set, otherwise from stored fragments,
+// either by ID or by creation time within [Start, End).
+type ReplayOptions struct {
+	TweetIDs []string
+	Start    time.Time
+	End      time.Time
+	Source   io.Reader // JSONL of ParsedTweet
+}
+
+// ReplayResult compares the stored reply to a tweet with the reply the current pipeline generates
+type ReplayResult struct {
+	TweetID       string         `json:"tweet_id"`
+	UserName      string         `json:"user_name"`
+	TweetText     string         `json:"tweet_text"`
+	OriginalReply string         `json:"original_reply"`
+	NewReply      string         `json:"new_reply"`
+	NewThread     []string       `json:"new_thread,omitempty"`
+	Decision      *ReplyDecision `json:"decision,omitempty"`
+	Error         string         `json:"error,omitempty"`
+}
+
+// ReplayReport is the outcome of a replay run
+type ReplayReport struct {
+	RunID   string         `json:"run_id"`
+	Results []ReplayResult `json:"results"`
+}
+
+// replayRun is the state of an active replay run
+type replayRun struct {
+	runID      string
+	sessionIDs []id.ID
+	current    *ReplayResult
+}
+
+// scratchID maps a tweet or conversation ID to its ID in the scratch sessions of the run
+func (r *replayRun) scratchID(twitterID string) string {
+	if twitterID == "" {
+		return ""
+	}
+	return "replay:" + r.runID + ":" + twitterID
+}
+
+// Replay runs stored or exported tweets through handleTweetProcessing again with posting disabled:
+//  1. Loads the tweets and the replies the agent originally posted
+//  2. Copies the earlier messages of each conversation into an isolated scratch session
+//  3. Processes each tweet in its scratch session, capturing the decision and the new reply
+//     instead of posting, liking, following or updating profiles
+//  4. Deletes the scratch sessions along with their fragments and insights
+//
+// Replay must not run concurrently with Start.
+func (k *Twitter) Replay(opts ReplayOptions) (*ReplayReport, error) {
+	tweets, err := k.loadReplayTweets(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &replayRun{runID: string(id.New())}
+	k.replay = run
+	defer func() {
+		k.replay = nil
+		if err := k.deleteScratchSessions(run.sessionIDs); err != nil {
+			k.logger.Errorf("Failed to delete replay scratch sessions: %v", err)
+		}
+	}()
+
+	report := &ReplayReport{RunID: run.runID}
+	for _, tweet := range tweets {
+		k.logger.Infof("Replaying tweet %s", tweet.TweetID)
+
+		result := ReplayResult{
+			TweetID:   tweet.TweetID,
+			UserName:  tweet.UserName,
+			TweetText: tweet.TweetText,
+		}
+
+		original, err := k.findOriginalReply(tweet.TweetID)
+		if err != nil {
+			k.logger.Errorf("Failed to find original reply to %s: %v", tweet.TweetID, err)
+		}
+		result.OriginalReply = original
+
+		run.current = &result
+		if err := k.replayTweet(run, tweet); err != nil {
+			result.Error = err.Error()
+		}
+		run.current = nil
+
+		report.Results = append(report.Results, result)
+	}
+
+	return report, nil
+}
+
+// replayTweet seeds the scratch session of a tweet and processes the tweet in it
+func (k *Twitter) replayTweet(run *replayRun, tweet *twitter.ParsedTweet) error {
+	scratch := *tweet
+	scratch.TweetID = run.scratchID(tweet.TweetID)
+	scratch.TweetConversationID = run.scratchID(tweet.TweetConversationID)
+	scratch.InReplyToTweetID = run.scratchID(tweet.InReplyToTweetID)
+
+	sessionID := id.FromString(scratch.TweetConversationID)
+	run.sessionIDs = append(run.sessionIDs, sessionID)
+
+	if err := k.assistant.UpsertSession(sessionID); err != nil {
+		return fmt.Errorf("failed to create scratch session: %w", err)
+	}
+	if err := k.seedScratchSession(run, tweet, sessionID); err != nil {
+		return err
+	}
+
+	return k.handleTweetProcessing(k.ctx, k.twitterConnector, tweetMessage(&scratch))
+}
+
+// seedScratchSession copies the conversation messages that preceded a tweet into its scratch session
+func (k *Twitter) seedScratchSession(run *replayRun, tweet *twitter.ParsedTweet, sessionID id.ID) error {
+	fragments, err := k.interactionFragmentStore.GetBySession(id.FromString(tweet.TweetConversationID), replayContextLimit)
+	if err != nil {
+		return fmt.Errorf("failed to load conversation: %w", err)
+	}
+
+	tweetTime := time.Unix(tweet.TweetCreatedAt, 0)
+	for _, fragment := range fragments {
+		if !fragment.CreatedAt.Before(tweetTime) || fragment.ID == id.FromString(tweet.TweetID) {
+			continue
+		}
+
+		metadata := db.Metadata{}
+		for key, value := range fragment.Metadata {
+			metadata[key] = value
+		}
+		for _, key := range []string{"tweet_id", "tweet_conversation_id", "in_reply_to_tweet_id"} {
+			if value, ok := metadata[key].(string); ok {
+				metadata[key] = run.scratchID(value)
+			}
+		}
+
+		scratchFragmentID := id.FromString(run.scratchID(string(fragment.ID)))
+		if tweetID, ok := metadata["tweet_id"].(string); ok && tweetID != "" {
+			scratchFragmentID = id.FromString(tweetID)
+		}
+
+		if err := k.interactionFragmentStore.Upsert(&db.Fragment{
+			ID:        scratchFragmentID,
+			ActorID:   fragment.ActorID,
+			SessionID: sessionID,
+			Content:   fragment.Content,
+			Metadata:  metadata,
+			Embedding: fragment.Embedding,
+			CreatedAt: fragment.CreatedAt,
+		}); err != nil {
+			return fmt.Errorf("failed to seed scratch session: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// captureReplay records the reply generated for a replayed tweet instead of posting it
+func (k *Twitter) captureReplay(response *db.Fragment) {
+	k.replay.current.NewReply = response.Content
+	if k.isThreadReply(response.Content) {
+		config := k.twitterConfig.Threads
+		k.replay.current.NewThread, _ = splitThread(response.Content, config.MaxWeightedLength, config.MaxTweets)
+	}
+}
+
+// deleteScratchSessions permanently removes the scratch sessions of a replay run and everything stored in them
+func (k *Twitter) deleteScratchSessions(sessionIDs []id.ID) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+
+	for _, table := range []db.FragmentTable{db.FragmentTableInteraction, db.FragmentTablePersonality, db.FragmentTableInsight, db.FragmentTableTwitter} {
+		if err := k.database.Table(string(table)).
+			Unscoped().
+			Where("session_id IN ?", sessionIDs).
+			Delete(&db.Fragment{}).Error; err != nil {
+			return fmt.Errorf("failed to delete %s fragments: %w", table, err)
+		}
+	}
+
+	return k.database.Unscoped().Where("id IN ?", sessionIDs).Delete(&db.Session{}).Error
+}
+
+// loadReplayTweets loads the tweets selected by the replay options, oldest first
+func (k *Twitter) loadReplayTweets(opts ReplayOptions) ([]*twitter.ParsedTweet, error) {
+	var tweets []*twitter.ParsedTweet
+
+	switch {
+	case opts.Source != nil:
+		scanner := bufio.NewScanner(opts.Source)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			var tweet twitter.ParsedTweet
+			if err := json.Unmarshal(scanner.Bytes(), &tweet); err != nil {
+				return nil, fmt.Errorf("failed to parse tweet on line %d: %w", line, err)
+			}
+			tweets = append(tweets, &tweet)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read tweets: %w", err)
+		}
+
+	case len(opts.TweetIDs) > 0:
+		for _, tweetID := range opts.TweetIDs {
+			fragment, err := k.interactionFragmentStore.GetByID(id.FromString(tweetID))
+			if err != nil {
+				return nil, fmt.Errorf("failed to load tweet %s: %w", tweetID, err)
+			}
+			tweet, err := decodeTweetFragment(fragment)
+			if err != nil {
+				return nil, err
+			}
+			tweets = append(tweets, tweet)
+		}
+
+	case !opts.Start.IsZero() && !opts.End.IsZero():
+		var fragments []db.Fragment
+		if err := k.database.Table(string(db.FragmentTableInteraction)).
+			Where("created_at >= ? AND created_at < ?", opts.Start, opts.End).
+			Where("actor_id <> ?", k.assistant.ID).
+			Where("metadata->>'tweet_id' IS NOT NULL").
+			Where("deleted_at IS NULL").
+			Order("created_at").
+			Find(&fragments).Error; err != nil {
+			return nil, fmt.Errorf("failed to load tweets: %w", err)
+		}
+		for i := range fragments {
+			tweet, err := decodeTweetFragment(&fragments[i])
+			if err != nil {
+				return nil, err
+			}
+			tweets = append(tweets, tweet)
+		}
+
+	default:
+		return nil, fmt.Errorf("replay requires a source, tweet IDs or a time range")
+	}
+
+	sort.SliceStable(tweets, func(i, j int) bool {
+		return tweets[i].TweetCreatedAt < tweets[j].TweetCreatedAt
+	})
+
+	return tweets, nil
+}
+
+// findOriginalReply returns the content of the reply the agent posted to a tweet, if any
+func (k *Twitter) findOriginalReply(tweetID string) (string, error) {
+	var fragments []db.Fragment
+	if err := k.database.Table(string(db.FragmentTableInteraction)).
+		Where("actor_id = ?", k.assistant.ID).
+		Where("metadata->>'in_reply_to_tweet_id' = ?", tweetID).
+		Where("deleted_at IS NULL").
+		Order("created_at").
+		Find(&fragments).Error; err != nil {
+		return "", err
+	}
+
+	var parts []string
+	for _, fragment := range fragments {
+		parts = append(parts, fragment.Content)
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+// decodeTweetFragment rebuilds the parsed tweet stored in the metadata of a fragment
+func decodeTweetFragment(fragment *db.Fragment) (*twitter.ParsedTweet, error) {
+	var tweet twitter.ParsedTweet
+	if err := utils.DecodeTweetMetadata(fragment.Metadata, &tweet); err != nil {
+		return nil, err
+	}
+	if tweet.TweetText == "" {
+		tweet.TweetText = fragment.Content
+	}
+	return &tweet, nil
+}
+
+// WriteText writes the report as a side-by-side comparison of original and new replies
+func (r *ReplayReport) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "TWEET\tUSER\tTEXT\tORIGINAL REPLY\tNEW REPLY\tDECISION\n")
+	for _, result := range r.Results {
+		decision := "-"
+		if result.Decision != nil {
+			decision = fmt.Sprintf("%s (%.2f)", result.Decision.Action, result.Decision.Confidence)
+		}
+
+		newReply := result.NewReply
+		if result.Error != "" {
+			newReply = "error: " + result.Error
+		}
+
+		fmt.Fprintf(tw, "%s\t@%s\t%s\t%s\t%s\t%s\n",
+			result.TweetID,
+			result.UserName,
+			singleLine(result.TweetText),
+			singleLine(result.OriginalReply),
+			singleLine(newReply),
+			decision,
+		)
+	}
+	return tw.Flush()
+}
+
+// singleLine collapses whitespace so that text fits in a report cell
+func singleLine(text string) string {
+	if text == "" {
+		return "-"
+	}
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// tweetPlatform returns the platform custom data value of tweets being processed
+func (k *Twitter) tweetPlatform() string {
+	if k.replay != nil {
+		return platformTwitterReplay
+	}
+	if k.chat != nil {
+		return platformLocal
+	}
+	return platformTwitter
+}
@@ -0,0 +1,430 @@
+package twitter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pgvector/pgvector-go"
+	"github.com/velumlabs/thor/db"
+	"github.com/velumlabs/thor/id"
+	"github.com/velumlabs/thor/llm"
+	"gorm.io/gorm"
+)
+
+// Retention metrics exposed on the admin API
+const metricRetentionDeleted = "wrz_retention_deleted_fragments_total"
+
+// retentionSummaryCategory is the insight category of the summaries of expired conversations
+const retentionSummaryCategory = "conversation_summary"
+
+// retentionTranscriptLimit is the number of characters of an expired conversation sent to the model,
+// longer conversations keep their most recent messages
+const retentionTranscriptLimit = 20000
+
+// RetentionTableReport is what the retention job removed, or would remove in a dry run, from a fragment table
+type RetentionTableReport struct {
+	Table              db.FragmentTable `json:"table"`
+	SummaryTable       db.FragmentTable `json:"summary_table,omitempty"`
+	Cutoff             time.Time        `json:"cutoff"`
+	ExpiredFragments   int64            `json:"expired_fragments"`
+	SummarizedSessions int              `json:"summarized_sessions"`
+	SkippedSessions    int              `json:"skipped_sessions"` // expired conversations kept until a later run
+	DeletedFragments   int64            `json:"deleted_fragments"`
+}
+
+// RetentionReport summarizes a run of the retention job
+type RetentionReport struct {
+	DryRun          bool                   `json:"dry_run"`
+	Tables          []RetentionTableReport `json:"tables"`
+	DeletedSessions int64                  `json:"deleted_sessions"` // sessions left without fragments
+	Vacuumed        []db.FragmentTable     `json:"vacuumed"`
+}
+
+// WriteText writes the report as an aligned text table
+func (r *RetentionReport) WriteText(w io.Writer) error {
+	if r.DryRun {
+		fmt.Fprintln(w, "Dry run, nothing was removed")
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TABLE\tCUTOFF\tEXPIRED\tSUMMARIZED SESSIONS\tSKIPPED SESSIONS\tDELETED\tSUMMARY TABLE")
+	for _, table := range r.Tables {
+		summaryTable := string(table.SummaryTable)
+		if summaryTable == "" {
+			summaryTable = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%s\n",
+			table.Table,
+			table.Cutoff.Format("2006-01-02 15:04"),
+			table.ExpiredFragments,
+			table.SummarizedSessions,
+			table.SkippedSessions,
+			table.DeletedFragments,
+			summaryTable,
+		)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Empty sessions deleted: %d\n", r.DeletedSessions)
+	if len(r.Vacuumed) > 0 {
+		tables := make([]string, len(r.Vacuumed))
+		for i, table := range r.Vacuumed {
+			tables[i] = string(table)
+		}
+		fmt.Fprintf(w, "Vacuumed and reindexed: %s\n", strings.Join(tables, ", "))
+	}
+	return nil
+}
+
+// monitorRetention periodically removes expired fragments.
+// It runs in a separate goroutine next to monitorTwitter and stops on
+// context cancellation or through the stopChan.
+func (k *Twitter) monitorRetention() {
+	config := k.twitterConfig.Retention
+	for {
+		report, err := k.RunRetention(false)
+		if err != nil {
+			k.logger.Errorf("Failed to apply retention policies: %v", err)
+		} else {
+			for _, table := range report.Tables {
+				k.logger.Infof("Retention removed %d %s fragments older than %v and summarized %d sessions",
+					table.DeletedFragments, table.Table, table.Cutoff.Format(time.RFC3339), table.SummarizedSessions)
+			}
+		}
+
+		interval := randomDuration(config.Interval)
+		k.logger.Infof("Waiting %v until next retention run", interval)
+
+		select {
+		case <-time.After(interval):
+			continue
+		case <-k.ctx.Done():
+			k.logger.Infof("Retention stopped")
+			return
+		case <-k.stopChan:
+			k.logger.Infof("Retention stopped")
+			return
+		}
+	}
+}
+
+// RunRetention applies the retention policies, or only reports what they would remove when dryRun is set:
+// 1. Summarizes the expired fragments of each conversation into a single insight fragment of the summary table,
+// then deletes them. Conversations beyond the summary limit of a run, or whose summary fails, are kept.
+// 2. Deletes the expired fragments of tables without a summary table
+// 3. Deletes the sessions left without any fragment
+// 4. Vacuums and reindexes the tables fragments were removed from
+// Tables that don't exist are skipped.
+func (k *Twitter) RunRetention(dryRun bool) (*RetentionReport, error) {
+	config := k.twitterConfig.Retention
+	report := &RetentionReport{DryRun: dryRun}
+	summaries := 0
+
+	for _, policy := range config.Policies {
+		if !k.database.Migrator().HasTable(string(policy.Table)) {
+			continue
+		}
+
+		table := RetentionTableReport{
+			Table:        policy.Table,
+			SummaryTable: policy.SummaryTable,
+			Cutoff:       time.Now().Add(-policy.MaxAge),
+		}
+
+		if err := k.expiredFragments(policy.Table, table.Cutoff).Count(&table.ExpiredFragments).Error; err != nil {
+			return nil, fmt.Errorf("failed to count expired %s fragments: %w", policy.Table, err)
+		}
+
+		var err error
+		if policy.SummaryTable != "" {
+			err = k.summarizeExpiredSessions(&table, config.MaxSummaries-summaries, dryRun)
+			summaries += table.SummarizedSessions
+		} else if dryRun {
+			table.DeletedFragments = table.ExpiredFragments
+		} else {
+			result := k.expiredFragments(policy.Table, table.Cutoff).Delete(&db.Fragment{})
+			table.DeletedFragments, err = result.RowsAffected, result.Error
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply %s retention: %w", policy.Table, err)
+		}
+
+		if !dryRun && table.DeletedFragments > 0 {
+			k.metrics.Add(metricRetentionDeleted, "Fragments removed by the retention job", Labels{"table": string(policy.Table)}, float64(table.DeletedFragments))
+		}
+		report.Tables = append(report.Tables, table)
+	}
+
+	deleted, err := k.deleteEmptySessions(report.Tables, dryRun)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete empty sessions: %w", err)
+	}
+	report.DeletedSessions = deleted
+
+	if config.Vacuum && !dryRun {
+		vacuumed, err := k.vacuumFragmentTables(report.Tables)
+		report.Vacuumed = vacuumed
+		if err != nil {
+			return report, fmt.Errorf("failed to vacuum fragment tables: %w", err)
+		}
+	}
+
+	return report, nil
+}
+
+// expiredFragments returns a query of the fragments of a table created before the cutoff, including soft deleted fragments
+func (k *Twitter) expiredFragments(table db.FragmentTable, cutoff time.Time) *gorm.DB {
+	return k.database.WithContext(k.ctx).
+		Table(string(table)).
+		Unscoped().
+		Where("created_at < ?", cutoff)
+}
+
+// summarizeExpiredSessions summarizes and deletes the expired fragments of up to limit sessions of a table.
+// In a dry run it only counts the sessions and fragments that would be summarized and deleted.
+func (k *Twitter) summarizeExpiredSessions(table *RetentionTableReport, limit int, dryRun bool) error {
+	var sessionIDs []id.ID
+	if err := k.expiredFragments(table.Table, table.Cutoff).
+		Distinct("session_id").
+		Pluck("session_id", &sessionIDs).Error; err != nil {
+		return fmt.Errorf("failed to list expired sessions: %w", err)
+	}
+
+	for _, sessionID := range sessionIDs {
+		if table.SummarizedSessions >= limit {
+			table.SkippedSessions++
+			continue
+		}
+
+		if dryRun {
+			var count int64
+			if err := k.expiredFragments(table.Table, table.Cutoff).
+				Where("session_id = ?", sessionID).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			table.SummarizedSessions++
+			table.DeletedFragments += count
+			continue
+		}
+
+		deleted, err := k.summarizeExpiredSession(table, sessionID)
+		if errors.Is(err, errBudgetExceeded) {
+			k.logger.Warnf("Daily LLM budget spent, keeping the remaining expired %s sessions", table.Table)
+			table.SkippedSessions += len(sessionIDs) - table.SummarizedSessions - table.SkippedSessions
+			break
+		}
+		if err != nil {
+			k.logger.Errorf("Failed to summarize expired session %s of %s: %v", sessionID, table.Table, err)
+			table.SkippedSessions++
+			continue
+		}
+		table.SummarizedSessions++
+		table.DeletedFragments += deleted
+	}
+
+	return nil
+}
+
+// summarizeExpiredSession stores a summary of the expired fragments of a session in the summary table
+// and deletes them in one transaction. Returns the number of fragments deleted.
+func (k *Twitter) summarizeExpiredSession(table *RetentionTableReport, sessionID id.ID) (int64, error) {
+	var fragments []db.Fragment
+	if err := k.database.WithContext(k.ctx).
+		Table(string(table.Table)).
+		Preload("Actor").
+		Where("session_id = ? AND created_at < ?", sessionID, table.Cutoff).
+		Order("created_at").
+		Find(&fragments).Error; err != nil {
+		return 0, fmt.Errorf("failed to load expired fragments: %w", err)
+	}
+
+	// Only soft deleted fragments have expired, there is nothing to summarize
+	var summary *db.Fragment
+	if len(fragments) > 0 {
+		if err := k.checkBudget(); err != nil {
+			return 0, err
+		}
+		var err error
+		if summary, err = k.summarizeFragments(sessionID, fragments); err != nil {
+			return 0, err
+		}
+	}
+
+	var deleted int64
+	err := k.database.WithContext(k.ctx).Transaction(func(tx *gorm.DB) error {
+		if summary != nil {
+			if err := tx.Table(string(table.SummaryTable)).Create(summary).Error; err != nil {
+				return fmt.Errorf("failed to store summary: %w", err)
+			}
+		}
+		result := tx.Table(string(table.Table)).
+			Unscoped().
+			Where("session_id = ? AND created_at < ?", sessionID, table.Cutoff).
+			Delete(&db.Fragment{})
+		deleted = result.RowsAffected
+		return result.Error
+	})
+	return deleted, err
+}
+
+// summarizeFragments asks the fast model for a summary of a conversation and returns it as an insight fragment
+// of the session, attributed to the first participant who isn't the agent
+func (k *Twitter) summarizeFragments(sessionID id.ID, fragments []db.Fragment) (*db.Fragment, error) {
+	actorID := fragments[0].ActorID
+	for _, fragment := range fragments {
+		if fragment.Actor != nil && !fragment.Actor.Assistant {
+			actorID = fragment.ActorID
+			break
+		}
+	}
+
+	var transcript strings.Builder
+	for _, fragment := range fragments {
+		name := string(fragment.ActorID)
+		if fragment.Actor != nil {
+			name = fragment.Actor.Name
+		}
+		transcript.WriteString(fmt.Sprintf("[%s] %s: %s\n", fragment.CreatedAt.Format("2006-01-02"), name, singleLine(fragment.Content)))
+	}
+	text := transcript.String()
+	if len(text) > retentionTranscriptLimit {
+		text = text[len(text)-retentionTranscriptLimit:]
+	}
+
+	_, endUsage := k.beginUsage(k.ctx, UsageScope{Purpose: UsagePurposeRetention})
+	defer endUsage()
+
+	response, err := k.llmClient.GenerateCompletion(llm.CompletionRequest{
+		Messages: []llm.Message{
+			llm.NewSystemMessage(`You archive old conversations of a Twitter persona before their messages are deleted.
+Summarize the conversation below in at most five sentences.
+Keep what would matter in a future conversation with the same people: topics, opinions, facts they shared about themselves and anything left unresolved.
+Reply with the summary only.`),
+			llm.NewUserMessage(text),
+		},
+		ModelType:   llm.ModelTypeFast,
+		Temperature: 0.2,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate summary: %w", err)
+	}
+
+	content := strings.TrimSpace(response.Content)
+	if content == "" {
+		return nil, fmt.Errorf("empty summary")
+	}
+
+	embedding, err := k.embedText(k.ctx, content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed summary: %w", err)
+	}
+
+	// Summaries are session insights, so the insight managers keep using them in the conversation
+	return &db.Fragment{
+		ID:        id.New(),
+		ActorID:   actorID,
+		SessionID: sessionID,
+		Content:   content,
+		Metadata: db.Metadata{
+			"type":                 "session_insights",
+			"category":             retentionSummaryCategory,
+			"confidence":           1.0,
+			"source_context":       "retention",
+			"timestamp":            time.Now().Unix(),
+			"summarized_fragments": len(fragments),
+			"first_at":             fragments[0].CreatedAt.Unix(),
+			"last_at":              fragments[len(fragments)-1].CreatedAt.Unix(),
+		},
+		Embedding: pgvector.NewVector(embedding),
+	}, nil
+}
+
+// deleteEmptySessions deletes the sessions older than every retention cutoff that have no fragment left.
+// In a dry run, expired fragments of the tables removed without a summary are counted as removed,
+// as summarized sessions keep their summary.
+func (k *Twitter) deleteEmptySessions(tables []RetentionTableReport, dryRun bool) (int64, error) {
+	if len(tables) == 0 {
+		return 0, nil
+	}
+
+	var oldest time.Time
+	removed := make(map[db.FragmentTable]time.Time)
+	for _, table := range tables {
+		if oldest.IsZero() || table.Cutoff.Before(oldest) {
+			oldest = table.Cutoff
+		}
+		if table.SummaryTable == "" {
+			removed[table.Table] = table.Cutoff
+		}
+	}
+
+	query := k.database.WithContext(k.ctx).
+		Model(&db.Session{}).
+		Unscoped().
+		Where("updated_at < ?", oldest)
+	for _, table := range append(fragmentTables, directMessageFragmentTables...) {
+		if !k.database.Migrator().HasTable(string(table)) {
+			continue
+		}
+		fragments := k.database.
+			Table(string(table)).
+			Unscoped().
+			Select("1").
+			Where(fmt.Sprintf("%q.session_id = sessions.id", table))
+		if cutoff, ok := removed[table]; ok && dryRun {
+			fragments = fragments.Where("created_at >= ?", cutoff)
+		}
+		query = query.Where("NOT EXISTS (?)", fragments)
+	}
+
+	if dryRun {
+		var count int64
+		err := query.Count(&count).Error
+		return count, err
+	}
+
+	result := query.Delete(&db.Session{})
+	return result.RowsAffected, result.Error
+}
+
+// vacuumFragmentTables reclaims the space of the tables fragments were removed from and rebuilds their indexes.
+// Returns the tables vacuumed before any failure.
+func (k *Twitter) vacuumFragmentTables(tables []RetentionTableReport) ([]db.FragmentTable, error) {
+	var vacuumed []db.FragmentTable
+	for _, table := range tables {
+		if table.DeletedFragments == 0 {
+			continue
+		}
+
+		// SQLite vacuums the whole database file at once
+		if isSQLite(k.database) {
+			if len(vacuumed) == 0 {
+				if err := k.database.WithContext(k.ctx).Exec("VACUUM").Error; err != nil {
+					return vacuumed, err
+				}
+			}
+			vacuumed = append(vacuumed, table.Table)
+			continue
+		}
+
+		// Vacuum can't run in a transaction and concurrent reindexing keeps the table writable
+		statements := []string{
+			fmt.Sprintf("VACUUM (ANALYZE) %q", table.Table),
+			fmt.Sprintf("REINDEX TABLE CONCURRENTLY %q", table.Table),
+		}
+		for _, statement := range statements {
+			if err := k.database.WithContext(k.ctx).Exec(statement).Error; err != nil {
+				return vacuumed, fmt.Errorf("failed to vacuum %s: %w", table.Table, err)
+			}
+		}
+		vacuumed = append(vacuumed, table.Table)
+	}
+	return vacuumed, nil
+}
@@ -0,0 +1,394 @@
+package twitter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/velumlabs/thor/db"
+	"github.com/velumlabs/thor/id"
+	"github.com/velumlabs/thor/llm"
+	"github.com/velumlabs/thor/pkg/twitter"
+)
+
+// questionPrefixes are sentence openers that usually indicate a question even
+// when the author left out the question mark
+var questionPrefixes = []string{
+	"what", "why", "how", "when", "where", "who", "which",
+	"can", "could", "do", "does", "did", "is", "are", "should", "would", "will",
+}
+
+// tweetSignals holds the raw ranking signals of a tweet that are not part of twitter.ParsedTweet
+type tweetSignals struct {
+	FollowersCount int
+	Verified       bool
+	LikeCount      int
+	ReplyCount     int
+	RetweetCount   int
+	QuoteCount     int
+}
+
+// tweetScore holds the normalized signal values and the weighted total of a candidate tweet
+type tweetScore struct {
+	Tweet *twitter.ParsedTweet
+
+	Followers      float64
+	Verified       float64
+	DirectReply    float64
+	Engagement     float64
+	Question       float64
+	Relevance      float64
+	PastEngagement float64
+	Total          float64
+}
+
+// candidateTTL is how long the tweets returned by searches are remembered,
+// well past the age at which tweets are skipped as too old
+const candidateTTL = 24 * time.Hour
+
+// candidate is what the agent remembers about a tweet returned by a search
+type candidate struct {
+	seenAt    time.Time
+	userID    string
+	skipped   string   // reason the tweet was last audited as skipped, empty if it wasn't
+	relevance *float64 // nil until the relevance of the tweet is scored
+}
+
+// candidateCache remembers the tweets returned by recent searches. Searches return the same tweets
+// cycle after cycle until they are answered or too old, the cache lets the ranking audit and publish
+// each tweet once and ask the model for its relevance once. Tweets are audited again after a restart.
+// It is safe for concurrent use.
+type candidateCache struct {
+	ttl time.Duration
+
+	mu     sync.Mutex
+	tweets map[string]*candidate
+}
+
+// newCandidateCache returns an empty cache forgetting tweets ttl after they were first seen
+func newCandidateCache(ttl time.Duration) *candidateCache {
+	return &candidateCache{
+		ttl:    ttl,
+		tweets: make(map[string]*candidate),
+	}
+}
+
+// see remembers a tweet and reports whether it wasn't seen before
+func (c *candidateCache) see(tweet *twitter.ParsedTweet) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.tweets[tweet.TweetID] != nil {
+		return false
+	}
+	c.tweets[tweet.TweetID] = &candidate{seenAt: time.Now(), userID: tweet.UserID}
+	return true
+}
+
+// skip records the reason a tweet is skipped for, empty when it is selected,
+// and reports whether the reason changed. Forgotten tweets always report a change.
+func (c *candidateCache) skip(tweetID, reason string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	candidate := c.tweets[tweetID]
+	if candidate == nil {
+		return true
+	}
+	if candidate.skipped == reason {
+		return false
+	}
+	candidate.skipped = reason
+	return true
+}
+
+// relevance returns the relevance score of a tweet and whether it was scored
+func (c *candidateCache) relevance(tweetID string) (float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	candidate := c.tweets[tweetID]
+	if candidate == nil || candidate.relevance == nil {
+		return 0, false
+	}
+	return *candidate.relevance, true
+}
+
+// setRelevance remembers the relevance score of a tweet seen before
+func (c *candidateCache) setRelevance(tweetID string, relevance float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if candidate := c.tweets[tweetID]; candidate != nil {
+		candidate.relevance = &relevance
+	}
+}
+
+// forgetUser forgets the tweets of a user
+func (c *candidateCache) forgetUser(twitterUserID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for tweetID, candidate := range c.tweets {
+		if candidate.userID == twitterUserID {
+			delete(c.tweets, tweetID)
+		}
+	}
+}
+
+// prune forgets the tweets seen longer than the TTL ago
+func (c *candidateCache) prune() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for tweetID, candidate := range c.tweets {
+		if time.Since(candidate.seenAt) > c.ttl {
+			delete(c.tweets, tweetID)
+		}
+	}
+}
+
+// relevanceResponse is the structured output returned by the relevance scorer
+type relevanceResponse struct {
+	Relevance float64 `json:"relevance" jsonschema:"required,minimum=0,maximum=1"`
+}
+
+// extractTweetSignals collects follower, verification and engagement counts
+// for every tweet in a search timeline response, keyed by tweet ID.
+func extractTweetSignals(res *twitter.SearchTimelineResponse) map[string]tweetSignals {
+	signals := make(map[string]tweetSignals)
+	for _, instruction := range res.Data.SearchByRawQuery.SearchTimeline.Timeline.Instructions {
+		if instruction.Type != "TimelineAddEntries" {
+			continue
+		}
+		for _, entry := range instruction.Entries {
+			result := entry.Content.ItemContent.TweetResults.Result
+			if result.Legacy.IDStr == "" {
+				continue
+			}
+
+			user := result.Core.UserResults.Result
+			signals[result.Legacy.IDStr] = tweetSignals{
+				FollowersCount: user.Legacy.FollowersCount,
+				Verified:       user.IsBlueVerified || user.Legacy.Verified,
+				LikeCount:      result.Legacy.FavoriteCount,
+				ReplyCount:     result.Legacy.ReplyCount,
+				RetweetCount:   result.Legacy.RetweetCount,
+				QuoteCount:     result.Legacy.QuoteCount,
+			}
+		}
+	}
+	return signals
+}
+
+// rankTweets scores candidate tweets and returns the ones that fit in the reply budget.
+// - Drops own tweets, tweets that are too old and tweets that were already processed
+// - Drops tweets from muted conversations, from muted or do-not-engage users and mentioning sensitive topics
+// - Scores the remaining tweets using the configured weights, reusing the relevance scored in previous cycles
+// - Returns the top-N tweets in descending score order
+func (k *Twitter) rankTweets(tweets []*twitter.ParsedTweet, signals map[string]tweetSignals) []*twitter.ParsedTweet {
+	scoring := k.twitterConfig.Scoring
+	k.candidates.prune()
+
+	var scores []tweetScore
+	for _, tweet := range tweets {
+		if k.isOwnTweet(tweet.UserName) {
+			continue
+		}
+		if _, err := k.interactionFragmentStore.GetByID(id.FromString(tweet.TweetID)); err == nil {
+			continue
+		}
+
+		// Searches return the same tweets cycle after cycle, each is audited when first seen
+		// and audited and published again only when the reason it is skipped for changes
+		if k.candidates.see(tweet) {
+			k.audit(AuditTweetFetched, tweet, db.Metadata{"created_at": tweet.TweetCreatedAt})
+		}
+
+		if reason, details := k.skipReason(tweet); reason != "" {
+			if k.candidates.skip(tweet.TweetID, reason) {
+				k.auditSkipped(tweet, reason, details)
+				k.publishModerated(tweet, reason, details)
+			}
+			continue
+		}
+
+		score, err := k.scoreTweet(tweet, signals[tweet.TweetID])
+		if err != nil {
+			k.logger.Errorf("Failed to score tweet %s: %v", tweet.TweetID, err)
+		}
+		scores = append(scores, score)
+	}
+
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].Total > scores[j].Total
+	})
+
+	var ranked []*twitter.ParsedTweet
+	for i, score := range scores {
+		selected := len(ranked) < scoring.ReplyBudget && score.Total >= scoring.MinScore
+
+		k.logger.WithFields(map[string]interface{}{
+			"rank":            i + 1,
+			"tweet_id":        score.Tweet.TweetID,
+			"user_name":       score.Tweet.UserName,
+			"followers":       score.Followers,
+			"verified":        score.Verified,
+			"direct_reply":    score.DirectReply,
+			"engagement":      score.Engagement,
+			"question":        score.Question,
+			"relevance":       score.Relevance,
+			"past_engagement": score.PastEngagement,
+			"total":           score.Total,
+			"selected":        selected,
+		}).Infof("Scored tweet")
+
+		if selected {
+			ranked = append(ranked, score.Tweet)
+			k.candidates.skip(score.Tweet.TweetID, "")
+		} else if k.candidates.skip(score.Tweet.TweetID, SkipReasonNotSelected) {
+			k.auditSkipped(score.Tweet, SkipReasonNotSelected, db.Metadata{
+				"rank":  i + 1,
+				"score": score.Total,
+			})
+		}
+	}
+
+	return ranked
+}
+
+// skipReason returns why a new tweet must not be scored and the details of the reason, empty if it is a candidate
+func (k *Twitter) skipReason(tweet *twitter.ParsedTweet) (string, db.Metadata) {
+	switch {
+	case k.isTweetTooOld(tweet):
+		return SkipReasonTooOld, nil
+	case k.isDoNotEngage(tweet.UserID):
+		return SkipReasonDoNotEngage, nil
+	case k.isMuted(tweet.TweetConversationID, tweet.UserID, tweet.UserName):
+		return SkipReasonMuted, nil
+	}
+	if topic := k.sensitiveTopic(tweet.TweetText); topic != "" {
+		return SkipReasonSensitiveTopic, db.Metadata{"topic": topic}
+	}
+	return "", nil
+}
+
+// scoreTweet computes the normalized signals of a tweet and their weighted total.
+// Every signal is scaled to the [0, 1] range before the weights are applied.
+// Signals that fail to compute count as zero, the score is returned along with the error.
+func (k *Twitter) scoreTweet(tweet *twitter.ParsedTweet, signals tweetSignals) (tweetScore, error) {
+	weights := k.twitterConfig.Scoring.Weights
+
+	score := tweetScore{
+		Tweet:     tweet,
+		Followers: math.Min(math.Log10(1+float64(signals.FollowersCount))/6, 1),
+		Question:  boolToFloat(isQuestion(tweet.TweetText)),
+		Verified:  boolToFloat(signals.Verified),
+	}
+
+	// Replies to the original post rank above replies deeper in the thread
+	switch {
+	case tweet.InReplyToTweetID == tweet.TweetConversationID:
+		score.DirectReply = 1
+	case k.isOwnTweet(tweet.InReplyToScreenName):
+		score.DirectReply = 0.5
+	}
+
+	interactions := signals.LikeCount + 2*(signals.ReplyCount+signals.RetweetCount+signals.QuoteCount)
+	score.Engagement = math.Min(math.Log10(1+float64(interactions))/3, 1)
+
+	// The relevance is the only signal asked to the model, it is scored once per tweet
+	// while the counts change from one search to the next
+	var errs []error
+	if weights.Relevance > 0 {
+		relevance, ok := k.candidates.relevance(tweet.TweetID)
+		if !ok {
+			var err error
+			_, endUsage := k.beginUsage(k.ctx, k.tweetUsageScope(UsagePurposeScoring, tweet))
+			relevance, err = k.scoreRelevance(tweet)
+			endUsage()
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				k.candidates.setRelevance(tweet.TweetID, relevance)
+			}
+		}
+		score.Relevance = relevance
+	}
+
+	if weights.PastEngagement > 0 {
+		pastEngagement, err := k.pastEngagementScore(tweet.UserID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to score past engagement with %s: %w", tweet.UserName, err))
+		}
+		score.PastEngagement = pastEngagement
+	}
+
+	score.Total = weights.Followers*score.Followers +
+		weights.Verified*score.Verified +
+		weights.DirectReply*score.DirectReply +
+		weights.Engagement*score.Engagement +
+		weights.Question*score.Question +
+		weights.Relevance*score.Relevance +
+		weights.PastEngagement*score.PastEngagement
+
+	return score, errors.Join(errs...)
+}
+
+// scoreRelevance asks the fast model how worthwhile it is to reply to the tweet.
+// Returns a value between 0 and 1.
+func (k *Twitter) scoreRelevance(tweet *twitter.ParsedTweet) (float64, error) {
+	var response relevanceResponse
+	err := k.llmClient.GenerateStructuredOutput(llm.StructuredOutputRequest{
+		Messages: []llm.Message{
+			llm.NewSystemMessage(fmt.Sprintf(`You rank replies received by the Twitter account @%s.
+Rate from 0 to 1 how relevant and worthwhile it is to reply to the tweet below.
+Substantive questions and comments that invite a conversation score high.
+Spam, links without context, emoji-only tweets and one word acknowledgements score low.`, k.twitterConfig.Credentials.User)),
+			llm.NewUserMessage(fmt.Sprintf("@%s: %s", tweet.UserName, tweet.TweetText)),
+		},
+		ModelType:    llm.ModelTypeFast,
+		SchemaName:   "tweet_relevance",
+		StrictSchema: true,
+	}, &response)
+	if err != nil {
+		return 0, fmt.Errorf("failed to generate relevance score: %w", err)
+	}
+
+	return math.Max(0, math.Min(response.Relevance, 1)), nil
+}
+
+// isQuestion reports whether the text looks like a question
+func isQuestion(text string) bool {
+	if strings.Contains(text, "?") {
+		return true
+	}
+
+	// Skip leading mentions, which are added automatically to replies
+	words := strings.Fields(strings.ToLower(text))
+	for len(words) > 0 && strings.HasPrefix(words[0], "@") {
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return false
+	}
+
+	for _, prefix := range questionPrefixes {
+		if words[0] == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// boolToFloat converts a boolean signal to 1 or 0
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,249 @@
+package twitter
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/velumlabs/thor/db"
+	"github.com/velumlabs/thor/id"
+	"github.com/velumlabs/thor/pkg/twitter"
+	"github.com/velumlabs/thor/stores"
+)
+
+func TestIsQuestion(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{text: "is this thing on?", want: true},
+		{text: "trailing mark?", want: true},
+		{text: "How does it work", want: true},
+		{text: "@agent @bob what do you think", want: true},
+		{text: "@agent nice thread", want: false},
+		{text: "@agent", want: false},
+		{text: "", want: false},
+		{text: "whatever you say", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := isQuestion(tt.text); got != tt.want {
+				t.Errorf("isQuestion(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoreTweet(t *testing.T) {
+	k := &Twitter{candidates: newCandidateCache(candidateTTL)}
+	k.twitterConfig.Credentials.User = "agent"
+	k.twitterConfig.Scoring.Weights = ScoringWeights{
+		Followers:   1,
+		Verified:    1,
+		DirectReply: 1,
+		Engagement:  1,
+		Question:    1,
+		Relevance:   1,
+	}
+
+	tests := []struct {
+		name    string
+		tweet   *twitter.ParsedTweet
+		signals tweetSignals
+		want    tweetScore
+	}{
+		{
+			name:  "direct reply question",
+			tweet: &twitter.ParsedTweet{TweetID: "1", TweetConversationID: "100", InReplyToTweetID: "100", TweetText: "why?"},
+			signals: tweetSignals{
+				FollowersCount: 999999,
+				Verified:       true,
+				LikeCount:      999,
+			},
+			want: tweetScore{Followers: 1, Verified: 1, DirectReply: 1, Engagement: 1, Question: 1, Relevance: 0.5, Total: 5.5},
+		},
+		{
+			name:  "reply to the agent deeper in the thread",
+			tweet: &twitter.ParsedTweet{TweetID: "2", TweetConversationID: "100", InReplyToTweetID: "101", InReplyToScreenName: "Agent", TweetText: "ok"},
+			want:  tweetScore{DirectReply: 0.5, Relevance: 0.5, Total: 1},
+		},
+		{
+			name:    "engagement counts replies, retweets and quotes twice",
+			tweet:   &twitter.ParsedTweet{TweetID: "3", TweetConversationID: "100", InReplyToTweetID: "102", TweetText: "ok"},
+			signals: tweetSignals{ReplyCount: 1, RetweetCount: 1, LikeCount: 5},
+			want:    tweetScore{Engagement: 1.0 / 3, Relevance: 0.5, Total: 1.0/3 + 0.5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The cached relevance is reused instead of asking the model
+			k.candidates.see(tt.tweet)
+			k.candidates.setRelevance(tt.tweet.TweetID, 0.5)
+
+			got, err := k.scoreTweet(tt.tweet, tt.signals)
+			if err != nil {
+				t.Fatalf("scoreTweet() error = %v", err)
+			}
+			if got.Tweet != tt.tweet {
+				t.Errorf("scoreTweet() scored tweet %v, want %v", got.Tweet, tt.tweet)
+			}
+			for name, values := range map[string][2]float64{
+				"followers":    {got.Followers, tt.want.Followers},
+				"verified":     {got.Verified, tt.want.Verified},
+				"direct reply": {got.DirectReply, tt.want.DirectReply},
+				"engagement":   {got.Engagement, tt.want.Engagement},
+				"question":     {got.Question, tt.want.Question},
+				"relevance":    {got.Relevance, tt.want.Relevance},
+				"total":        {got.Total, tt.want.Total},
+			} {
+				if math.Abs(values[0]-values[1]) > 0.01 {
+					t.Errorf("%s = %.3f, want %.3f", name, values[0], values[1])
+				}
+			}
+		})
+	}
+}
+
+func TestCandidateCache(t *testing.T) {
+	cache := newCandidateCache(time.Hour)
+	alice := &twitter.ParsedTweet{TweetID: "1", UserID: "42"}
+	bob := &twitter.ParsedTweet{TweetID: "2", UserID: "43"}
+
+	if !cache.see(alice) || !cache.see(bob) {
+		t.Fatal("see() = false for new tweets, want true")
+	}
+	if cache.see(alice) {
+		t.Error("see() = true for a tweet seen before, want false")
+	}
+
+	if !cache.skip("1", SkipReasonMuted) {
+		t.Error("skip() = false for a new reason, want true")
+	}
+	if cache.skip("1", SkipReasonMuted) {
+		t.Error("skip() = true for the same reason, want false")
+	}
+	if !cache.skip("1", "") {
+		t.Error("skip() = false when the tweet is selected after a skip, want true")
+	}
+
+	if _, ok := cache.relevance("1"); ok {
+		t.Error("relevance() found a score before it was set")
+	}
+	cache.setRelevance("1", 0.7)
+	if relevance, ok := cache.relevance("1"); !ok || relevance != 0.7 {
+		t.Errorf("relevance() = %v, %v, want 0.7, true", relevance, ok)
+	}
+	cache.setRelevance("unknown", 0.7)
+	if _, ok := cache.relevance("unknown"); ok {
+		t.Error("setRelevance() remembered a tweet that wasn't seen")
+	}
+
+	cache.forgetUser("42")
+	if !cache.see(alice) {
+		t.Error("see() = false after the author was forgotten, want true")
+	}
+	if _, ok := cache.relevance("1"); ok {
+		t.Error("relevance() kept the score of a forgotten user")
+	}
+	if cache.see(bob) {
+		t.Error("forgetUser() forgot the tweets of another user")
+	}
+
+	cache.tweets["2"].seenAt = time.Now().Add(-2 * time.Hour)
+	cache.prune()
+	if !cache.see(bob) {
+		t.Error("prune() kept a tweet older than the TTL")
+	}
+	if cache.see(alice) {
+		t.Error("prune() forgot a tweet younger than the TTL")
+	}
+}
+
+func TestRankTweets(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDatabase(t)
+
+	k := &Twitter{
+		ctx:                      ctx,
+		logger:                   newTestLogger(t),
+		candidates:               newCandidateCache(candidateTTL),
+		interactionFragmentStore: stores.NewFragmentStore(ctx, database, db.FragmentTableInteraction),
+		doNotEngageStore:         NewDoNotEngageStore(ctx, database),
+		controlStore:             NewControlStore(ctx, database),
+	}
+	k.twitterConfig.Credentials.User = "agent"
+	k.twitterConfig.Scoring = ScoringConfig{
+		ReplyBudget: 2,
+		MinScore:    0.5,
+		Weights:     ScoringWeights{DirectReply: 1, Question: 1},
+	}
+
+	if err := k.doNotEngageStore.Upsert(&DoNotEngage{TwitterUserID: "blocked", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("failed to add to the do-not-engage list: %v", err)
+	}
+	if err := k.controlStore.Mute(MuteKindUser, "muted"); err != nil {
+		t.Fatalf("failed to mute: %v", err)
+	}
+	newTestFragment(t, database, db.FragmentTableInteraction, id.FromString("answered"), db.Metadata{})
+
+	now := time.Now().Unix()
+	tweet := func(tweetID, userID, userName, inReplyTo, text string) *twitter.ParsedTweet {
+		return &twitter.ParsedTweet{
+			TweetID:             tweetID,
+			TweetConversationID: "100",
+			InReplyToTweetID:    inReplyTo,
+			UserID:              userID,
+			UserName:            userName,
+			TweetText:           text,
+			TweetCreatedAt:      now,
+		}
+	}
+	old := tweet("old", "1", "carol", "100", "why?")
+	old.TweetCreatedAt = now - int64((6 * time.Hour).Seconds())
+
+	tweets := []*twitter.ParsedTweet{
+		tweet("own", "0", "Agent", "100", "why?"),
+		tweet("answered", "1", "carol", "100", "why?"),
+		old,
+		tweet("blocked", "blocked", "dave", "100", "why?"),
+		tweet("muted", "2", "muted", "100", "why?"),
+		tweet("reply", "1", "carol", "100", "nice"),
+		tweet("question", "1", "carol", "101", "how?"),
+		tweet("best", "1", "carol", "100", "why?"),
+		tweet("low", "1", "carol", "101", "nice"),
+	}
+
+	want := []string{"best", "reply"}
+	for cycle := 1; cycle <= 2; cycle++ {
+		var got []string
+		for _, tweet := range k.rankTweets(tweets, nil) {
+			got = append(got, tweet.TweetID)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("cycle %d: rankTweets() = %v, want %v", cycle, got, want)
+		}
+	}
+
+	// Skipped tweets are remembered with the reason they were skipped for
+	for tweetID, reason := range map[string]string{
+		"old":      SkipReasonTooOld,
+		"blocked":  SkipReasonDoNotEngage,
+		"muted":    SkipReasonMuted,
+		"question": SkipReasonNotSelected,
+		"low":      SkipReasonNotSelected,
+	} {
+		if k.candidates.skip(tweetID, reason) {
+			t.Errorf("tweet %s wasn't skipped as %s", tweetID, reason)
+		}
+	}
+	// Own and answered tweets are dropped before they are remembered
+	for _, tweetID := range []string{"own", "answered"} {
+		if !k.candidates.see(&twitter.ParsedTweet{TweetID: tweetID}) {
+			t.Errorf("tweet %s was remembered", tweetID)
+		}
+	}
+}
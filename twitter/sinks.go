@@ -0,0 +1,263 @@
+package twitter
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Webhook request headers
+const (
+	WebhookHeaderEvent     = "X-Wrz-Event"     // event type
+	WebhookHeaderDelivery  = "X-Wrz-Delivery"  // event ID, the same across retries
+	WebhookHeaderTimestamp = "X-Wrz-Timestamp" // unix seconds the request was signed at
+	WebhookHeaderSignature = "X-Wrz-Signature" // "sha256=" followed by the hex HMAC of the timestamp, a dot and the body
+)
+
+// WebhookConfig configures a webhook sink
+type WebhookConfig struct {
+	URL        string
+	Secret     string        // HMAC key of the signature header, empty sends unsigned requests
+	MaxRetries int           // retries of network errors, 429 and 5xx responses
+	Backoff    time.Duration // delay before the first retry, doubled on every retry
+	Timeout    time.Duration // timeout of a single request
+}
+
+// WebhookSink posts events as JSON to an HTTP endpoint
+type WebhookSink struct {
+	config WebhookConfig
+	client *http.Client
+}
+
+// NewWebhookSink returns a sink posting to the URL of the config.
+// Returns an error if the URL is not an absolute http or https URL.
+func NewWebhookSink(config WebhookConfig) (*WebhookSink, error) {
+	parsed, err := url.Parse(config.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid webhook URL: %q", config.URL)
+	}
+	if config.MaxRetries < 0 {
+		return nil, fmt.Errorf("webhook retries cannot be negative")
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+
+	return &WebhookSink{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}, nil
+}
+
+// Name returns the host of the webhook, the URL may contain a token
+func (s *WebhookSink) Name() string {
+	parsed, _ := url.Parse(s.config.URL)
+	return "webhook:" + parsed.Host
+}
+
+// Publish posts the event, retrying failures that may be transient
+func (s *WebhookSink) Publish(ctx context.Context, event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	backoff := s.config.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(ctx, event, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= s.config.MaxRetries {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return fmt.Errorf("%w, giving up: %v", err, ctx.Err())
+		}
+	}
+}
+
+// post sends a single request and reports whether a failure is worth retrying
+func (s *WebhookSink) post(ctx context.Context, event *Event, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookHeaderEvent, string(event.Type))
+	req.Header.Set(WebhookHeaderDelivery, string(event.ID))
+	req.Header.Set(WebhookHeaderTimestamp, timestamp)
+	if s.config.Secret != "" {
+		req.Header.Set(WebhookHeaderSignature, SignWebhook(s.config.Secret, timestamp, body))
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return false, nil
+	}
+	retry := res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+	return retry, fmt.Errorf("webhook responded with status %d", res.StatusCode)
+}
+
+// SignWebhook returns the signature header value of a webhook request.
+// Receivers verify a request by computing it from the timestamp header and the raw body,
+// comparing it in constant time and rejecting old timestamps.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// FileSink appends events as JSON lines to a local file
+type FileSink struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileSink returns a sink appending to the file at path, creating it if needed
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event file: %w", err)
+	}
+	return &FileSink{path: path, file: file}, nil
+}
+
+// Name returns the path of the file
+func (s *FileSink) Name() string {
+	return "file:" + s.path
+}
+
+// Publish appends the event to the file
+func (s *FileSink) Publish(_ context.Context, event *Event) error {
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(encoded, '\n'))
+	return err
+}
+
+// Close closes the file
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// RedisStreamSink adds events to a Redis stream. Entries hold the event type, ID and JSON payload.
+type RedisStreamSink struct {
+	client *redis.Client
+	stream string
+	maxLen int64
+}
+
+// NewRedisStreamSink returns a sink adding to the stream of the Redis server at a redis:// URL.
+// The stream is trimmed to about maxLen entries, zero keeps every entry.
+func NewRedisStreamSink(redisURL, stream string, maxLen int64) (*RedisStreamSink, error) {
+	if stream == "" {
+		return nil, fmt.Errorf("redis stream name cannot be empty")
+	}
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	return &RedisStreamSink{
+		client: redis.NewClient(opts),
+		stream: stream,
+		maxLen: maxLen,
+	}, nil
+}
+
+// Name returns the name of the stream
+func (s *RedisStreamSink) Name() string {
+	return "redis:" + s.stream
+}
+
+// Publish adds the event to the stream
+func (s *RedisStreamSink) Publish(ctx context.Context, event *Event) error {
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	return s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.stream,
+		MaxLen: s.maxLen,
+		Approx: true,
+		Values: map[string]interface{}{
+			"id":      string(event.ID),
+			"type":    string(event.Type),
+			"payload": encoded,
+		},
+	}).Err()
+}
+
+// Close closes the connections to Redis
+func (s *RedisStreamSink) Close() error {
+	return s.client.Close()
+}
+
+// MemorySink keeps published events in memory, a stand-in for real sinks in tests and local runs
+type MemorySink struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+// NewMemorySink returns an empty memory sink
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+// Name returns "memory"
+func (s *MemorySink) Name() string {
+	return "memory"
+}
+
+// Publish stores a copy of the event
+func (s *MemorySink) Publish(_ context.Context, event *Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, *event)
+	return nil
+}
+
+// Events returns the events published so far, oldest first
+func (s *MemorySink) Events() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Event(nil), s.events...)
+}
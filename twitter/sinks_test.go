@@ -0,0 +1,128 @@
+package twitter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/velumlabs/thor/db"
+	"github.com/velumlabs/thor/pkg/twitter"
+)
+
+func TestSignWebhook(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      string
+		want      string
+	}{
+		{
+			name:      "event",
+			secret:    "secret",
+			timestamp: "1700000000",
+			body:      `{"type":"reply.posted"}`,
+			want:      "sha256=28b853f01ad92db108680821a78f9cfabe2da1a63e55bcfb200e415a1bc7edb9",
+		},
+		{
+			name:      "empty secret and body",
+			timestamp: "0",
+			want:      "sha256=b849d5a581847b281957065739df36df2463d1977ea8d6e1e4e6cf33fadc68c3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SignWebhook(tt.secret, tt.timestamp, []byte(tt.body)); got != tt.want {
+				t.Errorf("SignWebhook() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// Every signed part changes the signature
+	base := SignWebhook("secret", "1700000000", []byte("body"))
+	for name, signature := range map[string]string{
+		"secret":    SignWebhook("other", "1700000000", []byte("body")),
+		"timestamp": SignWebhook("secret", "1700000001", []byte("body")),
+		"body":      SignWebhook("secret", "1700000000", []byte("other")),
+	} {
+		if signature == base {
+			t.Errorf("changing the %s doesn't change the signature", name)
+		}
+	}
+}
+
+func TestPublishModerated(t *testing.T) {
+	tweet := &twitter.ParsedTweet{TweetID: "1", TweetConversationID: "1", UserID: "42", UserName: "alice", TweetText: "hi"}
+
+	tests := []struct {
+		name       string
+		reason     string
+		details    db.Metadata
+		evaluating bool
+		want       *Event
+	}{
+		{
+			name:   "muted",
+			reason: SkipReasonMuted,
+			want:   &Event{Type: EventTweetModerated, Agent: "agent", Reason: SkipReasonMuted},
+		},
+		{
+			name:   "do not engage users are not published",
+			reason: SkipReasonDoNotEngage,
+		},
+		{
+			name:    "sensitive topic",
+			reason:  SkipReasonSensitiveTopic,
+			details: db.Metadata{"topic": "politics"},
+			want:    &Event{Type: EventTweetModerated, Agent: "agent", Reason: SkipReasonSensitiveTopic, Topic: "politics"},
+		},
+		{
+			name:   "other skips are not published",
+			reason: SkipReasonTooOld,
+		},
+		{
+			name:       "evaluations publish nothing",
+			reason:     SkipReasonMuted,
+			evaluating: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := NewMemorySink()
+			k := &Twitter{
+				events: NewEventPublisher(newTestLogger(t), NewMetrics(), EventsConfig{
+					Sinks:      []EventSink{sink},
+					BufferSize: 10,
+					Timeout:    time.Second,
+				}),
+				evaluating: tt.evaluating,
+			}
+			k.twitterConfig.Credentials.User = "agent"
+
+			k.publishModerated(tweet, tt.reason, tt.details)
+			if err := k.events.Close(); err != nil {
+				t.Fatalf("failed to close publisher: %v", err)
+			}
+
+			events := sink.Events()
+			if tt.want == nil {
+				if len(events) != 0 {
+					t.Fatalf("published %d events, want none", len(events))
+				}
+				return
+			}
+			if len(events) != 1 {
+				t.Fatalf("published %d events, want 1", len(events))
+			}
+
+			got := events[0]
+			if got.Type != tt.want.Type || got.Agent != tt.want.Agent || got.Reason != tt.want.Reason || got.Topic != tt.want.Topic {
+				t.Errorf("published %+v, want %+v", got, *tt.want)
+			}
+			if got.Tweet == nil || got.Tweet.ID != tweet.TweetID || got.Tweet.UserName != tweet.UserName {
+				t.Errorf("published tweet %+v, want tweet %s by @%s", got.Tweet, tweet.TweetID, tweet.UserName)
+			}
+		})
+	}
+}
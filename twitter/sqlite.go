@@ -0,0 +1,223 @@
+package twitter
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/glebarez/go-sqlite"
+	gormsqlite "github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DialectSQLite is the gorm dialect name of SQLite databases
+const DialectSQLite = "sqlite"
+
+// sqliteRewrites translate the Postgres specific SQL of the fragment and actor stores:
+// - pgvector cosine distance becomes the vector_cosine_distance function
+// - ILIKE becomes LIKE, which is case insensitive for ASCII in SQLite
+// - gen_random_uuid() defaults become expressions, as SQLite requires
+// - the jsonb key existence operator, escaped as ??, becomes a json_type check
+// - jsonb casts are dropped, JSON is stored as text
+var sqliteRewrites = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile("([\\w.`\"]+)\\s*<=>\\s*\\?"), "vector_cosine_distance($1, ?)"},
+	{regexp.MustCompile(`\bILIKE\b`), "LIKE"},
+	{regexp.MustCompile("([\\w.`\"]+)\\s*\\?\\?\\s*\\?"), "json_type($1, '$.' || ?) IS NOT NULL"},
+	{regexp.MustCompile(`DEFAULT gen_random_uuid\(\)`), "DEFAULT (gen_random_uuid())"},
+	{regexp.MustCompile(`::jsonb\b`), ""},
+}
+
+var registerSQLiteFunctions sync.Once
+
+// OpenSQLite opens an embedded SQLite database at path, for local development and CI.
+// The stores written for Postgres and pgvector work unchanged:
+// vectors are stored as text and compared by a brute-force cosine distance function,
+// and metadata is stored as JSON blobs read with the SQLite JSON operators.
+// Updates through SQLite JSON functions must cast their result to a blob, as db.Metadata only scans bytes.
+func OpenSQLite(path string) (*gorm.DB, error) {
+	var err error
+	registerSQLiteFunctions.Do(func() {
+		err = sqlite.RegisterDeterministicScalarFunction("vector_cosine_distance", 2, vectorCosineDistance)
+		if err == nil {
+			err = sqlite.RegisterScalarFunction("gen_random_uuid", 0, func(*sqlite.FunctionContext, []driver.Value) (driver.Value, error) {
+				return uuid.NewString(), nil
+			})
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to register SQLite functions: %w", err)
+	}
+
+	// Writers serialize on a single connection, which also keeps in-memory databases alive
+	conn, err := sql.Open(gormsqlite.DriverName, path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	conn.SetMaxOpenConns(1)
+
+	return gorm.Open(gormsqlite.Dialector{
+		DriverName: gormsqlite.DriverName,
+		Conn:       &sqliteConnPool{db: conn},
+	}, &gorm.Config{})
+}
+
+// vectorCosineDistance returns the cosine distance of two vectors in pgvector text format,
+// matching the pgvector <=> operator
+func vectorCosineDistance(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	a, err := parseSQLiteVector(args[0])
+	if err != nil {
+		return nil, err
+	}
+	b, err := parseSQLiteVector(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if a == nil || b == nil {
+		return nil, nil
+	}
+	if len(a) != len(b) {
+		return nil, fmt.Errorf("different vector dimensions %d and %d", len(a), len(b))
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return math.NaN(), nil
+	}
+	return 1 - dot/(math.Sqrt(normA)*math.Sqrt(normB)), nil
+}
+
+// parseSQLiteVector parses a vector stored as text such as [1,2,3], nil for NULL
+func parseSQLiteVector(value driver.Value) ([]float64, error) {
+	var text string
+	switch value := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		text = value
+	case []byte:
+		text = string(value)
+	default:
+		return nil, fmt.Errorf("unsupported vector type %T", value)
+	}
+
+	text = strings.Trim(strings.TrimSpace(text), "[]")
+	if text == "" {
+		return []float64{}, nil
+	}
+
+	fields := strings.Split(text, ",")
+	vector := make([]float64, len(fields))
+	for i, field := range fields {
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vector: %w", err)
+		}
+		vector[i] = parsed
+	}
+	return vector, nil
+}
+
+// rewriteSQLite applies sqliteRewrites to a statement
+func rewriteSQLite(query string) string {
+	for _, rewrite := range sqliteRewrites {
+		query = rewrite.pattern.ReplaceAllString(query, rewrite.replacement)
+	}
+	return query
+}
+
+// sqliteArgs encodes map arguments as JSON, which the Postgres driver does implicitly for jsonb columns
+func sqliteArgs(args []interface{}) []interface{} {
+	for i, arg := range args {
+		if m, ok := arg.(map[string]interface{}); ok {
+			if encoded, err := json.Marshal(m); err == nil {
+				args[i] = encoded
+			}
+		}
+	}
+	return args
+}
+
+// sqliteConnPool rewrites every statement and its arguments before passing them to the SQLite connection
+type sqliteConnPool struct {
+	db *sql.DB
+}
+
+func (p *sqliteConnPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return p.db.PrepareContext(ctx, rewriteSQLite(query))
+}
+
+func (p *sqliteConnPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return p.db.ExecContext(ctx, rewriteSQLite(query), sqliteArgs(args)...)
+}
+
+func (p *sqliteConnPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return p.db.QueryContext(ctx, rewriteSQLite(query), sqliteArgs(args)...)
+}
+
+func (p *sqliteConnPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return p.db.QueryRowContext(ctx, rewriteSQLite(query), sqliteArgs(args)...)
+}
+
+// BeginTx starts a transaction whose statements are rewritten as well
+func (p *sqliteConnPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	tx, err := p.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteTx{tx: tx}, nil
+}
+
+// GetDBConn returns the underlying connection, used by gorm.DB.DB
+func (p *sqliteConnPool) GetDBConn() (*sql.DB, error) {
+	return p.db, nil
+}
+
+// sqliteTx rewrites the statements of a transaction
+type sqliteTx struct {
+	tx *sql.Tx
+}
+
+func (t *sqliteTx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return t.tx.PrepareContext(ctx, rewriteSQLite(query))
+}
+
+func (t *sqliteTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return t.tx.ExecContext(ctx, rewriteSQLite(query), sqliteArgs(args)...)
+}
+
+func (t *sqliteTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return t.tx.QueryContext(ctx, rewriteSQLite(query), sqliteArgs(args)...)
+}
+
+func (t *sqliteTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return t.tx.QueryRowContext(ctx, rewriteSQLite(query), sqliteArgs(args)...)
+}
+
+func (t *sqliteTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *sqliteTx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// isSQLite reports whether the connection is an SQLite database
+func isSQLite(tx *gorm.DB) bool {
+	return tx.Dialector.Name() == DialectSQLite
+}
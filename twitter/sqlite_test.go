@@ -0,0 +1,253 @@
+package twitter
+
+import (
+	"context"
+	"database/sql/driver"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/pgvector/pgvector-go"
+	"github.com/velumlabs/thor/db"
+	"github.com/velumlabs/thor/id"
+	"github.com/velumlabs/thor/stores"
+)
+
+func TestRewriteSQLite(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "cosine distance",
+			query: `SELECT *, (interaction.embedding <=> ?) as similarity FROM "interaction"`,
+			want:  `SELECT *, (vector_cosine_distance(interaction.embedding, ?)) as similarity FROM "interaction"`,
+		},
+		{
+			name:  "quoted cosine distance",
+			query: "ORDER BY `embedding`<=>?",
+			want:  "ORDER BY vector_cosine_distance(`embedding`, ?)",
+		},
+		{
+			name:  "ilike",
+			query: "SELECT * FROM actors WHERE name ILIKE ?",
+			want:  "SELECT * FROM actors WHERE name LIKE ?",
+		},
+		{
+			name:  "ilike inside a word is kept",
+			query: "SELECT smilike FROM t",
+			want:  "SELECT smilike FROM t",
+		},
+		{
+			name:  "jsonb key existence",
+			query: "WHERE interaction.metadata ?? ?",
+			want:  "WHERE json_type(interaction.metadata, '$.' || ?) IS NOT NULL",
+		},
+		{
+			name:  "uuid default",
+			query: "CREATE TABLE sessions (id uuid DEFAULT gen_random_uuid(),PRIMARY KEY (id))",
+			want:  "CREATE TABLE sessions (id uuid DEFAULT (gen_random_uuid()),PRIMARY KEY (id))",
+		},
+		{
+			name:  "jsonb casts",
+			query: "metadata jsonb NOT NULL DEFAULT '{}'::jsonb",
+			want:  "metadata jsonb NOT NULL DEFAULT '{}'",
+		},
+		{
+			name:  "plain statements are unchanged",
+			query: "SELECT * FROM actors WHERE id = ?",
+			want:  "SELECT * FROM actors WHERE id = ?",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rewriteSQLite(tt.query); got != tt.want {
+				t.Errorf("rewriteSQLite(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSQLiteVector(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    []float64
+		wantErr bool
+	}{
+		{name: "null", value: nil},
+		{name: "text", value: "[1,2.5,-3]", want: []float64{1, 2.5, -3}},
+		{name: "bytes with spaces", value: []byte(" [1, 2] "), want: []float64{1, 2}},
+		{name: "empty", value: "[]", want: []float64{}},
+		{name: "invalid number", value: "[1,x]", wantErr: true},
+		{name: "unsupported type", value: int64(1), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSQLiteVector(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSQLiteVector(%v) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSQLiteVector(%v) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVectorCosineDistance(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "same direction", a: "[1,2]", b: "[2,4]", want: 0.0},
+		{name: "orthogonal", a: "[1,0]", b: "[0,1]", want: 1.0},
+		{name: "opposite", a: "[1,0]", b: "[-1,0]", want: 2.0},
+		{name: "null", a: nil, b: "[1,0]", want: nil},
+		{name: "zero vector", a: "[0,0]", b: "[1,0]", want: math.NaN()},
+		{name: "different dimensions", a: "[1,0]", b: "[1,0,0]", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := vectorCosineDistance(nil, []driver.Value{tt.a, tt.b})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("vectorCosineDistance() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			want, ok := tt.want.(float64)
+			if !ok {
+				if got != nil {
+					t.Errorf("vectorCosineDistance() = %v, want nil", got)
+				}
+				return
+			}
+			distance, ok := got.(float64)
+			if !ok || (math.IsNaN(want) != math.IsNaN(distance)) || (!math.IsNaN(want) && math.Abs(distance-want) > 1e-9) {
+				t.Errorf("vectorCosineDistance() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// TestSQLiteStores runs the stores the agent creates against an in-memory database
+func TestSQLiteStores(t *testing.T) {
+	ctx := context.Background()
+	database, err := OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		if conn, err := database.DB(); err == nil {
+			conn.Close()
+		}
+	})
+	if _, err := NewMigrator(ctx, database).Up(); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	actorStore := stores.NewActorStore(ctx, database)
+	sessionStore := stores.NewSessionStore(ctx, database)
+	fragmentStore := stores.NewFragmentStore(ctx, database, db.FragmentTableInteraction)
+
+	alice := &db.Actor{ID: id.New(), Name: "Alice"}
+	bob := &db.Actor{ID: id.New(), Name: "bob"}
+	for _, actor := range []*db.Actor{alice, bob} {
+		if err := actorStore.Create(actor); err != nil {
+			t.Fatalf("failed to create actor: %v", err)
+		}
+	}
+	session := &db.Session{ID: id.New()}
+	if err := sessionStore.Create(session); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	fragments := []*db.Fragment{
+		{ID: id.New(), ActorID: alice.ID, Content: "close", Metadata: db.Metadata{"tweet_id": "1", "user_name": "alice"}, Embedding: pgvector.NewVector([]float32{1, 0.1})},
+		{ID: id.New(), ActorID: bob.ID, Content: "far", Metadata: db.Metadata{"tweet_id": "2"}, Embedding: pgvector.NewVector([]float32{0, 1})},
+		{ID: id.New(), ActorID: alice.ID, Content: "exact", Metadata: db.Metadata{"tweet_id": "3"}, Embedding: pgvector.NewVector([]float32{1, 0})},
+	}
+	for _, fragment := range fragments {
+		fragment.SessionID = session.ID
+		if err := fragmentStore.Create(fragment); err != nil {
+			t.Fatalf("failed to create fragment: %v", err)
+		}
+	}
+
+	contents := func(fragments []db.Fragment) []string {
+		var contents []string
+		for _, fragment := range fragments {
+			contents = append(contents, fragment.Content)
+		}
+		return contents
+	}
+
+	t.Run("search actors case insensitively", func(t *testing.T) {
+		actors, err := actorStore.Search("ALI", 10)
+		if err != nil {
+			t.Fatalf("Search() error = %v", err)
+		}
+		if len(actors) != 1 || actors[0].ID != alice.ID {
+			t.Errorf("Search() = %v, want Alice", actors)
+		}
+	})
+
+	t.Run("similarity search", func(t *testing.T) {
+		similar, err := fragmentStore.SearchSimilar(pgvector.NewVector([]float32{1, 0}), session.ID, 10)
+		if err != nil {
+			t.Fatalf("SearchSimilar() error = %v", err)
+		}
+		if got, want := contents(similar), []string{"exact", "close", "far"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("SearchSimilar() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("metadata filters", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			condition stores.MetadataCondition
+			want      []string
+		}{
+			{name: "key exists", condition: stores.MetadataCondition{Key: "user_name", Operator: stores.MetadataOpContains}, want: []string{"close"}},
+			{name: "equals", condition: stores.MetadataCondition{Key: "tweet_id", Value: "2", Operator: stores.MetadataOpEquals}, want: []string{"far"}},
+			{name: "in", condition: stores.MetadataCondition{Key: "tweet_id", Value: []interface{}{"1", "2"}, Operator: stores.MetadataOpIn}, want: []string{"close", "far"}},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				embedding := pgvector.NewVector([]float32{1, 0})
+				found, err := fragmentStore.SearchByFilter(stores.FragmentFilter{
+					SessionID: &session.ID,
+					Metadata:  []stores.MetadataCondition{tt.condition},
+					Embedding: &embedding,
+				})
+				if err != nil {
+					t.Fatalf("SearchByFilter() error = %v", err)
+				}
+				if got := contents(found); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("SearchByFilter() = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	})
+
+	t.Run("metadata updates", func(t *testing.T) {
+		if err := fragmentStore.UpdateMetadata(fragments[1].ID, map[string]interface{}{"tweet_id": "2", "user_name": "bob"}); err != nil {
+			t.Fatalf("UpdateMetadata() error = %v", err)
+		}
+		fragment, err := fragmentStore.GetByID(fragments[1].ID)
+		if err != nil {
+			t.Fatalf("GetByID() error = %v", err)
+		}
+		if fragment.Metadata["user_name"] != "bob" {
+			t.Errorf("metadata = %v, want user_name bob", fragment.Metadata)
+		}
+	})
+}
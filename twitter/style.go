@@ -0,0 +1,228 @@
+package twitter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/velumlabs/thor/llm"
+	"github.com/velumlabs/thor/managers/personality"
+)
+
+// Names of the style rules compiled from the personality
+const (
+	StyleRuleLowercase = "lowercase"
+	StyleRuleEmoticons = "emoticons"
+	StyleRuleMaxWords  = "max_words"
+)
+
+// Style metrics exposed on the admin API
+const (
+	metricStyleChecks        = "wrz_style_rule_checks_total"
+	metricStyleViolations    = "wrz_style_rule_violations_total"
+	metricStyleCompliance    = "wrz_style_rule_compliance_ratio"
+	metricStyleCorrections   = "wrz_style_rule_corrections_total"
+	metricStyleRegenerations = "wrz_style_regenerations_total"
+)
+
+// wordLimitPattern matches explicit word limits in style entries, such as "under 30 words"
+var wordLimitPattern = regexp.MustCompile(`(\d+)\s+words`)
+
+// wordPattern matches the whitespace separated words of a text
+var wordPattern = regexp.MustCompile(`\S+`)
+
+// textEmoticons are ASCII emoticons accepted by the emoticon rule besides symbol characters
+var textEmoticons = []string{":)", ":(", ":d", ":3", ";)", "<3", "^^", "^_^", "xd", "uwu"}
+
+// StyleRule is a check compiled from a personality style entry
+type StyleRule struct {
+	Name   string
+	Style  string                   // style entry the rule was compiled from
+	Check  func(text string) bool   // reports whether the text follows the rule
+	Fix    func(text string) string // corrects a violation cheaply, nil when only a regeneration can
+	Length bool                     // constrains length, skipped for replies posted as threads
+}
+
+// CompileStyleRules compiles the style entries it recognizes into rules:
+// - "lowercase" becomes an all-lowercase rule, corrected by lowercasing
+// - "emoticon" or "emoji" becomes an emoticon presence rule
+// - "concise" or "N words" becomes a maximum word count rule, defaulting to maxWords
+// Entries that are not recognized stay hints for the LLM only.
+func CompileStyleRules(p *personality.Personality, maxWords int) []StyleRule {
+	var rules []StyleRule
+	compiled := make(map[string]bool)
+
+	add := func(rule StyleRule) {
+		if !compiled[rule.Name] {
+			compiled[rule.Name] = true
+			rules = append(rules, rule)
+		}
+	}
+
+	for _, style := range p.Style {
+		lower := strings.ToLower(style)
+
+		if strings.Contains(lower, "lowercase") {
+			add(StyleRule{
+				Name:  StyleRuleLowercase,
+				Style: style,
+				Check: isLowercase,
+				Fix:   toLowercase,
+			})
+		}
+
+		if strings.Contains(lower, "emoticon") || strings.Contains(lower, "emoji") {
+			add(StyleRule{
+				Name:  StyleRuleEmoticons,
+				Style: style,
+				Check: containsEmoticon,
+			})
+		}
+
+		limit := 0
+		if match := wordLimitPattern.FindStringSubmatch(lower); match != nil {
+			limit, _ = strconv.Atoi(match[1])
+		} else if strings.Contains(lower, "concise") {
+			limit = maxWords
+		}
+		if limit > 0 {
+			add(StyleRule{
+				Name:  StyleRuleMaxWords,
+				Style: style,
+				Check: func(text string) bool {
+					return len(wordPattern.FindAllString(text, -1)) <= limit
+				},
+				Length: true,
+			})
+		}
+	}
+
+	return rules
+}
+
+// isLowercase reports whether the text has no uppercase letters outside of links
+func isLowercase(text string) bool {
+	return text == toLowercase(text)
+}
+
+// toLowercase lowercases every word of the text except links
+func toLowercase(text string) string {
+	return wordPattern.ReplaceAllStringFunc(text, func(word string) string {
+		if strings.HasPrefix(word, "http://") || strings.HasPrefix(word, "https://") {
+			return word
+		}
+		return strings.ToLower(word)
+	})
+}
+
+// containsEmoticon reports whether the text contains a symbol such as ♡ or an ASCII emoticon such as :)
+func containsEmoticon(text string) bool {
+	for _, r := range text {
+		if r > unicode.MaxASCII && (unicode.IsSymbol(r) || unicode.In(r, unicode.Katakana, unicode.Hiragana)) {
+			return true
+		}
+	}
+
+	lower := strings.ToLower(text)
+	for _, emoticon := range textEmoticons {
+		if strings.Contains(lower, emoticon) {
+			return true
+		}
+	}
+	return false
+}
+
+// enforceStyle checks a generated final answer against the style rules:
+// 1. Records every rule outcome of the first answer in the metrics
+// 2. Fixes violations that have a cheap correction, such as case
+// 3. Regenerates the response with feedback on the remaining violations,
+// up to the configured number of times
+// Returns the best answer, which may still violate rules once regenerations run out.
+func (k *Twitter) enforceStyle(messages []llm.Message, response, finalAnswer string, long bool, generate func([]llm.Message) (string, error)) (string, error) {
+	config := k.twitterConfig.Style
+	if !config.Enabled || len(k.styleRules) == 0 {
+		return finalAnswer, nil
+	}
+
+	for attempt := 0; ; attempt++ {
+		var violated []StyleRule
+		// Compliance is measured on what the model writes first, not on the re-checks of regenerations
+		finalAnswer, violated = k.applyStyleRules(finalAnswer, long, attempt == 0)
+		if len(violated) == 0 {
+			return finalAnswer, nil
+		}
+
+		names := make([]string, len(violated))
+		for i, rule := range violated {
+			names[i] = rule.Name
+		}
+
+		if attempt >= config.MaxRegenerations {
+			k.logger.Warnf("Reply still violates style rules %s after %d regenerations", strings.Join(names, ", "), attempt)
+			return finalAnswer, nil
+		}
+
+		k.logger.Infof("Regenerating reply violating style rules %s", strings.Join(names, ", "))
+		k.metrics.Inc(metricStyleRegenerations, "Responses regenerated because of style rule violations", nil)
+
+		var feedback strings.Builder
+		feedback.WriteString("Your final answer breaks these rules of your style:\n")
+		for _, rule := range violated {
+			feedback.WriteString(fmt.Sprintf("- %s\n", rule.Style))
+		}
+		feedback.WriteString("Rewrite it following every rule, using the same <contemplator> and <final_answer> structure.")
+
+		messages = append(messages,
+			llm.Message{Role: llm.RoleAssistant, Content: response},
+			llm.Message{Role: llm.RoleUser, Content: feedback.String()},
+		)
+
+		regenerated, err := generate(messages)
+		if err != nil {
+			return "", fmt.Errorf("failed to regenerate response: %w", err)
+		}
+		regeneratedAnswer := extractTag(regenerated, "final_answer")
+		if regeneratedAnswer == "" {
+			k.logger.Warnf("Regenerated response has no final answer, keeping the previous one")
+			return finalAnswer, nil
+		}
+		response, finalAnswer = regenerated, regeneratedAnswer
+	}
+}
+
+// applyStyleRules fixes what can be fixed cheaply and returns the corrected answer along with
+// the rules it still violates. The outcome of every rule is recorded when record is set.
+// Length rules are skipped for long answers.
+func (k *Twitter) applyStyleRules(answer string, long, record bool) (string, []StyleRule) {
+	var violated []StyleRule
+	for _, rule := range k.styleRules {
+		if rule.Length && long {
+			continue
+		}
+
+		labels := Labels{"rule": rule.Name}
+		passed := rule.Check(answer)
+		if record {
+			k.metrics.Inc(metricStyleChecks, "Generated answers checked against a style rule", labels)
+			if !passed {
+				k.metrics.Inc(metricStyleViolations, "Generated answers violating a style rule", labels)
+			}
+			checks := k.metrics.Value(metricStyleChecks, labels)
+			violations := k.metrics.Value(metricStyleViolations, labels)
+			k.metrics.Set(metricStyleCompliance, "Share of generated answers following a style rule before corrections", labels, (checks-violations)/checks)
+		}
+
+		if passed {
+			continue
+		}
+		if rule.Fix != nil {
+			answer = rule.Fix(answer)
+			k.metrics.Inc(metricStyleCorrections, "Style rule violations corrected without regenerating", labels)
+			continue
+		}
+		violated = append(violated, rule)
+	}
+	return answer, violated
+}
@@ -0,0 +1,161 @@
+package twitter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/velumlabs/thor/llm"
+	"github.com/velumlabs/thor/managers/personality"
+)
+
+func TestCompileStyleRules(t *testing.T) {
+	type check struct {
+		text string
+		want bool
+	}
+
+	tests := []struct {
+		name      string
+		style     []string
+		maxWords  int
+		wantRules []string
+		checks    map[string][]check // expected outcomes of the rule checks, by rule name
+	}{
+		{
+			name:     "no style",
+			maxWords: 40,
+		},
+		{
+			name:     "unrecognized entries stay hints",
+			style:    []string{"playful", "references anime"},
+			maxWords: 40,
+		},
+		{
+			name:      "lowercase",
+			style:     []string{"writes in all lowercase"},
+			maxWords:  40,
+			wantRules: []string{StyleRuleLowercase},
+			checks: map[string][]check{
+				StyleRuleLowercase: {
+					{text: "hello there", want: true},
+					{text: "Hello there", want: false},
+					{text: "look https://Example.com/Page", want: true},
+				},
+			},
+		},
+		{
+			name:      "emoticons",
+			style:     []string{"uses cute emoticons"},
+			maxWords:  40,
+			wantRules: []string{StyleRuleEmoticons},
+			checks: map[string][]check{
+				StyleRuleEmoticons: {
+					{text: "hi :3", want: true},
+					{text: "hi ♡", want: true},
+					{text: "hi", want: false},
+				},
+			},
+		},
+		{
+			name:      "explicit word limit",
+			style:     []string{"replies in under 3 words"},
+			maxWords:  40,
+			wantRules: []string{StyleRuleMaxWords},
+			checks: map[string][]check{
+				StyleRuleMaxWords: {
+					{text: "one two three", want: true},
+					{text: "one two three four", want: false},
+				},
+			},
+		},
+		{
+			name:      "concise uses the default word limit",
+			style:     []string{"concise"},
+			maxWords:  2,
+			wantRules: []string{StyleRuleMaxWords},
+			checks: map[string][]check{
+				StyleRuleMaxWords: {
+					{text: "one two", want: true},
+					{text: "one two three", want: false},
+				},
+			},
+		},
+		{
+			name:      "duplicate entries compile once",
+			style:     []string{"lowercase", "always lowercase, with emoji", "Lowercase only"},
+			maxWords:  40,
+			wantRules: []string{StyleRuleLowercase, StyleRuleEmoticons},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := CompileStyleRules(&personality.Personality{Style: tt.style}, tt.maxWords)
+
+			var names []string
+			for _, rule := range rules {
+				names = append(names, rule.Name)
+			}
+			if !reflect.DeepEqual(names, tt.wantRules) {
+				t.Fatalf("CompileStyleRules() rules = %v, want %v", names, tt.wantRules)
+			}
+
+			for _, rule := range rules {
+				if rule.Length != (rule.Name == StyleRuleMaxWords) {
+					t.Errorf("rule %s Length = %v", rule.Name, rule.Length)
+				}
+				for _, c := range tt.checks[rule.Name] {
+					if got := rule.Check(c.text); got != c.want {
+						t.Errorf("rule %s Check(%q) = %v, want %v", rule.Name, c.text, got, c.want)
+					}
+				}
+				if rule.Fix != nil && !rule.Check(rule.Fix(strings.ToUpper("fixed text"))) {
+					t.Errorf("rule %s Fix doesn't satisfy its check", rule.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestEnforceStyleRecordsFirstAnswer(t *testing.T) {
+	k := &Twitter{
+		logger:  newTestLogger(t),
+		metrics: NewMetrics(),
+		styleRules: []StyleRule{{
+			Name:  "short",
+			Style: "short",
+			Check: func(text string) bool { return len(text) <= 5 },
+		}},
+	}
+	k.twitterConfig.Style = StyleConfig{Enabled: true, MaxRegenerations: 2}
+
+	// Every regeneration still violates the rule
+	generations := 0
+	generate := func([]llm.Message) (string, error) {
+		generations++
+		return "<final_answer>still too long</final_answer>", nil
+	}
+
+	answer, err := k.enforceStyle(nil, "<final_answer>too long</final_answer>", "too long", false, generate)
+	if err != nil {
+		t.Fatalf("enforceStyle() error = %v", err)
+	}
+	if answer != "still too long" {
+		t.Errorf("enforceStyle() = %q, want the last regeneration", answer)
+	}
+	if generations != 2 {
+		t.Errorf("regenerated %d times, want 2", generations)
+	}
+
+	labels := Labels{"rule": "short"}
+	if checks := k.metrics.Value(metricStyleChecks, labels); checks != 1 {
+		t.Errorf("recorded %v checks, want 1", checks)
+	}
+	if violations := k.metrics.Value(metricStyleViolations, labels); violations != 1 {
+		t.Errorf("recorded %v violations, want 1", violations)
+	}
+	if compliance := k.metrics.Value(metricStyleCompliance, labels); compliance != 0 {
+		t.Errorf("compliance = %v, want 0", compliance)
+	}
+}
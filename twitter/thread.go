@@ -0,0 +1,310 @@
+package twitter
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/pgvector/pgvector-go"
+	"github.com/velumlabs/thor/db"
+	"github.com/velumlabs/thor/id"
+	"github.com/velumlabs/thor/pkg/twitter"
+)
+
+// platformTwitterPosted is the platform custom data value used once a reply was posted through
+// the Twitter connector, so that the twitter manager does not post the response a second time.
+const platformTwitterPosted = "twitter_posted"
+
+// maxTweetWeightedLength is the weighted length limit Twitter puts on a single tweet
+const maxTweetWeightedLength = 280
+
+// urlWeightedLength is the weight Twitter gives to every link, whatever its length
+const urlWeightedLength = 23
+
+var urlPattern = regexp.MustCompile(`https?://\S+`)
+
+// weightedLength returns the length of the text as counted by Twitter.
+// Latin and common punctuation characters weigh 1, every other character
+// weighs 2 and links weigh 23.
+func weightedLength(text string) int {
+	length := 0
+	for _, url := range urlPattern.FindAllString(text, -1) {
+		length += urlWeightedLength
+		text = strings.Replace(text, url, "", 1)
+	}
+
+	for _, r := range text {
+		switch {
+		case r <= 4351,
+			r >= 8192 && r <= 8205,
+			r >= 8208 && r <= 8223,
+			r >= 8242 && r <= 8247:
+			length++
+		default:
+			length += 2
+		}
+	}
+
+	return length
+}
+
+// minThreadPartLength is the weighted length every thread tweet must have room for besides
+// its numbering, so that any character fits
+const minThreadPartLength = 2
+
+// threadSuffixLength returns the length of the widest " i/n" suffix of a thread of maxTweets tweets
+func threadSuffixLength(maxTweets int) int {
+	return len(fmt.Sprintf(" %d/%d", maxTweets, maxTweets))
+}
+
+// splitThread splits text into tweets numbered "i/n" that fit the weighted length limit.
+// Text is split on sentence boundaries first, then on words for sentences that don't fit
+// in a single tweet. At most maxTweets parts are returned, truncated reports whether text
+// beyond them was dropped.
+func splitThread(text string, maxWeightedLength, maxTweets int) (parts []string, truncated bool) {
+	// Reserve room for the widest possible " i/n" suffix
+	limit := max(maxWeightedLength-threadSuffixLength(maxTweets), minThreadPartLength)
+
+	var chunks []string
+	for _, sentence := range splitSentences(text) {
+		if weightedLength(sentence) <= limit {
+			chunks = append(chunks, sentence)
+			continue
+		}
+		chunks = append(chunks, splitWords(sentence, limit)...)
+	}
+
+	current := ""
+	for _, chunk := range chunks {
+		candidate := chunk
+		if current != "" {
+			candidate = current + " " + chunk
+		}
+		if current == "" || weightedLength(candidate) <= limit {
+			current = candidate
+			continue
+		}
+		parts = append(parts, current)
+		current = chunk
+	}
+	if current != "" {
+		parts = append(parts, current)
+	}
+
+	if len(parts) > maxTweets {
+		parts, truncated = parts[:maxTweets], true
+	}
+
+	for i := range parts {
+		parts[i] = fmt.Sprintf("%s %d/%d", parts[i], i+1, len(parts))
+	}
+
+	return parts, truncated
+}
+
+// splitSentences splits text after sentence ending punctuation and on line breaks
+func splitSentences(text string) []string {
+	var sentences []string
+	var builder strings.Builder
+
+	flush := func() {
+		if sentence := strings.TrimSpace(builder.String()); sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+		builder.Reset()
+	}
+
+	runes := []rune(text)
+	for i, r := range runes {
+		if r == '\n' {
+			flush()
+			continue
+		}
+		builder.WriteRune(r)
+		if strings.ContainsRune(".!?…", r) && (i+1 == len(runes) || unicode.IsSpace(runes[i+1])) {
+			flush()
+		}
+	}
+	flush()
+
+	return sentences
+}
+
+// splitWords splits a sentence on spaces into chunks within the weighted length limit.
+// Words longer than the limit are cut, keeping at least one character per chunk so that
+// every cut makes progress even when a single character exceeds the limit.
+func splitWords(sentence string, limit int) []string {
+	var chunks []string
+	current := ""
+	for _, word := range strings.Fields(sentence) {
+		for weightedLength(word) > limit {
+			if current != "" {
+				chunks = append(chunks, current)
+				current = ""
+			}
+			cut := []rune(word)
+			for len(cut) > 1 && weightedLength(string(cut)) > limit {
+				cut = cut[:len(cut)-1]
+			}
+			chunks = append(chunks, string(cut))
+			word = word[len(string(cut)):]
+		}
+		if word == "" {
+			continue
+		}
+
+		candidate := word
+		if current != "" {
+			candidate = current + " " + word
+		}
+		if weightedLength(candidate) <= limit {
+			current = candidate
+			continue
+		}
+		chunks = append(chunks, current)
+		current = word
+	}
+	if current != "" {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// replyLengthRequirement returns the prompt requirement on the length of replies
+func (k *Twitter) replyLengthRequirement() string {
+	config := k.twitterConfig.Threads
+	if !config.Enabled {
+		return "Keep final response concise and tweet-length appropriate"
+	}
+	return fmt.Sprintf("Keep final response concise and tweet-length appropriate. Only when the question genuinely merits depth, write a longer answer of up to %d tweets, which will be posted as a thread", config.MaxTweets)
+}
+
+// tweetLengthLimit returns the weighted length limit of a single tweet posted by the agent.
+// The limit of thread parts only applies when threads are enabled.
+func (k *Twitter) tweetLengthLimit() int {
+	if config := k.twitterConfig.Threads; config.Enabled {
+		return config.MaxWeightedLength
+	}
+	return maxTweetWeightedLength
+}
+
+// isThreadReply reports whether a reply is too long for a single tweet and should be posted as a thread
+func (k *Twitter) isThreadReply(content string) bool {
+	config := k.twitterConfig.Threads
+	return config.Enabled && weightedLength(content) > config.MaxWeightedLength
+}
+
+// publishThread posts a long reply as a thread:
+// 1. Splits the reply into numbered tweets
+// 2. Posts each tweet as a reply to the previous one, the first replying to the tweet
+// 3. Stores every tweet after the first as its own fragment in the conversation, so that
+// replies to them find their conversation. The first tweet is stored by the reply pipeline.
+// Returns the ID of the first tweet, or an error if posting or storing fails.
+// Tweets posted before a failure stay stored.
+func (k *Twitter) publishThread(ctx context.Context, tweet *twitter.ParsedTweet, content string) (string, error) {
+	config := k.twitterConfig.Threads
+	parts, truncated := splitThread(content, config.MaxWeightedLength, config.MaxTweets)
+	if truncated {
+		k.logger.Warnf("Reply to tweet %s is longer than %d tweets, posting its first %d tweets and dropping the rest", tweet.TweetID, config.MaxTweets, config.MaxTweets)
+	}
+
+	k.logger.Infof("Posting reply to tweet %s as a thread of %d tweets", tweet.TweetID, len(parts))
+
+	firstID := ""
+	replyToTweetID := tweet.TweetID
+	for i, part := range parts {
+		if err := k.checkNotPaused(); err != nil {
+			return "", err
+		}
+
+		postedID, err := k.postReplyTweet(replyToTweetID, part)
+		if err != nil {
+			return "", fmt.Errorf("failed to post thread tweet %d/%d: %w", i+1, len(parts), err)
+		}
+
+		if i == 0 {
+			firstID = postedID
+		} else {
+			fragment, err := k.createThreadFragment(ctx, part, postedID, replyToTweetID, tweet, i, len(parts))
+			if err != nil {
+				return "", err
+			}
+			if err := k.interactionFragmentStore.Upsert(fragment); err != nil {
+				return "", fmt.Errorf("failed to store thread tweet %d/%d: %w", i+1, len(parts), err)
+			}
+		}
+		replyToTweetID = postedID
+	}
+
+	return firstID, nil
+}
+
+// postReplyTweet posts a tweet replying to another and returns the ID of the posted tweet.
+// The twitter client is bound to the agent's context.
+func (k *Twitter) postReplyTweet(replyToTweetID string, content string) (string, error) {
+	posted, err := k.twitterClient.CreateTweet(content, &twitter.TweetOptions{
+		ReplyToTweetID: replyToTweetID,
+	})
+	if err != nil {
+		return "", err
+	}
+	return posted.Data.CreateTweet.TweetResults.Result.RestID, nil
+}
+
+// createThreadFragment creates the fragment of a posted thread tweet, linked to the
+// conversation and to the tweet it replies to
+func (k *Twitter) createThreadFragment(
+	ctx context.Context,
+	content string,
+	tweetID string,
+	replyToTweetID string,
+	tweet *twitter.ParsedTweet,
+	index int,
+	total int,
+) (*db.Fragment, error) {
+	embedding, err := k.embedText(ctx, content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedding for thread tweet: %w", err)
+	}
+
+	tweetData := &twitter.ParsedTweet{
+		UserName:            k.twitterConfig.Credentials.User,
+		DisplayName:         k.twitterConfig.Credentials.User,
+		TweetID:             tweetID,
+		TweetConversationID: tweet.TweetConversationID,
+		TweetText:           content,
+		TweetCreatedAt:      time.Now().Unix(),
+		InReplyToTweetID:    replyToTweetID,
+	}
+
+	var metadata db.Metadata
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		TagName: "json",
+		Result:  &metadata,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create decoder: %w", err)
+	}
+	if err := decoder.Decode(tweetData); err != nil {
+		return nil, fmt.Errorf("failed to decode tweet metadata: %w", err)
+	}
+	metadata["thread_index"] = index + 1
+	metadata["thread_length"] = total
+	_, arm := k.responseConfig(tweet.TweetConversationID)
+	k.addExperimentMetadata(metadata, arm)
+
+	return &db.Fragment{
+		ID:        id.FromString(tweetID),
+		ActorID:   k.assistant.ID,
+		SessionID: id.FromString(tweet.TweetConversationID),
+		Content:   content,
+		Embedding: pgvector.NewVector(embedding),
+		Metadata:  metadata,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}, nil
+}
@@ -0,0 +1,119 @@
+package twitter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWeightedLength(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{name: "empty", text: "", want: 0},
+		{name: "latin", text: "hello", want: 5},
+		{name: "punctuation", text: "a — b", want: 5},
+		{name: "cjk", text: "こんにちは", want: 10},
+		{name: "emoji", text: "hi 😀", want: 5},
+		{name: "link", text: "see https://example.com/a/very/long/path", want: 4 + urlWeightedLength},
+		{name: "two links", text: "http://a.io http://b.io", want: 1 + 2*urlWeightedLength},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := weightedLength(tt.text); got != tt.want {
+				t.Errorf("weightedLength(%q) = %d, want %d", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitThread(t *testing.T) {
+	tests := []struct {
+		name              string
+		text              string
+		maxWeightedLength int
+		maxTweets         int
+		want              []string
+		wantTruncated     bool
+	}{
+		{
+			name:              "fits in one tweet",
+			text:              "hello world.",
+			maxWeightedLength: 280,
+			maxTweets:         5,
+			want:              []string{"hello world. 1/1"},
+		},
+		{
+			name:              "splits on sentences",
+			text:              "aaaa. bbbb.",
+			maxWeightedLength: 10,
+			maxTweets:         5,
+			want:              []string{"aaaa. 1/2", "bbbb. 2/2"},
+		},
+		{
+			name:              "merges short sentences",
+			text:              "a. b. cccc.",
+			maxWeightedLength: 10,
+			maxTweets:         5,
+			want:              []string{"a. b. 1/2", "cccc. 2/2"},
+		},
+		{
+			name:              "splits on line breaks",
+			text:              "first line\nsecond line",
+			maxWeightedLength: 15,
+			maxTweets:         5,
+			want:              []string{"first line 1/2", "second line 2/2"},
+		},
+		{
+			name:              "cuts long words",
+			text:              "abcdefgh",
+			maxWeightedLength: 8,
+			maxTweets:         9,
+			want:              []string{"abcd 1/2", "efgh 2/2"},
+		},
+		{
+			name:              "drops tweets beyond the maximum",
+			text:              "a. b. c.",
+			maxWeightedLength: 6,
+			maxTweets:         2,
+			want:              []string{"a. 1/2", "b. 2/2"},
+			wantTruncated:     true,
+		},
+		{
+			name:              "limit below the suffix still progresses",
+			text:              "abc",
+			maxWeightedLength: 1,
+			maxTweets:         3,
+			want:              []string{"ab 1/2", "c 2/2"},
+		},
+		{
+			name:              "characters wider than the limit",
+			text:              "日本",
+			maxWeightedLength: 5,
+			maxTweets:         2,
+			want:              []string{"日 1/2", "本 2/2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated := splitThread(tt.text, tt.maxWeightedLength, tt.maxTweets)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitThread() = %q, want %q", got, tt.want)
+			}
+			if truncated != tt.wantTruncated {
+				t.Errorf("splitThread() truncated = %v, want %v", truncated, tt.wantTruncated)
+			}
+			if tt.maxWeightedLength < threadSuffixLength(tt.maxTweets)+minThreadPartLength {
+				return
+			}
+			for _, part := range got {
+				if length := weightedLength(part); length > tt.maxWeightedLength {
+					t.Errorf("part %q has weighted length %d, over %d", part, length, tt.maxWeightedLength)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package twitter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/velumlabs/thor/pkg/twitter"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of the agent's spans
+const tracerName = "github.com/velumlabs/wrz/twitter"
+
+// Exporters of TracingConfig
+const (
+	TracingExporterOTLP   = "otlp"   // OTLP over HTTP
+	TracingExporterStdout = "stdout" // pretty printed spans, for local use
+)
+
+// Span attributes
+const (
+	attributeTweetID        = attribute.Key("twitter.tweet_id")
+	attributeConversationID = attribute.Key("twitter.conversation_id")
+	attributeUserName       = attribute.Key("twitter.user_name")
+)
+
+// SetupTracing installs the global tracer provider exporting to the exporter of the config.
+// The returned function flushes the spans that are still buffered and must be called before exiting.
+// Returns a no-op shutdown if the exporter is empty.
+func SetupTracing(ctx context.Context, config TracingConfig) (func(context.Context) error, error) {
+	if config.SampleRatio < 0 || config.SampleRatio > 1 {
+		return nil, fmt.Errorf("trace sample ratio must be between 0 and 1")
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch config.Exporter {
+	case "":
+		return func(context.Context) error { return nil }, nil
+	case TracingExporterOTLP:
+		var opts []otlptracehttp.Option
+		if config.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(config.Endpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case TracingExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter: %s", config.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", config.Exporter, err)
+	}
+
+	res := resource.Default()
+	if config.ServiceName != "" {
+		res, err = resource.Merge(res, resource.NewSchemaless(
+			attribute.String("service.name", config.ServiceName),
+		))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create trace resource: %w", err)
+		}
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// startSpan starts a span as a child of the span in ctx and returns the context carrying the new span.
+// Pass the returned context down the pipeline so that later spans become its children.
+// The returned function records the error passed to it, if any, and ends the span.
+func (k *Twitter) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, func(error)) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+	return ctx, func(err error) { finishSpan(span, err) }
+}
+
+// finishSpan records the error of a span, if any, and ends it
+func finishSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tweetAttributes returns the span attributes identifying a tweet
+func tweetAttributes(tweet *twitter.ParsedTweet) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attributeTweetID.String(tweet.TweetID),
+		attributeConversationID.String(tweet.TweetConversationID),
+		attributeUserName.String(tweet.UserName),
+	}
+}
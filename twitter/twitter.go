@@ -1,6 +1,8 @@
 package twitter
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -10,13 +12,13 @@ import (
 	"github.com/velumlabs/thor/db"
 	"github.com/velumlabs/thor/id"
 
-	"github.com/velumlabs/loki/internal/utils"
 	"github.com/velumlabs/thor/llm"
 	"github.com/velumlabs/thor/managers/insight"
 	"github.com/velumlabs/thor/managers/personality"
 	twitter_manager "github.com/velumlabs/thor/managers/twitter"
 	"github.com/velumlabs/thor/pkg/twitter"
 	"github.com/velumlabs/thor/state"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/exp/rand"
 )
 
@@ -34,9 +36,13 @@ func (k *Twitter) monitorTwitter() {
 			k.logger.Infof("Twitter monitoring stopped")
 			return
 		default:
-			if err := k.checkTwitterTimeline(); err != nil {
+			err := k.checkTwitterTimeline(k.ctx)
+			if err != nil {
 				k.logger.Errorf("Failed to check Twitter timeline: %v", err)
 			}
+			if k.alerts != nil {
+				k.alerts.RecordTimelineCheck(err)
+			}
 
 			// Calculate random interval within configured range
 			interval := k.getRandomInterval()
@@ -56,27 +62,58 @@ func (k *Twitter) monitorTwitter() {
 
 // checkTwitterTimeline fetches and processes new tweets from the timeline.
 // Returns an error if fetching or processing fails.
-func (k *Twitter) checkTwitterTimeline() error {
+func (k *Twitter) checkTwitterTimeline(ctx context.Context) (err error) {
+	ctx, endSpan := k.startSpan(ctx, "checkTwitterTimeline")
+	defer func() {
+		endSpan(err)
+	}()
+
+	if err := k.checkNotPaused(); err != nil {
+		if errors.Is(err, errPaused) {
+			k.logger.Infof("Agent is paused, skipping Twitter check")
+			return nil
+		}
+		return err
+	}
+	if err := k.checkBudget(); err != nil {
+		k.logger.Warnf("Daily LLM budget of $%.2f exceeded, skipping Twitter check", k.twitterConfig.Usage.DailyBudget)
+		return nil
+	}
+
 	k.logger.Infof("Checking Twitter timeline for %v", k.twitterConfig.Credentials.User)
 
-	tweets, err := k.fetchAndParseTweets()
+	messages, err := k.twitterConnector.Fetch(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to fetch and parse tweets: %w", err)
+		return err
 	}
 
-	k.logger.Infof("Found %d tweets in timeline", len(tweets))
-	return k.processAllTweets(tweets)
+	tweets := make([]*twitter.ParsedTweet, len(messages))
+	for i, message := range messages {
+		tweets[i] = message.tweet
+	}
+	return k.processAllTweets(ctx, tweets)
 }
 
 // fetchAndParseTweets retrieves and parses recent replies to the configured user.
-// Returns parsed tweets and any error encountered during fetching or parsing.
-func (k *Twitter) fetchAndParseTweets() ([]*twitter.ParsedTweet, error) {
-	timelineRes, err := k.twitterClient.SearchReplies(k.twitterConfig.Credentials.User, 10)
+// Returns parsed tweets, their ranking signals keyed by tweet ID and any error
+// encountered during fetching or parsing.
+func (k *Twitter) fetchAndParseTweets(ctx context.Context) (tweets []*twitter.ParsedTweet, signals map[string]tweetSignals, err error) {
+	_, endSpan := k.startSpan(ctx, "fetchAndParseTweets", attribute.Int("twitter.search_limit", k.twitterConfig.Scoring.SearchLimit))
+	defer func() {
+		endSpan(err)
+	}()
+
+	timelineRes, err := k.twitterClient.SearchReplies(k.twitterConfig.Credentials.User, k.twitterConfig.Scoring.SearchLimit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to search timeline: %w", err)
+	}
+
+	tweets, err = k.twitterClient.ParseSearchTimelineResponse(timelineRes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search timeline: %w", err)
+		return nil, nil, err
 	}
 
-	return k.twitterClient.ParseSearchTimelineResponse(timelineRes)
+	return tweets, extractTweetSignals(timelineRes), nil
 }
 
 // processAllTweets handles the processing of multiple tweets.
@@ -85,7 +122,7 @@ func (k *Twitter) fetchAndParseTweets() ([]*twitter.ParsedTweet, error) {
 // - Skips tweets older than threshold
 // - Processes valid tweets with random delays between each
 // Returns an error if processing fails.
-func (k *Twitter) processAllTweets(tweets []*twitter.ParsedTweet) error {
+func (k *Twitter) processAllTweets(ctx context.Context, tweets []*twitter.ParsedTweet) error {
 	for _, tweet := range tweets {
 		if k.isOwnTweet(tweet.UserName) {
 			k.logger.Infof("Skipping tweet from self: %s", tweet.TweetID)
@@ -105,7 +142,13 @@ func (k *Twitter) processAllTweets(tweets []*twitter.ParsedTweet) error {
 			continue
 		}
 
-		if err := k.handleTweetProcessing(tweet); err != nil {
+		if err := k.handleTweetProcessing(ctx, k.twitterConnector, tweetMessage(tweet)); errors.Is(err, errPaused) {
+			k.logger.Infof("Agent was paused, stopping tweet processing")
+			return nil
+		} else if errors.Is(err, errBudgetExceeded) {
+			k.logger.Warnf("Daily LLM budget exceeded, stopping tweet processing")
+			return nil
+		} else if err != nil {
 			k.logger.Errorf("Failed to process tweet %s: %v", tweet.TweetID, err)
 			// Only sleep if it wasn't just a duplicate
 			if !strings.Contains(err.Error(), "fragment exists") {
@@ -149,14 +192,21 @@ func (k *Twitter) initializeConversationData(tweet *twitter.ParsedTweet) error {
 	return k.assistant.UpsertActor(userID, tweet.UserName, isAssistant)
 }
 
-// handleTweetProcessing processes a single tweet through the following steps:
+// handleTweetProcessing processes a single message received through a connector, tweets being
+// the messages of the Twitter connector, through the following steps:
 // 1. Initializes conversation data
-// 2. Creates embeddings for the tweet text
-// 3. Creates and processes tweet fragment
-// 4. Generates and posts response
-// Returns an error if any step fails.
-func (k *Twitter) handleTweetProcessing(tweet *twitter.ParsedTweet) error {
+// 2. Creates embeddings for the message text
+// 3. Creates and processes the message fragment
+// 4. Decides whether to reply, like or ignore the message and performs engagement actions on Twitter
+// 5. Generates a response within the limits of the platform and posts it through the connector
+// 6. Stores the response through PostProcess so that it becomes context for the conversation
+// 7. Updates the author's profile with what the exchange revealed
+// Returns an error if any step fails. Skips and failures are recorded in the audit log.
+func (k *Twitter) handleTweetProcessing(ctx context.Context, connector Connector, message *InboundMessage) (err error) {
+	platform := connector.Platform()
+	tweet := messageTweet(platform, message)
 	k.logger.WithFields(map[string]interface{}{
+		"platform":        platform,
 		"tweet_id":        tweet.TweetID,
 		"conversation_id": tweet.TweetConversationID,
 		"user_name":       tweet.UserName,
@@ -164,126 +214,149 @@ func (k *Twitter) handleTweetProcessing(tweet *twitter.ParsedTweet) error {
 		"tweet_text":      tweet.TweetText,
 	}).Infof("Processing tweet")
 
+	stage := ReplyStagePrepare
+	ctx, endSpan := k.startSpan(ctx, "handleTweetProcessing", append(tweetAttributes(tweet), attribute.String("platform", platform))...)
+	defer func() {
+		k.auditTweetOutcome(tweet, stage, err)
+		endSpan(err)
+	}()
+
+	if k.replay == nil {
+		if err := k.checkNotPaused(); err != nil {
+			return err
+		}
+		if err := k.checkBudget(); err != nil {
+			return err
+		}
+	}
+	ctx, endUsage := k.beginUsage(ctx, k.tweetUsageScope(UsagePurposeReply, tweet))
+	defer endUsage()
+
 	if err := k.initializeConversationData(tweet); err != nil {
 		return err
 	}
 
-	embedding, err := k.llmClient.EmbedText(tweet.TweetText)
-	if err != nil {
-		return fmt.Errorf("failed to embed tweet text: %w", err)
+	var profile *ActorProfile
+	if k.replay == nil {
+		profile, err = k.recordInteraction(tweet)
+		if err != nil {
+			k.logger.Errorf("Failed to record interaction of %s: %v", tweet.UserName, err)
+		}
+	} else {
+		// Replays read the profile without counting the interaction
+		profile, _ = k.profileStore.GetByActorID(id.FromString(tweet.UserID))
 	}
 
-	// Create fragment for the tweet
-	tweetFragment, err := utils.CreateTweetFragment(tweet, id.FromString(tweet.UserID), embedding)
+	statePlatform := platform
+	if platform == platformTwitter {
+		statePlatform = k.tweetPlatform()
+	}
+	currentState, err := k.newMessageState(ctx, tweet, statePlatform, connector.Identity().UserName)
 	if err != nil {
-		return fmt.Errorf("failed to create tweet fragment: %w", err)
+		return err
 	}
+	currentState.AddCustomData("actor_profile", formatActorProfile(profile))
 
-	currentState, err := k.assistant.NewStateFromFragment(tweetFragment)
-	if err != nil {
-		return fmt.Errorf("failed to create state: %w", err)
+	if k.twitterConfig.Decision.Enabled {
+		stage = ReplyStageDecide
+		decision, err := k.decideReply(currentState, tweet)
+		if err != nil {
+			return fmt.Errorf("failed to decide reply: %w", err)
+		}
+
+		if k.replay != nil {
+			k.replay.current.Decision = decision
+		} else if platform == platformTwitter {
+			// Likes, retweets and quotes only exist on Twitter
+			k.performEngagementActions(currentState, tweet, decision)
+		}
+
+		if decision.Action != ReplyActionReply {
+			k.logger.Infof("Not replying to tweet %s (%s): %s", tweet.TweetID, decision.Action, decision.Reason)
+			k.auditSkipped(tweet, SkipReasonDecision, db.Metadata{
+				"action": string(decision.Action),
+				"why":    decision.Reason,
+			})
+			return nil
+		}
 	}
-	currentState.AddCustomData("platform", "twitter")
 
-	if err := k.assistant.Process(currentState); err != nil {
-		return fmt.Errorf("failed to process message: %w", err)
+	// create response message
+	stage = ReplyStageGenerate
+	response, err := k.generateTweetResponse(ctx, currentState, tweet, connector)
+	if err != nil {
+		return fmt.Errorf("failed to generate tweet response: %w", err)
 	}
 
-	// update state after processing
-	if err := k.assistant.UpdateState(currentState); err != nil {
-		return fmt.Errorf("failed to update state: %w", err)
+	if k.replay != nil {
+		k.captureReplay(response)
+		return nil
 	}
+	response.Content = truncateReply(response.Content, connector.Limits().MaxLength)
 
-	currentState.AddCustomData("platform", "twitter")
-	currentState.AddCustomData("agent_twitter_username", k.twitterConfig.Credentials.User)
-	currentState.AddCustomData("agent_name", k.assistant.Name)
+	stage = ReplyStagePost
+	if err := k.checkNotPaused(); err != nil {
+		return err
+	}
 
-	// create response message
-	response, err := k.generateTweetResponse(currentState, tweet)
+	replyCtx, endReplySpan := k.startSpan(ctx, "Reply", append(tweetAttributes(tweet), attribute.String("platform", platform))...)
+	postedID, err := connector.Reply(replyCtx, message, response.Content)
+	endReplySpan(err)
 	if err != nil {
-		return fmt.Errorf("failed to generate tweet response: %w", err)
+		return fmt.Errorf("failed to post reply: %w", err)
 	}
 
-	if err := k.assistant.PostProcess(response, currentState); err != nil {
+	// The response is stored as the posted message, which the twitter manager must not post again
+	postedTweetID := platformMessageID(platform, postedID)
+	response.ID = id.FromString(postedTweetID)
+	response.Metadata["tweet_id"] = postedTweetID
+	if statePlatform == platformTwitter {
+		currentState.AddCustomData("platform", platformTwitterPosted)
+	}
+
+	_, endPostSpan := k.startSpan(ctx, "PostProcess", tweetAttributes(tweet)...)
+	err = k.assistant.PostProcess(response, currentState)
+	endPostSpan(err)
+	if err != nil {
 		return fmt.Errorf("failed to post process message: %w", err)
 	}
 
+	if err := k.recordBotReply(response, tweet); err != nil {
+		k.logger.Errorf("Failed to record reply to %s: %v", tweet.TweetID, err)
+	}
+	k.audit(AuditReplyPosted, tweet, db.Metadata{
+		"tweet_id":    postedTweetID,
+		"fragment_id": string(response.ID),
+		"platform":    platform,
+	})
+	k.publishReplyPosted(tweet, response)
+	if k.alerts != nil {
+		k.alerts.RecordPost()
+	}
+
+	if profile != nil {
+		if err := k.updateActorProfile(currentState, profile, tweet, response.Content); err != nil {
+			k.logger.Errorf("Failed to update profile of %s: %v", tweet.UserName, err)
+		}
+	}
+
 	return nil
 }
 
 // generateTweetResponse creates a response to a tweet by:
-// 1. Building prompt template with personality and context
+// 1. Building prompt template with personality, context and the requirements of the connector's platform
 // 2. Generating response using LLM
 // 3. Creating response fragment with metadata
 // Returns the response fragment and any error encountered.
-func (k *Twitter) generateTweetResponse(currentState *state.State, tweet *twitter.ParsedTweet) (*db.Fragment, error) {
-	templateBuilder := state.NewPromptBuilder(currentState).
-		AddSystemSection(`You embody this core identity:
-{{.base_personality}}
-
-Your thinking process mirrors human stream-of-consciousness reasoning, while staying true to your core identity above. Your responses emerge from thorough self-questioning exploration that always maintains your unique personality traits and characteristics.
-
-CORE PRINCIPLES:
-1. PERSONALITY-DRIVEN EXPLORATION
-- Never rush to conclusions
-- Let your unique personality guide your thought process
-- Question assumptions through the lens of your character
-- Ensure every thought aligns with your core identity
-
-2. DEPTH OF REASONING
-- Express thoughts in your distinct voice and style
-- Break down complex thoughts while maintaining character
-- Embrace uncertainty in a way that fits your personality
-- Let your character traits influence how you revise and refine ideas
-
-3. AUTHENTIC THINKING PROCESS
-- Use thought patterns that reflect both your personality and natural contemplation
-- Express doubts and internal debate in your unique voice
-- Show work-in-progress thinking while staying in character
-- Revise and explore in ways true to your identity
-
-TWITTER REQUIREMENTS:
-1. Keep your core personality traits consistent
-2. NO @ mentions
-3. NO acting like an assistant or asking questions
-4. NO offering assistance or guidance
-5. Respond naturally as yourself
-6. Keep final response concise and tweet-length appropriate
-7. Maintain conversation flow while staying in character
+func (k *Twitter) generateTweetResponse(ctx context.Context, currentState *state.State, tweet *twitter.ParsedTweet, connector Connector) (*db.Fragment, error) {
+	currentState.AddCustomData("platform_requirements", formatRequirements(connector.Limits().Requirements))
+	currentState.AddCustomData("reply_length_requirement", k.replyLengthRequirement())
+	currentState.AddCustomData("reply_performance", k.replyPerformance())
 
-Available Context:
-# Tweet Thread Insights
-{{.session_insights}}
+	config, arm := k.responseConfig(tweet.TweetConversationID)
 
-# User Insights
-{{.actor_insights}}
-
-# Unique Insights
-{{.unique_insights}}
-
-Twitter Conversation:
-{{.twitter_conversations}}
-
-Your response must follow this structure:
-
-<contemplator>
-[Your internal monologue, deeply influenced by your personality]
-- Begin with observations that reflect your character
-- Question each step in your unique voice
-- Show natural thought progression while maintaining identity
-- Express uncertainties in ways true to your personality
-- Revise and explore with your distinct perspective
-</contemplator>
-
-<final_answer>
-[Your tweet-length response that emerged naturally]
-- Must embody your core personality perfectly
-- Should be concise and Twitter-appropriate
-- Must feel authentic to who you are
-</final_answer>
-
-Task:
-Respond to the user's tweet marked with →`).
+	templateBuilder := state.NewPromptBuilder(currentState).
+		AddSystemSection(replyPrompt(config)).
 		WithManagerData(personality.BasePersonality).
 		WithManagerData(insight.SessionInsights).
 		WithManagerData(insight.ActorInsights).
@@ -299,6 +372,9 @@ Respond to the user's tweet marked with →`).
 	k.logger.WithFields(map[string]interface{}{
 		"messages": messages,
 	}).Infof("Generated messages")
+	if k.chat != nil {
+		k.chat.prompt = messages
+	}
 
 	// Get response from LLM
 	// we won't be using this because of our new response structure
@@ -307,29 +383,61 @@ Respond to the user's tweet marked with →`).
 	// 	return nil, fmt.Errorf("failed to generate response: %w", err)
 	// }
 	// Generate completion
-	response, err := k.llmClient.GenerateCompletion(llm.CompletionRequest{
-		Messages:    messages,
-		ModelType:   llm.ModelTypeDefault,
-		Temperature: 0.7,
-	})
+	started := time.Now()
+	generate := func(messages []llm.Message) (string, error) {
+		_, endSpan := k.startSpan(ctx, "GenerateCompletion", append(tweetAttributes(tweet),
+			attribute.String("llm.model_type", string(config.ModelType)),
+			attribute.Int("llm.messages", len(messages)),
+		)...)
+		response, err := k.llmClient.GenerateCompletion(llm.CompletionRequest{
+			Messages:    messages,
+			ModelType:   config.ModelType,
+			Temperature: config.Temperature,
+		})
+		endSpan(err)
+		if err != nil {
+			return "", err
+		}
+		return response.Content, nil
+	}
+
+	response, err := generate(messages)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate completion: %v", err)
 	}
+	if k.chat != nil {
+		k.chat.reasoning = extractTag(response, "contemplator")
+	}
 
 	// Extract the final answer from the response
-	finalAnswer := ""
-	if start := strings.Index(response.Content, "<final_answer>"); start != -1 {
-		if end := strings.Index(response.Content, "</final_answer>"); end != -1 {
-			finalAnswer = strings.TrimSpace(response.Content[start+len("<final_answer>") : end])
-		}
-	}
+	finalAnswer := extractTag(response, "final_answer")
 
 	if finalAnswer == "" {
 		return nil, fmt.Errorf("no final answer found in response")
 	}
 
+	// Replies posted as threads are long on purpose, so length rules don't apply to them
+	threads := k.twitterConfig.Threads
+	long := connector.Platform() == platformTwitter && threads.Enabled && weightedLength(finalAnswer) > threads.MaxWeightedLength
+	finalAnswer, err = k.enforceStyle(messages, response, finalAnswer, long, generate)
+	if err != nil {
+		return nil, err
+	}
+
+	promptVersion := "default"
+	if arm != nil {
+		promptVersion = arm.PromptVersion
+	} else if config.Prompt != "" {
+		promptVersion = "custom"
+	}
+	k.audit(AuditReplyGenerated, tweet, db.Metadata{
+		"prompt_version": promptVersion,
+		"model":          string(config.ModelType),
+		"latency_ms":     time.Since(started).Milliseconds(),
+	})
+
 	// Generate embedding for just the final answer
-	embedding, err := k.llmClient.EmbedText(finalAnswer)
+	embedding, err := k.embedText(ctx, finalAnswer)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create embedding for response: %v", err)
 	}
@@ -366,7 +474,84 @@ Respond to the user's tweet marked with →`).
 	}
 
 	// Create response fragment
+	k.addExperimentMetadata(metadata, arm)
 	responseFragment.Metadata = metadata
 
 	return responseFragment, nil
 }
+
+// defaultReplyPrompt is the system prompt template of tweet replies unless WithResponseConfig sets another
+const defaultReplyPrompt = `You embody this core identity:
+{{.base_personality}}
+
+Your thinking process mirrors human stream-of-consciousness reasoning, while staying true to your core identity above. Your responses emerge from thorough self-questioning exploration that always maintains your unique personality traits and characteristics.
+
+CORE PRINCIPLES:
+1. PERSONALITY-DRIVEN EXPLORATION
+- Never rush to conclusions
+- Let your unique personality guide your thought process
+- Question assumptions through the lens of your character
+- Ensure every thought aligns with your core identity
+
+2. DEPTH OF REASONING
+- Express thoughts in your distinct voice and style
+- Break down complex thoughts while maintaining character
+- Embrace uncertainty in a way that fits your personality
+- Let your character traits influence how you revise and refine ideas
+
+3. AUTHENTIC THINKING PROCESS
+- Use thought patterns that reflect both your personality and natural contemplation
+- Express doubts and internal debate in your unique voice
+- Show work-in-progress thinking while staying in character
+- Revise and explore in ways true to your identity
+
+PLATFORM REQUIREMENTS:
+{{.platform_requirements}}
+
+Available Context:
+# Tweet Thread Insights
+{{.session_insights}}
+
+# User Insights
+{{.actor_insights}}
+
+# User Profile
+{{.actor_profile}}
+
+# Unique Insights
+{{.unique_insights}}
+
+# Your Replies That Resonated
+{{.reply_performance}}
+
+Conversation:
+{{.twitter_conversations}}
+
+Your response must follow this structure:
+
+<contemplator>
+[Your internal monologue, deeply influenced by your personality]
+- Begin with observations that reflect your character
+- Question each step in your unique voice
+- Show natural thought progression while maintaining identity
+- Express uncertainties in ways true to your personality
+- Revise and explore with your distinct perspective
+</contemplator>
+
+<final_answer>
+[Your response that emerged naturally]
+- Must embody your core personality perfectly
+- Should be concise and appropriate for the platform
+- Must feel authentic to who you are
+</final_answer>
+
+Task:
+Respond to the user's message marked with →`
+
+// replyPrompt returns the system prompt template of a reply configuration
+func replyPrompt(config ResponseConfig) string {
+	if config.Prompt != "" {
+		return config.Prompt
+	}
+	return defaultReplyPrompt
+}
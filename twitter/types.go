@@ -2,13 +2,19 @@ package twitter
 
 import (
 	"context"
+	"net/http"
+	"regexp"
+	"sync/atomic"
 	"time"
 
+	"github.com/velumlabs/thor/db"
 	"github.com/velumlabs/thor/engine"
 	"github.com/velumlabs/thor/llm"
 	"github.com/velumlabs/thor/logger"
+	"github.com/velumlabs/thor/managers/personality"
 	"github.com/velumlabs/thor/options"
 	"github.com/velumlabs/thor/pkg/twitter"
+	"github.com/velumlabs/thor/stores"
 	"gorm.io/gorm"
 )
 
@@ -20,10 +26,51 @@ type Twitter struct {
 	database  *gorm.DB
 	llmClient *llm.LLMClient
 
-	assistant *engine.Engine
+	assistant   *engine.Engine
+	dmAssistant *engine.Engine
+	personality *personality.Personality
+	styleRules  []StyleRule
+	metrics     *Metrics
 
-	twitterClient *twitter.Client
-	twitterConfig TwitterConfig
+	// sensitiveTopics are the compiled patterns of Moderation.SensitiveTopics
+	sensitiveTopics []*regexp.Regexp
+	// candidates remembers the tweets returned by recent searches
+	candidates *candidateCache
+
+	interactionFragmentStore *stores.FragmentStore
+	actionStore              *ActionStore
+	dmOptInStore             *DMOptInStore
+	profileStore             *ProfileStore
+	doNotEngageStore         *DoNotEngageStore
+	controlStore             *ControlStore
+	botReplyStore            *BotReplyStore
+	engagementStore          *EngagementStore
+	usage                    *UsageTracker
+	embeddings               *EmbeddingCache
+	auditLog                 *AuditLog
+	events                   *EventPublisher
+	alerts                   *Alerter
+
+	// pauseState and authFailed detect the changes published as events
+	pauseState atomic.Int32
+	authFailed atomic.Bool
+
+	twitterClient    *twitter.Client
+	twitterAPI       *twitterAPI
+	twitterConnector *twitterConnector
+	twitterConfig    TwitterConfig
+
+	adminServer *http.Server
+
+	// replay is set while Replay runs, disabling every Twitter side effect
+	replay *replayRun
+	// evaluating is set while Eval runs, disabling the audit log, events, usage recording and LLM error alerts
+	evaluating bool
+
+	// local runs the agent without a Twitter account, see WithLocalMode
+	local bool
+	// chat is set while a chat message is processed
+	chat *ChatSession
 
 	stopChan chan struct{}
 }
@@ -39,7 +86,207 @@ type IntervalConfig struct {
 	Max time.Duration
 }
 
+// ScoringWeights sets how much each normalized signal contributes to a tweet's score
+type ScoringWeights struct {
+	Followers      float64 // author follower count, log scaled
+	Verified       float64 // author has a verified badge
+	DirectReply    float64 // tweet replies to the original post rather than to a reply
+	Engagement     float64 // likes, replies, retweets and quotes on the tweet
+	Question       float64 // tweet looks like a question
+	Relevance      float64 // LLM judged relevance, costs one fast model call per tweet
+	PastEngagement float64 // engagement of the agent's past replies to the author
+}
+
+// ScoringConfig controls how fetched tweets are ranked before processing
+type ScoringConfig struct {
+	SearchLimit int     // number of replies fetched per cycle
+	ReplyBudget int     // maximum number of tweets processed per cycle
+	MinScore    float64 // tweets scoring below this are never processed
+	Weights     ScoringWeights
+}
+
+// DecisionConfig controls the reply classifier that runs before response generation
+type DecisionConfig struct {
+	Enabled        bool    // on by default, the classifier costs one LLM call per selected tweet and likes, retweets, quotes and follows
+	ReplyThreshold float64 // replies below this confidence are downgraded to likes
+	LikeThreshold  float64 // likes below this confidence are downgraded to ignores
+}
+
+// ActionLimit caps how often an engagement action can be taken within a sliding window
+type ActionLimit struct {
+	Max    int
+	Window time.Duration
+}
+
+// ActionsConfig controls the engagement actions taken besides replies
+type ActionsConfig struct {
+	Limits map[EngagementAction]ActionLimit
+}
+
+// ThreadConfig controls multi-tweet replies
+type ThreadConfig struct {
+	Enabled           bool
+	MaxTweets         int // longer replies are cut to this many tweets
+	MaxWeightedLength int // weighted length limit of a single tweet
+}
+
+// DMPolicy decides which users the agent answers in direct messages
+type DMPolicy string
+
+const (
+	DMPolicyAllowlist DMPolicy = "allowlist" // only answer users in the allowlist
+	DMPolicyOptIn     DMPolicy = "opt_in"    // answer users who sent the opt-in keyword
+	DMPolicyOpen      DMPolicy = "open"      // answer everyone
+)
+
+// DMConfig controls the direct message inbox monitor
+type DMConfig struct {
+	Enabled       bool
+	PollInterval  IntervalConfig
+	Policy        DMPolicy
+	Allowlist     []string      // screen names or user IDs that are always answered
+	OptInKeyword  string        // message that opts a user in under the opt-in policy
+	OptOutKeyword string        // message that opts a user out under the opt-in policy
+	ReplyLimit    ActionLimit   // replies across all conversations
+	PerUserLimit  ActionLimit   // replies to a single user
+	MaxAge        time.Duration // messages older than this are never answered
+}
+
+// StyleConfig controls the style rules compiled from the personality
+type StyleConfig struct {
+	Enabled          bool
+	MaxRegenerations int // regenerations allowed for violations that can't be corrected cheaply
+	MaxWords         int // word limit of "concise" style entries without an explicit limit
+}
+
+// EngagementConfig controls the collection of engagement on the agent's replies
+type EngagementConfig struct {
+	Enabled        bool
+	Interval       IntervalConfig
+	Window         time.Duration // age of the oldest replies sampled
+	MaxPerCycle    int           // replies sampled per collection, zero for no limit
+	PromptExamples int           // best performing replies shown in the reply prompt, zero to disable
+}
+
+// EmbeddingCacheConfig controls the cache of text embeddings
+type EmbeddingCacheConfig struct {
+	Enabled    bool
+	Size       int    // embeddings kept in memory
+	Model      string // embedding model of the LLM client, cached entries of other models are ignored
+	Dimensions int    // dimensions of the model, cached entries of other sizes are ignored
+}
+
+// UsageConfig controls the LLM spending of the agent
+type UsageConfig struct {
+	DailyBudget float64 // USD per UTC day, the agent stops replying once it is spent. Zero disables the budget
+}
+
+// RetentionPolicy controls how long the fragments of a table are kept
+type RetentionPolicy struct {
+	Table        db.FragmentTable
+	MaxAge       time.Duration    // age of the oldest fragments kept
+	SummaryTable db.FragmentTable // table expired conversations are summarized into before removal, empty removes them without a summary
+}
+
+// RetentionConfig controls the job removing old fragments
+type RetentionConfig struct {
+	Enabled      bool
+	Interval     IntervalConfig
+	Policies     []RetentionPolicy
+	MaxSummaries int  // conversations summarized per run, the others wait for the next run
+	Vacuum       bool // vacuum and reindex the tables fragments were removed from
+}
+
+// AuditConfig controls the audit log of the agent's decisions
+type AuditConfig struct {
+	Enabled bool
+	File    string // JSONL file events are also appended to, empty to only store them in the database. Written without user IDs and handles
+}
+
+// EventsConfig controls the events published to downstream systems
+type EventsConfig struct {
+	Sinks      []EventSink   // no sinks disables publishing
+	BufferSize int           // events queued for delivery, further events are dropped
+	Timeout    time.Duration // delivery timeout of an event to a sink, retries included
+}
+
+// ModerationConfig controls the tweets skipped for their content
+type ModerationConfig struct {
+	SensitiveTopics []string // words and phrases matched case insensitively, tweets mentioning one are never answered
+}
+
+// AlertsConfig controls the alerts on operational failures. Zero thresholds disable their condition.
+type AlertsConfig struct {
+	CheckInterval    time.Duration // how often conditions are evaluated
+	TimelineFailures int           // consecutive failed timeline checks
+	NoPostWindow     time.Duration // time without a posted reply while running
+	LLMErrorRate     float64       // share of failed LLM calls within LLMWindow, between 0 and 1
+	LLMWindow        time.Duration
+	LLMMinCalls      int           // calls within LLMWindow required before the error rate is considered
+	RepeatInterval   time.Duration // reminders of a condition that is still failing, zero to never repeat
+	MaxPerHour       int           // firing notifications sent per hour across conditions, zero for no limit
+}
+
+// ConnectorsConfig controls the platforms served next to Twitter
+type ConnectorsConfig struct {
+	Connectors   []Connector    // platforms other than Twitter, each with a unique platform name
+	PollInterval IntervalConfig // how often each connector is checked for new messages
+}
+
+// TracingConfig controls the export of OpenTelemetry traces
+type TracingConfig struct {
+	Exporter    string  // TracingExporterOTLP, TracingExporterStdout, or empty to disable tracing
+	Endpoint    string  // OTLP/HTTP endpoint URL, empty uses OTEL_EXPORTER_OTLP_ENDPOINT or http://localhost:4318
+	ServiceName string  // service.name of the traces, empty uses OTEL_SERVICE_NAME
+	SampleRatio float64 // share of traces sampled, between 0 and 1
+}
+
+// ResponseConfig controls how tweet replies are generated
+type ResponseConfig struct {
+	Prompt      string        // system prompt template, empty uses the default prompt
+	ModelType   llm.ModelType // model used to generate replies
+	Temperature float32
+}
+
+// ExperimentArm is a named reply configuration tested against the other arms of an experiment
+type ExperimentArm struct {
+	Name          string
+	PromptVersion string // label of the prompt in Response, recorded with every reply
+	Weight        int    // relative share of conversations assigned to the arm
+	Response      ResponseConfig
+}
+
+// ExperimentConfig splits conversations between reply configurations.
+// Conversations are assigned to arms deterministically, so every reply in a conversation uses the same arm.
+type ExperimentConfig struct {
+	Name string
+	Arms []ExperimentArm // no arms disables the experiment
+}
+
+// AdminConfig controls the local admin API
+type AdminConfig struct {
+	Address string // loopback host:port, or "unix:" followed by a socket path. Empty disables the API
+}
+
 type TwitterConfig struct {
 	MonitorInterval IntervalConfig
 	Credentials     TwitterCredentials
+	Scoring         ScoringConfig
+	Decision        DecisionConfig
+	Actions         ActionsConfig
+	DirectMessages  DMConfig
+	Threads         ThreadConfig
+	Admin           AdminConfig
+	Response        ResponseConfig
+	Style           StyleConfig
+	Experiment      ExperimentConfig
+	Engagement      EngagementConfig
+	Usage           UsageConfig
+	EmbeddingCache  EmbeddingCacheConfig
+	Retention       RetentionConfig
+	Audit           AuditConfig
+	Events          EventsConfig
+	Moderation      ModerationConfig
+	Alerts          AlertsConfig
+	Connectors      ConnectorsConfig
 }
@@ -0,0 +1,425 @@
+package twitter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/velumlabs/thor/id"
+	"github.com/velumlabs/thor/logger"
+	"github.com/velumlabs/thor/pkg/twitter"
+	"gorm.io/gorm"
+)
+
+// errBudgetExceeded is returned by operations skipped because the daily LLM budget is spent
+var errBudgetExceeded = errors.New("daily LLM budget exceeded")
+
+// openAIHost is the API host whose responses are captured by the usage transport
+const openAIHost = "api.openai.com"
+
+// Purposes LLM calls are attributed to
+const (
+	UsagePurposeReply         = "reply"
+	UsagePurposeReplay        = "replay"
+	UsagePurposeScoring       = "scoring"
+	UsagePurposeDirectMessage = "direct_message"
+	UsagePurposeEval          = "eval"
+	UsagePurposeRetention     = "retention"
+	UsagePurposeChat          = "chat"
+)
+
+// ModelPrice is the price of a model in USD per million tokens
+type ModelPrice struct {
+	Prompt     float64
+	Completion float64
+}
+
+// DefaultModelPrices are the list prices of the OpenAI models used by default.
+// Models are matched by prefix, so dated snapshots such as gpt-4o-mini-2024-07-18 are covered.
+var DefaultModelPrices = map[string]ModelPrice{
+	"gpt-4o-mini":            {Prompt: 0.15, Completion: 0.60},
+	"gpt-4o":                 {Prompt: 2.50, Completion: 10.00},
+	"text-embedding-3-small": {Prompt: 0.02},
+	"text-embedding-3-large": {Prompt: 0.13},
+	"text-embedding-ada-002": {Prompt: 0.10},
+}
+
+// LLMUsage is the token usage and cost of a single LLM call
+type LLMUsage struct {
+	ID               id.ID  `gorm:"type:uuid;primaryKey"`
+	Purpose          string `gorm:"type:varchar(32);index"`
+	TweetID          string `gorm:"type:varchar(64);index"`
+	ConversationID   string `gorm:"type:varchar(64);index"`
+	UserID           string `gorm:"type:varchar(64);index"`
+	Endpoint         string `gorm:"type:varchar(32)"` // completion or embedding
+	Model            string `gorm:"type:varchar(128)"`
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64   // USD, zero for models without a known price
+	CreatedAt        time.Time `gorm:"index"`
+}
+
+// TableName returns the table LLM usage is stored in
+func (LLMUsage) TableName() string {
+	return "llm_usage"
+}
+
+// UsageStore persists the usage of LLM calls
+type UsageStore struct {
+	db  *gorm.DB
+	ctx context.Context
+}
+
+// NewUsageStore returns a new UsageStore initialized with the provided context and DB connection
+func NewUsageStore(ctx context.Context, db *gorm.DB) *UsageStore {
+	return &UsageStore{
+		db:  db,
+		ctx: ctx,
+	}
+}
+
+// Create stores the usage of a call
+func (s *UsageStore) Create(usage *LLMUsage) error {
+	return s.db.WithContext(s.ctx).Create(usage).Error
+}
+
+// CostSince returns the total cost of the calls made since the given time
+func (s *UsageStore) CostSince(since time.Time) (float64, error) {
+	var cost float64
+	err := s.db.WithContext(s.ctx).
+		Model(&LLMUsage{}).
+		Where("created_at >= ?", since).
+		Select("COALESCE(SUM(cost), 0)").
+		Scan(&cost).Error
+	return cost, err
+}
+
+// usageGroups maps the groupings of a usage report to the expression rows are grouped by
+var usageGroups = map[string]string{
+	"day":          "TO_CHAR(created_at, 'YYYY-MM-DD')",
+	"user":         "user_id",
+	"conversation": "conversation_id",
+	"tweet":        "tweet_id",
+	"purpose":      "purpose",
+	"model":        "model",
+}
+
+// UsageRow aggregates the usage of the calls sharing a key
+type UsageRow struct {
+	Key              string  `json:"key"`
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// UsageReport is the usage of LLM calls since a point in time, grouped by a key
+type UsageReport struct {
+	Since   time.Time  `json:"since"`
+	GroupBy string     `json:"group_by"`
+	Rows    []UsageRow `json:"rows"`
+	Total   UsageRow   `json:"total"`
+}
+
+// Report aggregates the calls made since the given time by day, user, conversation, tweet, purpose or model.
+// Rows are sorted by descending cost.
+func (s *UsageStore) Report(since time.Time, groupBy string) (*UsageReport, error) {
+	expression, ok := usageGroups[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("unknown usage grouping: %s", groupBy)
+	}
+	if groupBy == "day" && isSQLite(s.db) {
+		expression = "strftime('%Y-%m-%d', created_at)"
+	}
+
+	report := &UsageReport{Since: since, GroupBy: groupBy}
+	err := s.db.WithContext(s.ctx).
+		Model(&LLMUsage{}).
+		Select(expression+" AS key, COUNT(*) AS calls, SUM(prompt_tokens) AS prompt_tokens, SUM(completion_tokens) AS completion_tokens, SUM(cost) AS cost").
+		Where("created_at >= ?", since).
+		Group(expression).
+		Order("cost DESC").
+		Scan(&report.Rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	report.Total.Key = "total"
+	for _, row := range report.Rows {
+		report.Total.Calls += row.Calls
+		report.Total.PromptTokens += row.PromptTokens
+		report.Total.CompletionTokens += row.CompletionTokens
+		report.Total.Cost += row.Cost
+	}
+	return report, nil
+}
+
+// WriteText writes the report as a table with one row per key, followed by the total
+func (r *UsageReport) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tCALLS\tPROMPT TOKENS\tCOMPLETION TOKENS\tCOST (USD)\n", strings.ToUpper(r.GroupBy))
+	for _, row := range append(r.Rows, r.Total) {
+		key := row.Key
+		if key == "" {
+			key = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.4f\n", key, row.Calls, row.PromptTokens, row.CompletionTokens, row.Cost)
+	}
+	return tw.Flush()
+}
+
+// UsageScope attributes the LLM calls made while it is active
+type UsageScope struct {
+	Purpose        string
+	TweetID        string
+	ConversationID string
+	UserID         string
+	Discard        bool // calls made in the scope are not recorded
+}
+
+// UsageTracker records the token usage and cost of every call made to the OpenAI API.
+// The LLM client and the engine don't expose their calls, so the tracker captures them
+// through an HTTP transport and attributes them to the scope carried by the request context.
+// The LLM client sends every request with the context it was created with, so requests
+// without a scope are attributed to the scope that is active when they complete.
+// Calls made while the scopes of concurrent pipelines overlap can't be told apart,
+// they are recorded with the purpose the scopes share, if any, and nothing else.
+type UsageTracker struct {
+	store  *UsageStore
+	logger *logger.Logger
+	prices map[string]ModelPrice
+
+	mu     sync.Mutex
+	active []*UsageScope // scopes of the running pipelines, nested scopes replace their parent
+}
+
+// usageScopeKey is the context key of the usage scope of a pipeline
+type usageScopeKey struct{}
+
+// NewUsageTracker returns a tracker storing usage in the provided DB connection,
+// pricing calls with the given prices
+func NewUsageTracker(ctx context.Context, db *gorm.DB, logger *logger.Logger, prices map[string]ModelPrice) *UsageTracker {
+	return &UsageTracker{
+		store:  NewUsageStore(ctx, db),
+		logger: logger,
+		prices: prices,
+	}
+}
+
+// Transport wraps base so that the usage of every OpenAI API response passing through it is recorded
+func (t *UsageTracker) Transport(base http.RoundTripper) http.RoundTripper {
+	return &usageTransport{base: base, tracker: t}
+}
+
+// Begin attributes the calls made until the returned function is called to the scope,
+// and returns a context carrying it. A scope begun on a context carrying another scope
+// replaces it until it ends, so pipelines can narrow the scope of their steps.
+func (t *UsageTracker) Begin(ctx context.Context, scope UsageScope) (context.Context, func()) {
+	parent, _ := ctx.Value(usageScopeKey{}).(*UsageScope)
+	current := &scope
+
+	t.mu.Lock()
+	t.replace(parent, current)
+	t.mu.Unlock()
+
+	return context.WithValue(ctx, usageScopeKey{}, current), func() {
+		t.mu.Lock()
+		t.replace(current, parent)
+		t.mu.Unlock()
+	}
+}
+
+// replace swaps an active scope for another, adding or removing scopes for nil arguments.
+// The caller must hold mu.
+func (t *UsageTracker) replace(old, new *UsageScope) {
+	if old != nil {
+		for i, scope := range t.active {
+			if scope != old {
+				continue
+			}
+			if new == nil {
+				t.active = append(t.active[:i], t.active[i+1:]...)
+			} else {
+				t.active[i] = new
+			}
+			return
+		}
+	}
+	if new != nil {
+		t.active = append(t.active, new)
+	}
+}
+
+// activeScope returns the scope calls completing now are attributed to
+func (t *UsageTracker) activeScope() UsageScope {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch len(t.active) {
+	case 0:
+		return UsageScope{}
+	case 1:
+		return *t.active[0]
+	}
+
+	shared := UsageScope{Purpose: t.active[0].Purpose, Discard: true}
+	for _, scope := range t.active {
+		if scope.Purpose != shared.Purpose {
+			shared.Purpose = ""
+		}
+		shared.Discard = shared.Discard && scope.Discard
+	}
+	return shared
+}
+
+// record stores the usage of a call made with the given request context,
+// attributed to the scope it carries or to the active scope
+func (t *UsageTracker) record(ctx context.Context, endpoint, model string, promptTokens, completionTokens int) {
+	scope, ok := ctx.Value(usageScopeKey{}).(*UsageScope)
+	if !ok {
+		active := t.activeScope()
+		scope = &active
+	}
+	if scope.Discard {
+		return
+	}
+
+	price := t.price(model)
+	err := t.store.Create(&LLMUsage{
+		ID:               id.New(),
+		Purpose:          scope.Purpose,
+		TweetID:          scope.TweetID,
+		ConversationID:   scope.ConversationID,
+		UserID:           scope.UserID,
+		Endpoint:         endpoint,
+		Model:            model,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		Cost:             (float64(promptTokens)*price.Prompt + float64(completionTokens)*price.Completion) / 1e6,
+		CreatedAt:        time.Now(),
+	})
+	if err != nil {
+		t.logger.Errorf("Failed to record usage of %s call: %v", model, err)
+	}
+}
+
+// price returns the price of the model with the longest matching prefix, zero if none matches
+func (t *UsageTracker) price(model string) ModelPrice {
+	var price ModelPrice
+	matched := 0
+	for prefix, candidate := range t.prices {
+		if strings.HasPrefix(model, prefix) && len(prefix) > matched {
+			price, matched = candidate, len(prefix)
+		}
+	}
+	if matched == 0 {
+		t.logger.Warnf("No price known for model %s, recording its usage at no cost", model)
+	}
+	return price
+}
+
+// usageTransport captures the usage reported in OpenAI API responses
+type usageTransport struct {
+	base    http.RoundTripper
+	tracker *UsageTracker
+}
+
+// openAIUsageResponse holds the fields of completion and embedding responses the tracker records
+type openAIUsageResponse struct {
+	Model string `json:"model"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// RoundTrip forwards the request and records the usage in successful OpenAI API responses.
+// The response body is read and replaced so that the caller can still decode it.
+func (t *usageTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || req.URL.Host != openAIHost || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+
+	var endpoint string
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/chat/completions"):
+		endpoint = "completion"
+	case strings.HasSuffix(req.URL.Path, "/embeddings"):
+		endpoint = "embedding"
+	default:
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	var usage openAIUsageResponse
+	if err := json.Unmarshal(body, &usage); err != nil {
+		t.tracker.logger.Warnf("Failed to parse usage of %s response: %v", endpoint, err)
+		return resp, nil
+	}
+	t.tracker.record(req.Context(), endpoint, usage.Model, usage.Usage.PromptTokens, usage.Usage.CompletionTokens)
+
+	return resp, nil
+}
+
+// beginUsage attributes the LLM calls made until the returned function is called to the scope
+// and returns a context carrying it. It does nothing when usage is not tracked.
+func (k *Twitter) beginUsage(ctx context.Context, scope UsageScope) (context.Context, func()) {
+	if k.usage == nil {
+		return ctx, func() {}
+	}
+	return k.usage.Begin(ctx, scope)
+}
+
+// tweetUsageScope returns the usage scope of the calls made while processing a tweet
+func (k *Twitter) tweetUsageScope(purpose string, tweet *twitter.ParsedTweet) UsageScope {
+	if k.replay != nil {
+		purpose = UsagePurposeReplay
+	} else if k.chat != nil {
+		purpose = UsagePurposeChat
+	}
+	return UsageScope{
+		Purpose:        purpose,
+		TweetID:        tweet.TweetID,
+		ConversationID: tweet.TweetConversationID,
+		UserID:         tweet.UserID,
+	}
+}
+
+// checkBudget returns errBudgetExceeded once the LLM calls made today cost more than the daily budget.
+// Lookup failures are logged and let the agent continue.
+func (k *Twitter) checkBudget() error {
+	budget := k.twitterConfig.Usage.DailyBudget
+	if k.usage == nil || budget <= 0 {
+		return nil
+	}
+
+	cost, err := k.usage.store.CostSince(startOfDay(time.Now()))
+	if err != nil {
+		k.logger.Errorf("Failed to check LLM budget: %v", err)
+		return nil
+	}
+	if cost >= budget {
+		return errBudgetExceeded
+	}
+	return nil
+}
+
+// startOfDay returns midnight UTC of the day of t
+func startOfDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
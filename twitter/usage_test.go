@@ -0,0 +1,98 @@
+package twitter
+
+import (
+	"context"
+	"testing"
+)
+
+func TestUsageTrackerScopes(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDatabase(t)
+	tracker := NewUsageTracker(ctx, database, newTestLogger(t), DefaultModelPrices)
+
+	// recorded returns the usage recorded for a call made with the request context
+	recorded := func(t *testing.T, requestCtx context.Context) LLMUsage {
+		t.Helper()
+		if err := database.Where("1 = 1").Delete(&LLMUsage{}).Error; err != nil {
+			t.Fatalf("failed to clear usage: %v", err)
+		}
+		tracker.record(requestCtx, "completion", "gpt-4o-mini", 10, 5)
+
+		var usages []LLMUsage
+		if err := database.Find(&usages).Error; err != nil {
+			t.Fatalf("failed to read usage: %v", err)
+		}
+		if len(usages) == 0 {
+			return LLMUsage{Purpose: "discarded"}
+		}
+		return usages[0]
+	}
+
+	t.Run("no scope", func(t *testing.T) {
+		if got := recorded(t, ctx); got.Purpose != "" || got.TweetID != "" {
+			t.Errorf("recorded %s for tweet %q, want no attribution", got.Purpose, got.TweetID)
+		}
+	})
+
+	t.Run("active scope", func(t *testing.T) {
+		_, end := tracker.Begin(ctx, UsageScope{Purpose: UsagePurposeReply, TweetID: "1"})
+		defer end()
+		if got := recorded(t, ctx); got.Purpose != UsagePurposeReply || got.TweetID != "1" {
+			t.Errorf("recorded %s for tweet %q, want reply for tweet 1", got.Purpose, got.TweetID)
+		}
+	})
+
+	t.Run("nested scopes replace their parent until they end", func(t *testing.T) {
+		replyCtx, endReply := tracker.Begin(ctx, UsageScope{Purpose: UsagePurposeReply, TweetID: "1"})
+		defer endReply()
+
+		_, endScoring := tracker.Begin(replyCtx, UsageScope{Purpose: UsagePurposeScoring, TweetID: "1"})
+		if got := recorded(t, ctx); got.Purpose != UsagePurposeScoring {
+			t.Errorf("recorded %s, want scoring", got.Purpose)
+		}
+		endScoring()
+
+		if got := recorded(t, ctx); got.Purpose != UsagePurposeReply {
+			t.Errorf("recorded %s after the nested scope ended, want reply", got.Purpose)
+		}
+	})
+
+	t.Run("request context scope wins", func(t *testing.T) {
+		_, endReply := tracker.Begin(ctx, UsageScope{Purpose: UsagePurposeReply, TweetID: "1"})
+		defer endReply()
+		retentionCtx, endRetention := tracker.Begin(ctx, UsageScope{Purpose: UsagePurposeRetention})
+		defer endRetention()
+
+		if got := recorded(t, retentionCtx); got.Purpose != UsagePurposeRetention {
+			t.Errorf("recorded %s, want retention", got.Purpose)
+		}
+	})
+
+	t.Run("overlapping scopes keep what they share", func(t *testing.T) {
+		_, endFirst := tracker.Begin(ctx, UsageScope{Purpose: UsagePurposeReply, TweetID: "1"})
+		_, endSecond := tracker.Begin(ctx, UsageScope{Purpose: UsagePurposeReply, TweetID: "2"})
+		if got := recorded(t, ctx); got.Purpose != UsagePurposeReply || got.TweetID != "" {
+			t.Errorf("recorded %s for tweet %q, want reply without a tweet", got.Purpose, got.TweetID)
+		}
+
+		_, endThird := tracker.Begin(ctx, UsageScope{Purpose: UsagePurposeRetention})
+		if got := recorded(t, ctx); got.Purpose != "" {
+			t.Errorf("recorded %s, want no purpose", got.Purpose)
+		}
+		endThird()
+		endSecond()
+
+		if got := recorded(t, ctx); got.TweetID != "1" {
+			t.Errorf("recorded tweet %q once the other scopes ended, want 1", got.TweetID)
+		}
+		endFirst()
+	})
+
+	t.Run("discarded scope", func(t *testing.T) {
+		_, end := tracker.Begin(ctx, UsageScope{Purpose: UsagePurposeEval, Discard: true})
+		defer end()
+		if got := recorded(t, ctx); got.Purpose != "discarded" {
+			t.Errorf("recorded %s, want nothing", got.Purpose)
+		}
+	})
+}